@@ -0,0 +1,558 @@
+package folder
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+const (
+	// BasicAnalyzer is the name of the analyzer that splits text on spaces and commas, lowercases the
+	// tokens, and removes punctuations and English stop words.
+	BasicAnalyzer = "basic"
+
+	// StandardAnalyzer is the name of the analyzer that splits text into words at Unicode word
+	// boundaries, lowercases the tokens, and removes punctuations and English stop words.
+	StandardAnalyzer = "standard"
+
+	// CJKAnalyzer is the name of the analyzer that works like the standard analyzer except that it
+	// breaks down Chinese, Japanese, and Korean text into overlapping bigrams.
+	CJKAnalyzer = "cjk"
+
+	// JapaneseAnalyzer is the name of the analyzer that segments Japanese text into words using the
+	// IPADIC dictionary, reduces conjugated words to their dictionary form, and removes particles,
+	// auxiliary verbs, punctuations, and Japanese stop words.
+	JapaneseAnalyzer = "japanese"
+
+	// DefaultAnalyzer is the name of the analyzer used by new indexes for fields without an analyzer.
+	DefaultAnalyzer = StandardAnalyzer
+
+	// LegacyAnalyzer is the name of the analyzer used by indexes saved before analyzers were
+	// configurable.
+	LegacyAnalyzer = BasicAnalyzer
+)
+
+// Token is a term produced by an analyzer along with where it came from in the analyzed text.
+type Token struct {
+	Term     string
+	Type     string
+	Position int    // Position of the token in the token stream
+	Start    int    // Byte offset of the start of the token in the analyzed text
+	End      int    // Byte offset of the end of the token in the analyzed text
+	BaseForm string // Dictionary form of the term, if known by the tokenizer
+	Reading  string // Reading of the term in Katakana, if known by the tokenizer
+}
+
+// CharFilter transforms text before it is tokenized.
+type CharFilter interface {
+	Filter(s string) string
+}
+
+// Tokenizer breaks down text into tokens.
+type Tokenizer interface {
+	Tokenize(s string) []Token
+}
+
+// TokenFilter adds, removes, or changes the tokens produced by a tokenizer.
+type TokenFilter interface {
+	Filter(tokens []Token) []Token
+}
+
+// CharFilterFunc allows an ordinary function to be used as a CharFilter.
+type CharFilterFunc func(s string) string
+
+// Filter calls f(s).
+func (f CharFilterFunc) Filter(s string) string {
+	return f(s)
+}
+
+// TokenizerFunc allows an ordinary function to be used as a Tokenizer.
+type TokenizerFunc func(s string) []Token
+
+// Tokenize calls f(s).
+func (f TokenizerFunc) Tokenize(s string) []Token {
+	return f(s)
+}
+
+// TokenFilterFunc allows an ordinary function to be used as a TokenFilter.
+type TokenFilterFunc func(tokens []Token) []Token
+
+// Filter calls f(tokens).
+func (f TokenFilterFunc) Filter(tokens []Token) []Token {
+	return f(tokens)
+}
+
+// Analyzer turns text into tokens by passing it through char filters, a tokenizer, and token
+// filters in that order.
+type Analyzer struct {
+	CharFilters  []CharFilter
+	Tokenizer    Tokenizer
+	TokenFilters []TokenFilter
+}
+
+// Analyze breaks down text into tokens. The offsets of the tokens refer to the text before it is
+// changed by the char filters, as far as they can tell where their output came from.
+func (analyzer *Analyzer) Analyze(s string) (tokens []Token) {
+	s, mapping := analyzer.filterChars(s)
+	tokens = analyzer.Tokenizer.Tokenize(s)
+
+	for _, tokenFilter := range analyzer.TokenFilters {
+		tokens = tokenFilter.Filter(tokens)
+	}
+
+	if mapping != nil {
+		for i := range tokens {
+			tokens[i].Start, tokens[i].End = mapping.Span(tokens[i].Start, tokens[i].End)
+		}
+	}
+	return
+}
+
+// filterChars applies the char filters to text and returns the mapping from the offsets of the
+// filtered text to the original one, or nil if there is no char filter.
+func (analyzer *Analyzer) filterChars(s string) (filtered string, mapping *OffsetMapping) {
+	filtered = s
+	for _, charFilter := range analyzer.CharFilters {
+		var next OffsetMapping
+		s = filtered
+
+		if offsetCharFilter, ok := charFilter.(OffsetCharFilter); ok {
+			filtered, next = offsetCharFilter.FilterWithOffsets(s)
+		} else {
+			filtered = charFilter.Filter(s)
+			next = identityOffsetMapping(len(s), len(filtered))
+		}
+
+		if mapping == nil {
+			mapping = &next
+		} else {
+			composed := mapping.then(next)
+			mapping = &composed
+		}
+	}
+	return
+}
+
+// ComponentConfig describes a char filter, tokenizer, or token filter by its registered type and
+// its parameters.
+type ComponentConfig struct {
+	Type   string
+	Params []string
+}
+
+// AnalyzerConfig describes an analyzer by its components so that it can be saved with the index and
+// rebuilt when the index is loaded.
+type AnalyzerConfig struct {
+	CharFilters  []ComponentConfig
+	Tokenizer    ComponentConfig
+	TokenFilters []ComponentConfig
+}
+
+// CharFilterConstructor creates a char filter from its parameters.
+type CharFilterConstructor func(params []string) (CharFilter, error)
+
+// TokenizerConstructor creates a tokenizer from its parameters.
+type TokenizerConstructor func(params []string) (Tokenizer, error)
+
+// TokenFilterConstructor creates a token filter from its parameters.
+type TokenFilterConstructor func(params []string) (TokenFilter, error)
+
+var (
+	charFilterConstructors = map[string]CharFilterConstructor{
+		"html_strip":     newHTMLStripCharFilter,
+		"markdown_strip": newMarkdownStripCharFilter,
+	}
+	tokenizerConstructors = map[string]TokenizerConstructor{
+		"separator": newSeparatorTokenizer,
+		"unicode":   newUnicodeTokenizer,
+		"japanese":  newJapaneseTokenizer,
+
+		"ngram":      newNGramTokenizer,
+		"edge_ngram": newEdgeNGramTokenizer,
+		"identifier": newIdentifierTokenizer,
+	}
+	tokenFilterConstructors = map[string]TokenFilterConstructor{
+		"lowercase":   staticTokenFilter(LowercaseTokenFilter),
+		"punctuation": staticTokenFilter(PunctuationTokenFilter),
+		"stop":        newStopTokenFilter,
+		"cjk_bigram":  staticTokenFilter(CJKBigramTokenFilter),
+		"stemmer":     newStemmerTokenFilter,
+
+		"nfkc":          staticTokenFilter(NFKCTokenFilter),
+		"width_folding": staticTokenFilter(WidthFoldingTokenFilter),
+		"ascii_folding": staticTokenFilter(ASCIIFoldingTokenFilter),
+		"kana_folding":  staticTokenFilter(KanaFoldingTokenFilter),
+		"transliterate": newTransliterationTokenFilter,
+
+		"ngram":      newNGramTokenFilter,
+		"edge_ngram": newEdgeNGramTokenFilter,
+
+		"soundex":          staticTokenFilter(SoundexTokenFilter),
+		"double_metaphone": staticTokenFilter(DoubleMetaphoneTokenFilter),
+		"identifier":       staticTokenFilter(IdentifierTokenFilter),
+
+		"japanese_base_form": staticTokenFilter(JapaneseBaseFormTokenFilter),
+		"japanese_pos_stop":  newJapanesePartOfSpeechStopTokenFilter,
+	}
+
+	// builtinAnalyzers contains the analyzers that are available in every index without having to
+	// be added.
+	builtinAnalyzers = map[string]AnalyzerConfig{
+		BasicAnalyzer: {
+			Tokenizer: ComponentConfig{Type: "separator"},
+			TokenFilters: []ComponentConfig{
+				{Type: "lowercase"},
+				{Type: "punctuation"},
+				{Type: "stop"},
+			},
+		},
+		StandardAnalyzer: {
+			Tokenizer: ComponentConfig{Type: "unicode"},
+			TokenFilters: []ComponentConfig{
+				{Type: "lowercase"},
+				{Type: "punctuation"},
+				{Type: "stop"},
+			},
+		},
+		CJKAnalyzer: {
+			Tokenizer: ComponentConfig{Type: "unicode"},
+			TokenFilters: []ComponentConfig{
+				{Type: "cjk_bigram"},
+				{Type: "lowercase"},
+				{Type: "punctuation"},
+				{Type: "stop"},
+			},
+		},
+		JapaneseAnalyzer: {
+			Tokenizer: ComponentConfig{Type: "japanese"},
+			TokenFilters: []ComponentConfig{
+				{Type: "japanese_base_form"},
+				{Type: "japanese_pos_stop"},
+				{Type: "stop", Params: []string{"_japanese_"}},
+				{Type: "lowercase"},
+			},
+		},
+	}
+)
+
+// RegisterCharFilter makes a char filter type available to analyzer configurations.
+func RegisterCharFilter(name string, constructor CharFilterConstructor) {
+	charFilterConstructors[name] = constructor
+}
+
+// RegisterTokenizer makes a tokenizer type available to analyzer configurations.
+func RegisterTokenizer(name string, constructor TokenizerConstructor) {
+	tokenizerConstructors[name] = constructor
+}
+
+// RegisterTokenFilter makes a token filter type available to analyzer configurations.
+func RegisterTokenFilter(name string, constructor TokenFilterConstructor) {
+	tokenFilterConstructors[name] = constructor
+}
+
+// staticTokenFilter returns a constructor for token filters that do not take any parameters.
+func staticTokenFilter(f func(tokens []Token) []Token) TokenFilterConstructor {
+	return func(params []string) (TokenFilter, error) {
+		return TokenFilterFunc(f), nil
+	}
+}
+
+// NewAnalyzer builds an analyzer from its configuration.
+func NewAnalyzer(config AnalyzerConfig) (analyzer *Analyzer, err error) {
+	analyzer = &Analyzer{}
+
+	for _, c := range config.CharFilters {
+		var charFilter CharFilter
+
+		constructor, ok := charFilterConstructors[c.Type]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownCharFilter, c.Type)
+		}
+
+		charFilter, err = constructor(c.Params)
+		if err != nil {
+			return nil, err
+		}
+		analyzer.CharFilters = append(analyzer.CharFilters, charFilter)
+	}
+
+	constructor, ok := tokenizerConstructors[config.Tokenizer.Type]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTokenizer, config.Tokenizer.Type)
+	}
+
+	analyzer.Tokenizer, err = constructor(config.Tokenizer.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range config.TokenFilters {
+		var tokenFilter TokenFilter
+
+		constructor, ok := tokenFilterConstructors[c.Type]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownTokenFilter, c.Type)
+		}
+
+		tokenFilter, err = constructor(c.Params)
+		if err != nil {
+			return nil, err
+		}
+		analyzer.TokenFilters = append(analyzer.TokenFilters, tokenFilter)
+	}
+
+	return
+}
+
+// AnalysisSettings contains the analyzers of an index and which fields they are used for. It is
+// saved along with the index so that a loaded index analyzes queries like it analyzed documents.
+type AnalysisSettings struct {
+	Analyzers            map[string]AnalyzerConfig // Analyzers added to the index in addition to the built-in ones
+	FieldAnalyzers       map[string]string         // Analyzer names of fields that don't use the default analyzer
+	FieldSearchAnalyzers map[string]string         // Analyzer names used for queries on fields that are not analyzed like their documents
+	DefaultAnalyzer      string                    // Analyzer name of fields without their own analyzer
+	LanguageDetection    LanguageDetection         // Analyzers of the languages detected in fields without their own analyzer
+}
+
+// NewAnalysisSettings creates analysis settings that use the default analyzer for every field.
+func NewAnalysisSettings() (settings AnalysisSettings) {
+	settings.Analyzers = make(map[string]AnalyzerConfig)
+	settings.FieldAnalyzers = make(map[string]string)
+	settings.FieldSearchAnalyzers = make(map[string]string)
+	settings.DefaultAnalyzer = DefaultAnalyzer
+	return
+}
+
+// analyzerConfig returns the configuration of an added or built-in analyzer.
+func (settings *AnalysisSettings) analyzerConfig(name string) (config AnalyzerConfig, ok bool) {
+	config, ok = settings.Analyzers[name]
+	if ok {
+		return
+	}
+	config, ok = builtinAnalyzers[name]
+	return
+}
+
+// fieldAnalyzer returns the analyzer name of a field.
+func (settings *AnalysisSettings) fieldAnalyzer(field string) (name string) {
+	name, ok := settings.FieldAnalyzers[field]
+	if ok {
+		return
+	}
+
+	name = settings.DefaultAnalyzer
+	if name == "" {
+		name = DefaultAnalyzer
+	}
+	return
+}
+
+// fieldSearchAnalyzer returns the analyzer name used for queries on a field, which is the analyzer
+// of the field unless it has its own search analyzer.
+func (settings *AnalysisSettings) fieldSearchAnalyzer(field string) (name string) {
+	name, ok := settings.FieldSearchAnalyzers[field]
+	if ok {
+		return
+	}
+	return settings.fieldAnalyzer(field)
+}
+
+// searchAnalyzerNames returns the sorted names of the analyzers that are used for queries on at
+// least one field.
+func (settings *AnalysisSettings) searchAnalyzerNames() (names []string) {
+	set := MakeStringSet([]string{settings.fieldSearchAnalyzer("")})
+	for field := range settings.FieldAnalyzers {
+		set.Add(settings.fieldSearchAnalyzer(field))
+	}
+	for _, name := range settings.FieldSearchAnalyzers {
+		set.Add(name)
+	}
+	for _, name := range settings.LanguageDetection.Analyzers {
+		set.Add(name)
+	}
+
+	names = set.List()
+	sort.Strings(names)
+	return
+}
+
+// AddAnalyzer adds a named analyzer to the index, replacing any analyzer with the same name.
+func (index *Index) AddAnalyzer(name string, config AnalyzerConfig) (err error) {
+	analyzer, err := NewAnalyzer(config)
+	if err != nil {
+		return
+	}
+
+	if index.Analysis.Analyzers == nil {
+		index.Analysis.Analyzers = make(map[string]AnalyzerConfig)
+	}
+	index.Analysis.Analyzers[name] = config
+
+	index.analyzers.set(name, analyzer)
+	return
+}
+
+// SetFieldAnalyzer sets the analyzer of a field. It should be set before indexing any document as
+// documents that are already indexed are not analyzed again.
+func (index *Index) SetFieldAnalyzer(field, analyzerName string) (err error) {
+	_, err = index.Analyzer(analyzerName)
+	if err != nil {
+		return
+	}
+
+	if index.Analysis.FieldAnalyzers == nil {
+		index.Analysis.FieldAnalyzers = make(map[string]string)
+	}
+	index.Analysis.FieldAnalyzers[field] = analyzerName
+	return
+}
+
+// SetFieldSearchAnalyzer sets the analyzer used for queries on a field when it should differ from
+// the analyzer of the field, e.g. when documents are broken down into n-grams for search-as-you-type
+// but queries should be analyzed normally.
+func (index *Index) SetFieldSearchAnalyzer(field, analyzerName string) (err error) {
+	_, err = index.Analyzer(analyzerName)
+	if err != nil {
+		return
+	}
+
+	if index.Analysis.FieldSearchAnalyzers == nil {
+		index.Analysis.FieldSearchAnalyzers = make(map[string]string)
+	}
+	index.Analysis.FieldSearchAnalyzers[field] = analyzerName
+	return
+}
+
+// SetDefaultAnalyzer sets the analyzer of fields without their own analyzer. Just like
+// SetFieldAnalyzer, it should be set before indexing any document.
+func (index *Index) SetDefaultAnalyzer(analyzerName string) (err error) {
+	_, err = index.Analyzer(analyzerName)
+	if err != nil {
+		return
+	}
+
+	index.Analysis.DefaultAnalyzer = analyzerName
+	return
+}
+
+// Analyzer returns an added or built-in analyzer by its name.
+func (index *Index) Analyzer(name string) (analyzer *Analyzer, err error) {
+	analyzer, ok := index.analyzers.get(name)
+	if ok {
+		return
+	}
+
+	config, ok := index.Analysis.analyzerConfig(name)
+	if !ok {
+		err = fmt.Errorf("%w: %s", ErrUnknownAnalyzer, name)
+		return
+	}
+
+	analyzer, err = NewAnalyzer(config)
+	if err != nil {
+		return
+	}
+
+	index.analyzers.set(name, analyzer)
+	return
+}
+
+// analyzerCache keeps the analyzers of an index once they are built. Searches build analyzers as
+// they need them and may run concurrently, also on the copies of the index made by uncached
+// searches which share the cache, so the cache is guarded by a lock.
+type analyzerCache struct {
+	mu        sync.RWMutex
+	analyzers map[string]*Analyzer
+}
+
+func newAnalyzerCache() (cache *analyzerCache) {
+	cache = &analyzerCache{}
+	cache.analyzers = make(map[string]*Analyzer)
+	return
+}
+
+// get returns a cached analyzer. Indexes that weren't created with New don't have a cache, in which
+// case analyzers are built every time.
+func (cache *analyzerCache) get(name string) (analyzer *Analyzer, ok bool) {
+	if cache == nil {
+		return
+	}
+
+	cache.mu.RLock()
+	defer cache.mu.RUnlock()
+	analyzer, ok = cache.analyzers[name]
+	return
+}
+
+func (cache *analyzerCache) set(name string, analyzer *Analyzer) {
+	if cache == nil {
+		return
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cache.analyzers[name] = analyzer
+}
+
+// AnalyzeField breaks down a field value into the terms that would be indexed for that field.
+func (index *Index) AnalyzeField(field, s string) (terms []string) {
+	return index.analyzeWith(index.Analysis.fieldAnalyzer(field), s)
+}
+
+// analyzeWith breaks down text into terms using a named analyzer.
+func (index *Index) analyzeWith(analyzerName, s string) (terms []string) {
+	analyzer, err := index.Analyzer(analyzerName)
+	if err != nil {
+		debug("  Failed to analyze with", analyzerName+":", err)
+		return
+	}
+
+	for _, token := range analyzer.Analyze(s) {
+		terms = append(terms, token.Term)
+	}
+	return
+}
+
+// analyzeGroupsWith breaks down text into terms using a named analyzer and groups the terms by
+// position. Terms at the same position, such as a word and its transliteration, are alternatives.
+func (index *Index) analyzeGroupsWith(analyzerName, s string) (groups [][]string) {
+	analyzer, err := index.Analyzer(analyzerName)
+	if err != nil {
+		debug("  Failed to analyze with", analyzerName+":", err)
+		return
+	}
+
+	groupIndexes := make(map[int]int)
+	for _, token := range analyzer.Analyze(s) {
+		i, ok := groupIndexes[token.Position]
+		if !ok {
+			i = len(groups)
+			groupIndexes[token.Position] = i
+			groups = append(groups, nil)
+		}
+		if !contains(groups[i], token.Term) {
+			groups[i] = append(groups[i], token.Term)
+		}
+	}
+	return
+}
+
+// analyzeQuery breaks down a query with every search analyzer of the fields of the index since the
+// query may be looking for any of them. Identical results are only returned once.
+func (index *Index) analyzeQuery(s string) (analyses [][][]string) {
+	seen := MakeStringSet([]string{})
+
+	for _, name := range index.Analysis.searchAnalyzerNames() {
+		groups := index.analyzeGroupsWith(name, s)
+
+		key := fmt.Sprintf("%q", groups)
+		if seen.Contains(key) {
+			continue
+		}
+		seen.Add(key)
+
+		debug("  Analyzed", s, "with", name, "into", groups)
+		analyses = append(analyses, groups)
+	}
+	return
+}
@@ -0,0 +1,155 @@
+package folder
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var keepStopWordsAnalyzer = AnalyzerConfig{
+	Tokenizer:    ComponentConfig{Type: "separator", Params: []string{" |"}},
+	TokenFilters: []ComponentConfig{{Type: "lowercase"}},
+}
+
+func TestAnalyzerAnalyze(t *testing.T) {
+	analyzer, err := NewAnalyzer(keepStopWordsAnalyzer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Token{
+		{Term: "the", Type: TokenTypeWord, Position: 0, Start: 0, End: 3},
+		{Term: "who", Type: TokenTypeWord, Position: 1, Start: 4, End: 7},
+		{Term: "rock", Type: TokenTypeWord, Position: 2, Start: 8, End: 12},
+	}
+	assert.Equal(t, expected, analyzer.Analyze("The Who|Rock"))
+
+	_, err = NewAnalyzer(AnalyzerConfig{Tokenizer: ComponentConfig{Type: "nonexistent"}})
+	assert.True(t, errors.Is(err, ErrUnknownTokenizer))
+}
+
+func TestFieldAnalyzer(t *testing.T) {
+	index := New()
+	err := index.AddAnalyzer("keep_stop_words", keepStopWordsAnalyzer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = index.SetFieldAnalyzer("band", "keep_stop_words")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = index.SetFieldAnalyzer("title", "nonexistent")
+	assert.True(t, errors.Is(err, ErrUnknownAnalyzer))
+
+	index.IndexWithID(map[string]interface{}{"band": "The The", "title": "Mind Bomb"}, "1")
+	index.IndexWithID(map[string]interface{}{"band": "The Who", "title": "The Who Sell Out"}, "2")
+
+	assert.Equal(t, []string{"the", "the"}, index.AnalyzeField("band", "The The"))
+	assert.Equal(t, []string{"mind", "bomb"}, index.AnalyzeField("title", "Mind Bomb"))
+
+	res, _ := index.Search("the the")
+	assert.Equal(t, 2, res.Count)
+
+	res, _ = index.Search("mind")
+	assert.Equal(t, 1, res.Count)
+	assert.Equal(t, "1", res.Hits[0].ID)
+}
+
+func TestConcurrentSearches(t *testing.T) {
+	index := New()
+	err := index.SetFieldAnalyzer("title", "cjk")
+	assert.Nil(t, err)
+	_, err = index.IndexWithID(map[string]interface{}{"title": "東京タワー", "text": "Tokyo Tower"}, "1")
+	assert.Nil(t, err)
+
+	// Analyzers are built by the first searches that need them
+	index.analyzers = newAnalyzerCache()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := index.Search("東京 tower")
+			assert.Nil(t, err)
+			assert.Equal(t, 1, res.Count)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestSaveAndLoadAnalysis(t *testing.T) {
+	dir, err := os.MkdirTemp("", "folder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	index := New()
+	index.AddAnalyzer("keep_stop_words", keepStopWordsAnalyzer)
+	index.SetFieldAnalyzer("band", "keep_stop_words")
+	index.IndexWithID(map[string]interface{}{"band": "The The"}, "1")
+
+	err = index.SaveToShards(dir+"/index", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loadedIndex, err := LoadDeferred(dir + "/index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, index.Analysis, loadedIndex.Analysis)
+
+	res, err := loadedIndex.Search("the")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, res.Count)
+
+	// Indexes saved before analyzers were configurable use the legacy analyzer
+	err = os.Remove(dir + "/index/" + AnalysisFileExtension)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loadedIndex, err = LoadDeferred(dir + "/index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, LegacyAnalyzer, loadedIndex.Analysis.DefaultAnalyzer)
+	assert.Equal(t, 0, len(loadedIndex.Analysis.FieldAnalyzers))
+}
+
+func TestStopTokenFilter(t *testing.T) {
+	index := New()
+	err := index.AddAnalyzer("indonesian", AnalyzerConfig{
+		Tokenizer: ComponentConfig{Type: "unicode"},
+		TokenFilters: []ComponentConfig{
+			{Type: "lowercase"},
+			{Type: "stop", Params: []string{"_indonesian_", "folder"}},
+		},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"mesin", "pencari", "kecil"}, index.analyzeWith("indonesian", "Folder adalah mesin pencari yang kecil"))
+
+	err = index.AddAnalyzer("no_stop_words", AnalyzerConfig{
+		Tokenizer:    ComponentConfig{Type: "unicode"},
+		TokenFilters: []ComponentConfig{{Type: "lowercase"}, {Type: "stop", Params: []string{NoStopWords}}},
+	})
+	assert.Nil(t, err)
+	index.SetFieldAnalyzer("band", "no_stop_words")
+	index.IndexWithID(map[string]interface{}{"band": "The Who", "title": "Who are you"}, "1")
+	index.IndexWithID(map[string]interface{}{"band": "The The", "title": "This is the day"}, "2")
+
+	res, _ := index.Search("the who")
+	assert.Equal(t, 1, res.Count)
+	assert.Equal(t, "1", res.Hits[0].ID)
+
+	err = index.AddAnalyzer("klingon", AnalyzerConfig{
+		Tokenizer:    ComponentConfig{Type: "unicode"},
+		TokenFilters: []ComponentConfig{{Type: "stop", Params: []string{"_klingon_"}}},
+	})
+	assert.True(t, errors.Is(err, ErrUnknownLanguage))
+}
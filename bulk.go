@@ -0,0 +1,229 @@
+package folder
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// BulkActionType is the type of a bulk action.
+type BulkActionType string
+
+const (
+	// BulkIndex indexes a document, replacing the document with the same ID if there is one.
+	BulkIndex BulkActionType = "index"
+	// BulkCreate indexes a document only if there is no document with the same ID yet.
+	BulkCreate BulkActionType = "create"
+	// BulkUpdate patches an existing document with a merge patch, patch operations, or both.
+	BulkUpdate BulkActionType = "update"
+	// BulkDelete deletes a document.
+	BulkDelete BulkActionType = "delete"
+)
+
+// BulkAction is an action of a bulk request. Index and create actions get a generated document ID
+// if they don't have one.
+type BulkAction struct {
+	Type       BulkActionType
+	DocumentID string
+
+	// Version makes the action fail with a version conflict unless the document has this version.
+	// It is ignored if it is 0.
+	Version uint64
+
+	// Document is the document of index and create actions, or the merge patch of update actions.
+	Document map[string]interface{}
+
+	// Operations are the patch operations of update actions, which are applied after the merge
+	// patch.
+	Operations []PatchOperation
+}
+
+// BulkItemStatus is the outcome of a bulk action.
+type BulkItemStatus string
+
+const (
+	// BulkCreated means that a new document was indexed.
+	BulkCreated BulkItemStatus = "created"
+	// BulkUpdated means that an existing document was replaced or patched.
+	BulkUpdated BulkItemStatus = "updated"
+	// BulkDeleted means that a document was deleted.
+	BulkDeleted BulkItemStatus = "deleted"
+	// BulkNotFound means that there was no document to delete.
+	BulkNotFound BulkItemStatus = "not_found"
+	// BulkFailed means that the action failed and the index was left as it was.
+	BulkFailed BulkItemStatus = "failed"
+)
+
+// BulkItem is the result of a bulk action.
+type BulkItem struct {
+	Line       int // Line of the action in NDJSON bulk data, or 0 if the action wasn't read from data
+	Type       BulkActionType
+	DocumentID string
+	Version    uint64 // Version of the document after the action, or 0 if it doesn't exist
+	Status     BulkItemStatus
+	Err        error
+}
+
+// BulkResult contains the results of the actions of a bulk request in the same order as the
+// actions, along with the number of failed actions and the time it took.
+type BulkResult struct {
+	Items  []BulkItem
+	Failed int
+	Time   time.Duration
+}
+
+// Err returns a *BulkError with the failed items, or nil if every action succeeded.
+func (res BulkResult) Err() error {
+	if res.Failed == 0 {
+		return nil
+	}
+
+	failed := make([]BulkItem, 0, res.Failed)
+	for _, item := range res.Items {
+		if item.Status == BulkFailed {
+			failed = append(failed, item)
+		}
+	}
+	return &BulkError{Items: failed}
+}
+
+// BulkError contains the failed items of a bulk request. It unwraps to the error of the first
+// failed item.
+type BulkError struct {
+	Items []BulkItem
+}
+
+func (e *BulkError) Error() string {
+	item := e.Items[0]
+	if item.Line > 0 {
+		return fmt.Sprintf("%d bulk actions failed, first on line %d: %s", len(e.Items), item.Line, item.Err)
+	}
+	return fmt.Sprintf("%d bulk actions failed, first %s %s: %s", len(e.Items), item.Type, item.DocumentID, item.Err)
+}
+
+func (e *BulkError) Unwrap() error {
+	return e.Items[0].Err
+}
+
+// Bulk runs index, create, update, and delete actions in order. An action that fails doesn't stop
+// the others; its error is in its item of the result instead.
+func (index *Index) Bulk(actions []BulkAction) (res BulkResult, err error) {
+	return index.BulkContext(context.Background(), actions)
+}
+
+// BulkContext runs actions just like Bulk but stops when the context is done, in which case the
+// result only has the items of the actions that ran and the error of the context is returned.
+func (index *Index) BulkContext(ctx context.Context, actions []BulkAction) (res BulkResult, err error) {
+	start := time.Now()
+	defer func() {
+		res.Time = time.Since(start)
+	}()
+
+	res.Items = make([]BulkItem, 0, len(actions))
+	for _, action := range actions {
+		err = ctx.Err()
+		if err != nil {
+			return
+		}
+
+		res.add(index.bulk(action))
+	}
+	return
+}
+
+// add appends the result of an action.
+func (res *BulkResult) add(item BulkItem) {
+	if item.Err != nil {
+		item.Status = BulkFailed
+		item.Version = 0
+		res.Failed++
+	}
+	res.Items = append(res.Items, item)
+}
+
+// bulk runs a bulk action and returns its result.
+func (index *Index) bulk(action BulkAction) (item BulkItem) {
+	item = BulkItem{Type: action.Type, DocumentID: action.DocumentID}
+
+	debug("Bulk", action.Type, action.DocumentID)
+
+	switch action.Type {
+	case BulkIndex, BulkCreate:
+		if item.DocumentID == "" {
+			item.DocumentID = index.nextDocumentID()
+		}
+		if action.Type == BulkCreate {
+			item.Err = index.checkVersion(item.DocumentID, 0)
+		}
+	case BulkUpdate, BulkDelete:
+		if item.DocumentID == "" {
+			item.Err = fmt.Errorf("%w: %s action without document ID", ErrInvalidBulkAction, action.Type)
+		}
+	default:
+		item.Err = fmt.Errorf("%w: unknown action %q", ErrInvalidBulkAction, action.Type)
+	}
+	if item.Err == nil && action.Version != 0 {
+		item.Err = index.checkVersion(item.DocumentID, action.Version)
+	}
+	if item.Err != nil {
+		return
+	}
+
+	switch action.Type {
+	case BulkIndex, BulkCreate:
+		// Documents that were deleted are created again with a newer version
+		var version uint64
+		version, item.Err = index.Version(item.DocumentID)
+		if item.Err != nil {
+			return
+		}
+		item.Err = index.Update(item.DocumentID, action.Document)
+		item.Status = BulkUpdated
+		if version == 0 {
+			item.Status = BulkCreated
+		}
+	case BulkUpdate:
+		item.Err = index.bulkUpdate(item.DocumentID, action)
+		item.Status = BulkUpdated
+	case BulkDelete:
+		// Deleting a missing document is not an error but is reported
+		item.Version, item.Err = index.Version(item.DocumentID)
+		if item.Err != nil || item.Version == 0 {
+			item.Status = BulkNotFound
+			return
+		}
+		item.Err = index.Delete(item.DocumentID)
+		item.Status = BulkDeleted
+		item.Version = 0
+		return
+	}
+	if item.Err != nil {
+		return
+	}
+
+	item.Version = index.Versions[item.DocumentID]
+	return
+}
+
+// bulkUpdate patches a document with the merge patch and the patch operations of an update action.
+func (index *Index) bulkUpdate(documentID string, action BulkAction) (err error) {
+	if action.Document == nil && len(action.Operations) == 0 {
+		return fmt.Errorf("%w: update action without a patch", ErrInvalidBulkAction)
+	}
+
+	return index.patch(documentID, func(document map[string]interface{}) (patched map[string]interface{}, fields []string, err error) {
+		patched = document
+		if action.Document != nil {
+			patched = mergePatch(document, action.Document).(map[string]interface{})
+			fields = mergePatchFields("", document, action.Document)
+		}
+		for _, operation := range action.Operations {
+			patched, err = applyPatchOperation(patched, operation)
+			if err != nil {
+				return
+			}
+			fields = append(fields, operation.Field)
+		}
+		return
+	})
+}
@@ -0,0 +1,158 @@
+package folder
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBulk(t *testing.T) {
+	index := New()
+	index.IndexWithID(map[string]interface{}{"title": "Folder", "views": 1}, "1")
+
+	res, err := index.Bulk([]BulkAction{
+		{Type: BulkIndex, DocumentID: "2", Document: map[string]interface{}{"title": "Search engine"}},
+		{Type: BulkCreate, DocumentID: "1", Document: map[string]interface{}{"title": "Duplicate"}},
+		{Type: BulkUpdate, DocumentID: "1", Version: 1, Operations: []PatchOperation{{Type: PatchIncrement, Field: "views", Value: 1}}},
+		{Type: BulkUpdate, DocumentID: "1", Version: 1, Document: map[string]interface{}{"title": "Stale"}},
+		{Type: BulkDelete, DocumentID: "3"},
+		{Type: BulkIndex, Document: map[string]interface{}{"title": "Generated"}},
+		{Type: BulkDelete, DocumentID: "2"},
+		{Type: BulkUpdate},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, res.Failed)
+
+	statuses := []BulkItemStatus{BulkCreated, BulkFailed, BulkUpdated, BulkFailed, BulkNotFound, BulkCreated, BulkDeleted, BulkFailed}
+	for i, item := range res.Items {
+		assert.Equal(t, statuses[i], item.Status, i)
+	}
+	assert.Equal(t, uint64(1), res.Items[0].Version)
+	assert.Equal(t, uint64(2), res.Items[2].Version)
+	assert.True(t, errors.Is(res.Items[1].Err, ErrVersionConflict))
+	assert.True(t, errors.Is(res.Items[3].Err, ErrVersionConflict))
+	assert.True(t, errors.Is(res.Items[7].Err, ErrInvalidBulkAction))
+	assert.NotEqual(t, "", res.Items[5].DocumentID)
+
+	document, _ := index.Fetch("1")
+	assert.Equal(t, map[string]interface{}{"title": "Folder", "views": 2}, document)
+	r, _ := index.Search("generated")
+	assert.Equal(t, 1, r.Count)
+	r, _ = index.Search("engine")
+	assert.Equal(t, 0, r.Count)
+
+	err = res.Err()
+	var bulkErr *BulkError
+	assert.True(t, errors.As(err, &bulkErr))
+	assert.Equal(t, 3, len(bulkErr.Items))
+	assert.True(t, errors.Is(err, ErrVersionConflict))
+
+	// Deleted documents are created again with a newer version
+	res, err = index.Bulk([]BulkAction{{Type: BulkCreate, DocumentID: "2", Document: map[string]interface{}{"title": "Search engine"}}})
+	assert.Nil(t, err)
+	assert.Equal(t, BulkCreated, res.Items[0].Status)
+	assert.Equal(t, uint64(2), res.Items[0].Version)
+}
+
+func TestBulkData(t *testing.T) {
+	dir, err := os.MkdirTemp("", "folder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	index := New()
+	index.IndexWithID(map[string]interface{}{"title": "Folder", "views": 1.0}, "1")
+	err = index.SaveToShards(dir+"/index", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := `{"index": {"_id": "2"}}
+{"title": "Search engine"}
+{"update": {"_id": "1", "version": 1}}
+{"doc": {"tags": ["search"]}, "operations": [{"type": "increment", "field": "views", "value": 1}]}
+
+{"create": {"_id": "1"}}
+{"title": "Duplicate"}
+not json
+{"delete": {"_id": 2}}
+{"update": {"_id": "3"}}
+{"doc": {"title": "Missing"}}
+{"delete": {"_id": "2"}}
+{"index": {}}
+`
+	err = os.WriteFile(dir+"/bulk.ndjson", []byte(data), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loadedIndex, err := LoadDeferred(dir + "/index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := loadedIndex.BulkFilePath(dir + "/bulk.ndjson")
+	assert.Nil(t, err)
+
+	type result struct {
+		Line       int
+		Type       BulkActionType
+		DocumentID string
+		Version    uint64
+		Status     BulkItemStatus
+	}
+	var results []result
+	for _, item := range res.Items {
+		results = append(results, result{item.Line, item.Type, item.DocumentID, item.Version, item.Status})
+	}
+	assert.Equal(t, []result{
+		{1, BulkIndex, "2", 1, BulkCreated},
+		{3, BulkUpdate, "1", 2, BulkUpdated},
+		{6, BulkCreate, "1", 0, BulkFailed},
+		{8, "", "", 0, BulkFailed},
+		{9, BulkDelete, "", 0, BulkFailed},
+		{10, BulkUpdate, "3", 0, BulkFailed},
+		{12, BulkDelete, "2", 0, BulkDeleted},
+		{13, BulkIndex, "", 0, BulkFailed},
+	}, results)
+	assert.True(t, errors.Is(res.Items[5].Err, ErrDocumentNotFound))
+	assert.True(t, errors.Is(res.Items[7].Err, ErrInvalidBulkAction))
+
+	document, _ := loadedIndex.Fetch("1")
+	assert.Equal(t, map[string]interface{}{"title": "Folder", "views": 2.0, "tags": []interface{}{"search"}}, document)
+	r, _ := loadedIndex.Search("search")
+	assert.Equal(t, 1, r.Count)
+	assert.Equal(t, "1", r.Hits[0].ID)
+}
+
+func TestIndexDataJSONL(t *testing.T) {
+	index := New()
+
+	err := index.IndexDataWithIDField([]byte(`{"id": "1", "title": "Folder"}
+{"id": "2", "title": broken}
+{"title": "No ID"}
+{"id": "3", "title": "Search engine"}
+`), "jsonl", "id")
+	var bulkErr *BulkError
+	assert.True(t, errors.As(err, &bulkErr))
+	assert.Equal(t, 2, bulkErr.Items[0].Line)
+	assert.Equal(t, 3, bulkErr.Items[1].Line)
+	assert.True(t, errors.Is(bulkErr.Items[1].Err, ErrDocumentMissingIDField))
+
+	// The valid lines are indexed even though other lines are not
+	assert.Equal(t, 2, len(index.Documents))
+	res, _ := index.Search("engine")
+	assert.Equal(t, 1, res.Count)
+
+	err = index.IndexData([]byte(`{"title": "Another"}`+"\n"), "jsonl")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(index.Documents))
+
+	err = index.IndexData([]byte(`title: Another`), "yaml")
+	assert.ErrorIs(t, err, ErrUnsupportedDocumentDataType)
+	err = index.IndexDataWithIDField([]byte(`{"id": "4", "title": "Yet another"}`), "json", "id")
+	assert.Nil(t, err)
+	assert.Equal(t, "Yet another", index.Documents["4"]["title"])
+}
@@ -0,0 +1,501 @@
+package folder
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	// htmlBlockTags are the tags that separate the text around them. Other tags such as <b> are
+	// removed without separating anything so that "wo<b>rd</b>" stays a single word.
+	htmlBlockTags = MakeStringSet([]string{
+		"address", "article", "aside", "blockquote", "br", "dd", "div", "dl", "dt", "figcaption",
+		"figure", "footer", "form", "h1", "h2", "h3", "h4", "h5", "h6", "header", "hr", "li", "main",
+		"nav", "ol", "option", "p", "pre", "section", "table", "td", "th", "title", "tr", "ul",
+	})
+	// htmlRawTextTags are the tags whose content is not text and is removed along with them.
+	htmlRawTextTags = MakeStringSet([]string{"script", "style"})
+)
+
+// OffsetCharFilter is a char filter that can tell where each part of its output came from so that
+// the offsets of the tokens can refer to the text given to the analyzer, e.g. for highlighting.
+type OffsetCharFilter interface {
+	CharFilter
+	FilterWithOffsets(s string) (filtered string, mapping OffsetMapping)
+}
+
+// OffsetMapping maps the byte offsets of the text produced by a char filter to the text it was
+// given. Each byte of the produced text comes from a span of the given text, which may be empty if
+// the byte was inserted.
+type OffsetMapping struct {
+	Starts []int // Offset of the start of the span of each byte
+	Ends   []int // Offset of the end of the span of each byte
+	Length int   // Length of the given text
+}
+
+// identityOffsetMapping returns the mapping of a char filter that changes text of a certain length
+// into text of another length without telling what changed. Offsets are kept as they are as long as
+// they are within the given text.
+func identityOffsetMapping(length, filteredLength int) (mapping OffsetMapping) {
+	mapping.Length = length
+	for i := 0; i < filteredLength; i++ {
+		start, end := i, i+1
+		if end > length {
+			start, end = length, length
+		}
+		mapping.Starts = append(mapping.Starts, start)
+		mapping.Ends = append(mapping.Ends, end)
+	}
+	return
+}
+
+// Span returns the span of the given text that produced the text between two offsets.
+func (mapping *OffsetMapping) Span(start, end int) (originalStart, originalEnd int) {
+	originalStart = mapping.Length
+	if start < len(mapping.Starts) {
+		originalStart = mapping.Starts[start]
+	}
+	if end <= start {
+		return originalStart, originalStart
+	}
+
+	originalEnd = mapping.Length
+	if end <= len(mapping.Ends) {
+		originalEnd = mapping.Ends[end-1]
+	}
+	return
+}
+
+// then returns a mapping from the output of the char filter that comes after this one to the text
+// given to this one.
+func (mapping *OffsetMapping) then(next OffsetMapping) (composed OffsetMapping) {
+	composed.Length = mapping.Length
+	for i := range next.Starts {
+		start, end := mapping.Span(next.Starts[i], next.Ends[i])
+		composed.Starts = append(composed.Starts, start)
+		composed.Ends = append(composed.Ends, end)
+	}
+	return
+}
+
+// offsetBuilder builds the output of a char filter along with its offset mapping.
+type offsetBuilder struct {
+	b       strings.Builder
+	mapping OffsetMapping
+}
+
+// write appends text that replaces the span of the given text between start and end.
+func (ob *offsetBuilder) write(s string, start, end int) {
+	ob.b.WriteString(s)
+	for i := 0; i < len(s); i++ {
+		ob.mapping.Starts = append(ob.mapping.Starts, start)
+		ob.mapping.Ends = append(ob.mapping.Ends, end)
+	}
+}
+
+// copy appends the given text between start and end as it is.
+func (ob *offsetBuilder) copy(s string, start, end int) {
+	ob.b.WriteString(s[start:end])
+	for i := start; i < end; i++ {
+		ob.mapping.Starts = append(ob.mapping.Starts, i)
+		ob.mapping.Ends = append(ob.mapping.Ends, i+1)
+	}
+}
+
+// result returns the built text and its mapping to the given text of a certain length.
+func (ob *offsetBuilder) result(length int) (string, OffsetMapping) {
+	ob.mapping.Length = length
+	return ob.b.String(), ob.mapping
+}
+
+// HTMLStripCharFilter removes HTML tags and comments along with the content of scripts and styles,
+// and decodes character references such as "&amp;". Block-level tags such as <p> and <br> are
+// replaced by a newline so that the text around them is not joined together.
+type HTMLStripCharFilter struct{}
+
+func newHTMLStripCharFilter(params []string) (CharFilter, error) {
+	return &HTMLStripCharFilter{}, nil
+}
+
+// Filter strips HTML from text.
+func (filter *HTMLStripCharFilter) Filter(s string) string {
+	filtered, _ := filter.FilterWithOffsets(s)
+	return filtered
+}
+
+// FilterWithOffsets strips HTML from text and maps the offsets of the stripped text to the text.
+func (filter *HTMLStripCharFilter) FilterWithOffsets(s string) (filtered string, mapping OffsetMapping) {
+	var ob offsetBuilder
+
+	for i := 0; i < len(s); {
+		switch s[i] {
+		case '<':
+			if strings.HasPrefix(s[i:], "<!--") {
+				end := strings.Index(s[i+4:], "-->")
+				if end < 0 {
+					end = len(s)
+				} else {
+					end += i + 4 + 3
+				}
+				i = end
+				continue
+			}
+
+			name, closing, end := parseHTMLTag(s, i)
+			if end < 0 {
+				ob.copy(s, i, i+1)
+				i++
+				continue
+			}
+
+			if !closing && htmlRawTextTags.Contains(name) {
+				end = htmlRawTextEnd(s, end, name)
+			}
+			if htmlBlockTags.Contains(name) {
+				ob.write("\n", i, end)
+			}
+			i = end
+		case '&':
+			end := strings.IndexByte(s[i:], ';')
+			if end > 1 && end <= 32 {
+				reference := s[i : i+end+1]
+				// Legacy references such as "&not" are decoded without a semicolon, which would turn
+				// "&notanentity;" into "¬anentity;"
+				decoded := html.UnescapeString(reference)
+				if decoded != reference && (!strings.HasSuffix(decoded, ";") || decoded == ";") {
+					ob.write(decoded, i, i+end+1)
+					i += end + 1
+					continue
+				}
+			}
+			ob.copy(s, i, i+1)
+			i++
+		default:
+			end := strings.IndexAny(s[i:], "<&")
+			if end < 0 {
+				end = len(s)
+			} else {
+				end += i
+			}
+			ob.copy(s, i, end)
+			i = end
+		}
+	}
+
+	return ob.result(len(s))
+}
+
+// parseHTMLTag parses the tag starting at an offset and returns its lowercased name, whether it is
+// a closing tag, and the offset after it. The offset is -1 if there is no tag at the offset, e.g.
+// in "1 < 2". Declarations such as <!DOCTYPE html> are tags without a name.
+func parseHTMLTag(s string, start int) (name string, closing bool, end int) {
+	i := start + 1
+	if i < len(s) && s[i] == '/' {
+		closing = true
+		i++
+	}
+
+	nameStart := i
+	for i < len(s) && (isASCIILetter(s[i]) || (i > nameStart && s[i] >= '0' && s[i] <= '9')) {
+		i++
+	}
+	name = strings.ToLower(s[nameStart:i])
+	if name == "" && (closing || i >= len(s) || (s[i] != '!' && s[i] != '?')) {
+		return "", false, -1
+	}
+
+	var quote byte
+	for ; i < len(s); i++ {
+		switch {
+		case quote != 0:
+			if s[i] == quote {
+				quote = 0
+			}
+		case s[i] == '"' || s[i] == '\'':
+			quote = s[i]
+		case s[i] == '>':
+			return name, closing, i + 1
+		case s[i] == '<':
+			return "", false, -1
+		}
+	}
+	return "", false, -1
+}
+
+// htmlRawTextEnd returns the offset after the closing tag of a script or style whose content starts
+// at an offset, or the length of the text if it is not closed.
+func htmlRawTextEnd(s string, start int, name string) int {
+	for i := start; i < len(s); i++ {
+		if s[i] != '<' {
+			continue
+		}
+		if tagName, closing, end := parseHTMLTag(s, i); closing && tagName == name {
+			return end
+		}
+	}
+	return len(s)
+}
+
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// MarkdownStripCharFilter removes Markdown syntax such as headings, emphasis, list markers, code
+// fences, and the destinations of links and images while keeping their text. HTML within Markdown
+// is kept as it is and can be removed by HTMLStripCharFilter.
+type MarkdownStripCharFilter struct{}
+
+func newMarkdownStripCharFilter(params []string) (CharFilter, error) {
+	return &MarkdownStripCharFilter{}, nil
+}
+
+// Filter strips Markdown from text.
+func (filter *MarkdownStripCharFilter) Filter(s string) string {
+	filtered, _ := filter.FilterWithOffsets(s)
+	return filtered
+}
+
+// FilterWithOffsets strips Markdown from text and maps the offsets of the stripped text to the text.
+func (filter *MarkdownStripCharFilter) FilterWithOffsets(s string) (filtered string, mapping OffsetMapping) {
+	var ob offsetBuilder
+	fence := ""
+
+	for start := 0; start < len(s); {
+		end := strings.IndexByte(s[start:], '\n')
+		if end < 0 {
+			end = len(s)
+		} else {
+			end += start
+		}
+		line := strings.TrimSpace(s[start:end])
+
+		switch {
+		case fence != "":
+			// Code is kept as it is but the fence lines are removed
+			if strings.HasPrefix(line, fence) && strings.Trim(line, fence[:1]) == "" {
+				fence = ""
+			} else {
+				ob.copy(s, start, end)
+			}
+		case strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~"):
+			fence = line[:3]
+		case isMarkdownRule(line) || isMarkdownReferenceDefinition(line):
+		default:
+			contentStart, contentEnd := markdownContent(s, start, end)
+			stripMarkdownInline(&ob, s, contentStart, contentEnd)
+		}
+
+		if end < len(s) {
+			ob.copy(s, end, end+1)
+		}
+		start = end + 1
+	}
+
+	return ob.result(len(s))
+}
+
+// isMarkdownRule returns whether a trimmed line is a thematic break such as "---" or a setext
+// heading underline such as "===".
+func isMarkdownRule(line string) bool {
+	if len(line) < 3 || !strings.ContainsRune("-*_=", rune(line[0])) {
+		return false
+	}
+	return strings.Trim(line, string(line[0])+" ") == ""
+}
+
+// isMarkdownReferenceDefinition returns whether a trimmed line defines a link destination, e.g.
+// "[folder]: https://github.com/veeableful/folder".
+func isMarkdownReferenceDefinition(line string) bool {
+	if !strings.HasPrefix(line, "[") {
+		return false
+	}
+	end := strings.Index(line, "]:")
+	return end > 1 && !strings.Contains(line[:end], "](")
+}
+
+// markdownContent returns the offsets of the content of a line without its block quote markers,
+// heading markers, list marker, and task list checkbox.
+func markdownContent(s string, start, end int) (contentStart, contentEnd int) {
+	skipSpaces := func(i int) int {
+		for i < end && (s[i] == ' ' || s[i] == '\t') {
+			i++
+		}
+		return i
+	}
+
+	i := skipSpaces(start)
+	for i < end && s[i] == '>' {
+		i = skipSpaces(i + 1)
+	}
+
+	if j := i; j < end && s[j] == '#' {
+		for j < end && s[j] == '#' {
+			j++
+		}
+		if j-i <= 6 && (j == end || s[j] == ' ') {
+			// The closing sequence of an ATX heading is optional
+			trimmed := strings.TrimRight(s[j:end], " \t")
+			if closing := strings.TrimRight(trimmed, "#"); len(closing) < len(trimmed) && strings.HasSuffix(closing, " ") {
+				trimmed = closing
+			}
+			return skipSpaces(j), j + len(trimmed)
+		}
+	}
+
+	j := i
+	if j < end && strings.ContainsRune("-*+", rune(s[j])) {
+		j++
+	} else {
+		for j < end && s[j] >= '0' && s[j] <= '9' {
+			j++
+		}
+		if j == i || j == end || (s[j] != '.' && s[j] != ')') {
+			return i, end
+		}
+		j++
+	}
+	if j < end && s[j] != ' ' {
+		return i, end
+	}
+	i = skipSpaces(j)
+
+	if i+3 <= end && (s[i:i+3] == "[ ]" || s[i:i+3] == "[x]" || s[i:i+3] == "[X]") {
+		i = skipSpaces(i + 3)
+	}
+	return i, end
+}
+
+// stripMarkdownInline writes the text between two offsets without emphasis, code span backticks,
+// escapes, and link destinations.
+func stripMarkdownInline(ob *offsetBuilder, s string, start, end int) {
+	for i := start; i < end; {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < end && strings.ContainsRune("\\`*_{}[]()#+-.!~<>|", rune(s[i+1])):
+			ob.write(s[i+1:i+2], i, i+2)
+			i += 2
+		case c == '`':
+			ticks := i
+			for ticks < end && s[ticks] == '`' {
+				ticks++
+			}
+			closing := strings.Index(s[ticks:end], s[i:ticks])
+			if closing < 0 {
+				ob.copy(s, i, ticks)
+				i = ticks
+				continue
+			}
+			ob.copy(s, ticks, ticks+closing)
+			i = ticks + closing + ticks - i
+		case c == '!' && i+1 < end && s[i+1] == '[':
+			if next, ok := stripMarkdownLink(ob, s, i+1, end); ok {
+				i = next
+				continue
+			}
+			ob.copy(s, i, i+1)
+			i++
+		case c == '[':
+			if next, ok := stripMarkdownLink(ob, s, i, end); ok {
+				i = next
+				continue
+			}
+			ob.copy(s, i, i+1)
+			i++
+		case c == '<' && strings.HasPrefix(s[i:end], "<http"):
+			closing := strings.IndexByte(s[i:end], '>')
+			if closing < 0 {
+				ob.copy(s, i, i+1)
+				i++
+				continue
+			}
+			ob.copy(s, i+1, i+closing)
+			i += closing + 1
+		case c == '*' || c == '_' || c == '~':
+			run := i
+			for run < end && s[run] == c {
+				run++
+			}
+			if !isMarkdownDelimiter(s, i, run, start, end) {
+				ob.copy(s, i, run)
+			}
+			i = run
+		default:
+			ob.copy(s, i, i+1)
+			i++
+		}
+	}
+}
+
+// stripMarkdownLink writes the text of a link or image whose text starts with the bracket at an
+// offset, and returns the offset after the link.
+func stripMarkdownLink(ob *offsetBuilder, s string, start, end int) (next int, ok bool) {
+	textEnd := matchingBracket(s, start, end, '[', ']')
+	if textEnd < 0 {
+		return
+	}
+
+	next = textEnd + 1
+	if next < end && s[next] == '(' {
+		destinationEnd := matchingBracket(s, next, end, '(', ')')
+		if destinationEnd < 0 {
+			return
+		}
+		next = destinationEnd + 1
+	} else if next < end && s[next] == '[' {
+		referenceEnd := matchingBracket(s, next, end, '[', ']')
+		if referenceEnd < 0 {
+			return
+		}
+		next = referenceEnd + 1
+	}
+
+	stripMarkdownInline(ob, s, start+1, textEnd)
+	return next, true
+}
+
+// matchingBracket returns the offset of the bracket that closes the one at an offset, or -1 if it
+// is not closed before the end.
+func matchingBracket(s string, start, end int, open, close byte) int {
+	depth := 0
+	for i := start; i < end; i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case open:
+			depth++
+		case close:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// isMarkdownDelimiter returns whether the run of emphasis characters between two offsets is
+// emphasis rather than text, e.g. the asterisk in "2 * 3" and the underscores in "snake_case" are
+// text.
+func isMarkdownDelimiter(s string, runStart, runEnd, start, end int) bool {
+	var before, after rune = ' ', ' '
+	if runStart > start {
+		before, _ = utf8.DecodeLastRuneInString(s[start:runStart])
+	}
+	if runEnd < end {
+		after, _ = utf8.DecodeRuneInString(s[runEnd:end])
+	}
+
+	if unicode.IsSpace(before) && unicode.IsSpace(after) {
+		return false
+	}
+	if s[runStart] == '_' && isWordRune(before) && isWordRune(after) {
+		return false
+	}
+	return true
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
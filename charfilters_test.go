@@ -0,0 +1,53 @@
+package folder
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTMLStripCharFilter(t *testing.T) {
+	filter := &HTMLStripCharFilter{}
+
+	assert.Equal(t, "\n\nCafé & Bar\n\nword 1 < 2\nnext\n\n", filter.Filter(
+		`<div class="post"><h1>Caf&eacute; &amp; Bar</h1><p>wo<b>rd</b> 1 < 2<br/>next</p>`+
+			`<script>var p = "<p>";</script><!-- hidden --></div>`))
+	assert.Equal(t, "link &notanentity; 'q'", filter.Filter(`<!DOCTYPE html><a href='x>y'>link</a> &notanentity; &#39;q&#x27;`))
+}
+
+func TestMarkdownStripCharFilter(t *testing.T) {
+	filter := &MarkdownStripCharFilter{}
+
+	assert.Equal(t, "Title \nBold and italic with snake_case and 2 * 3", filter.Filter("# Title #\n> **Bold** and _italic_ with snake_case and 2 * 3"))
+	assert.Equal(t, "Task link alt img\ncode_span *esc*", filter.Filter("- [x] Task [link](http://example.com \"t\") ![alt *img*](a.png)\n1. `code_span` \\*esc\\*"))
+	assert.Equal(t, "\nfunc main() {}\n\n\n\nSee text and https://go.dev.\nstrike", filter.Filter("```go\nfunc main() {}\n```\n---\n[ref]: http://x\nSee [text][ref] and <https://go.dev>.\n~~strike~~"))
+}
+
+func TestCharFilterOffsets(t *testing.T) {
+	index := New()
+	err := index.AddAnalyzer("markup", AnalyzerConfig{
+		CharFilters: []ComponentConfig{{Type: "html_strip"}, {Type: "markdown_strip"}},
+		Tokenizer:   ComponentConfig{Type: "unicode"},
+	})
+	assert.Nil(t, err)
+
+	analyzer, err := index.Analyzer("markup")
+	assert.Nil(t, err)
+
+	s := `<p class="intro">Caf&eacute; **bold** [site](http://example.com)</p>`
+	var originals []string
+	for _, token := range analyzer.Analyze(s) {
+		originals = append(originals, s[token.Start:token.End])
+	}
+	assert.Equal(t, []string{"Caf&eacute;", "bold", "site"}, originals)
+	assert.Equal(t, []string{"Café", "bold", "site"}, index.analyzeWith("markup", s))
+
+	// Char filters that don't map offsets keep them as they are
+	analyzer = &Analyzer{
+		CharFilters: []CharFilter{CharFilterFunc(func(s string) string { return s + "!" })},
+		Tokenizer:   &SeparatorTokenizer{Separators: " "},
+	}
+	tokens := analyzer.Analyze("a b")
+	assert.Equal(t, 2, tokens[1].Start)
+	assert.Equal(t, 3, tokens[1].End)
+}
@@ -3,6 +3,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,7 +12,9 @@ import (
 	"path"
 	"path/filepath"
 	"plugin"
+	"strings"
 	"syscall"
+	"text/tabwriter"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
@@ -48,6 +51,11 @@ func doIndex(c *cli.Context) (err error) {
 		}
 	}
 
+	err = setAnalyzers(index, c.StringSlice("analyzer"))
+	if err != nil {
+		return
+	}
+
 	if pluginName != "" {
 		var p *plugin.Plugin
 		var sym plugin.Symbol
@@ -70,14 +78,8 @@ func doIndex(c *cli.Context) (err error) {
 
 		if idField != "" {
 			err = index.IndexDataWithIDField(data, dataType, idField)
-			if err != nil {
-				return
-			}
 		} else {
 			err = index.IndexData(data, dataType)
-			if err != nil {
-				return
-			}
 		}
 	} else {
 		if info.IsDir() {
@@ -95,9 +97,13 @@ func doIndex(c *cli.Context) (err error) {
 				err = index.IndexFilePath(filePath, dataType)
 			}
 		}
-		if err != nil {
-			return err
-		}
+	}
+
+	// The documents of the lines of JSONL data that could be indexed are saved even if others failed
+	var bulkErr *folder.BulkError
+	indexErr := err
+	if err != nil && !errors.As(err, &bulkErr) {
+		return
 	}
 
 	err = index.SaveToShards(indexName, c.Int("shards"))
@@ -105,6 +111,24 @@ func doIndex(c *cli.Context) (err error) {
 		return
 	}
 
+	err = indexErr
+	return
+}
+
+// setAnalyzers sets the analyzers of an index from values in the form of [field]=[analyzer], or just
+// [analyzer] for the default analyzer.
+func setAnalyzers(index *folder.Index, values []string) (err error) {
+	for _, value := range values {
+		i := strings.LastIndex(value, "=")
+		if i < 0 {
+			err = index.SetDefaultAnalyzer(value)
+		} else {
+			err = index.SetFieldAnalyzer(value[:i], value[i+1:])
+		}
+		if err != nil {
+			return
+		}
+	}
 	return
 }
 
@@ -113,8 +137,16 @@ func doSearch(c *cli.Context) error {
 	format := c.String("format")
 	size := c.Int("size")
 	from := c.Int("from")
+	timeout := c.Duration("timeout")
 
-	index, err := folder.LoadDeferred(indexName)
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	index, err := folder.LoadDeferredContext(ctx, indexName)
 	if err != nil {
 		return err
 	}
@@ -123,11 +155,11 @@ func doSearch(c *cli.Context) error {
 	opts := folder.DefaultSearchOptions
 	opts.From = from
 	opts.Size = size
-	result, err := index.SearchWithOptions(s, opts)
+	result, err := index.SearchContext(ctx, s, opts)
 	if err != nil {
 		log.Fatal(err)
 	}
-	result, err = index.SearchWithOptions(s, opts)
+	result, err = index.SearchContext(ctx, s, opts)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -165,6 +197,172 @@ func doFetch(c *cli.Context) error {
 	return nil
 }
 
+func doAnalyze(c *cli.Context) (err error) {
+	indexName := c.String("index")
+	analyzerName := c.String("analyzer")
+	field := c.String("field")
+	format := c.String("format")
+
+	if c.NArg() <= 0 {
+		err = errors.New("please specify the text to analyze")
+		return
+	}
+
+	// Built-in analyzers can be used without an index
+	index, err := folder.LoadDeferred(indexName)
+	if errors.Is(err, fs.ErrNotExist) {
+		index, err = folder.New(), nil
+	}
+	if err != nil {
+		return
+	}
+
+	var explanation folder.AnalysisExplanation
+	if analyzerName != "" {
+		explanation, err = index.ExplainAnalysis(analyzerName, c.Args().First())
+	} else {
+		explanation, err = index.ExplainFieldAnalysis(field, c.Args().First())
+	}
+	if err != nil {
+		return
+	}
+
+	if format == "go" {
+		fmt.Printf("%+v\n", explanation)
+	} else if format == "json" {
+		data, _ := json.Marshal(explanation)
+		fmt.Printf("%s\n", string(data))
+	} else {
+		printExplanation(explanation)
+	}
+	return
+}
+
+func doMigrate(c *cli.Context) error {
+	indexName := c.String("index")
+	return folder.Migrate(indexName)
+}
+
+func doBulk(c *cli.Context) (err error) {
+	indexName := c.String("index")
+	format := c.String("format")
+
+	if c.NArg() <= 0 {
+		err = errors.New("please specify the bulk file path")
+		return
+	}
+
+	// Every shard is loaded so that saving the index keeps the documents that the actions don't touch
+	index, err := folder.LoadDeferred(indexName)
+	if errors.Is(err, fs.ErrNotExist) {
+		index, err = folder.New(), nil
+	} else if err == nil {
+		err = index.LoadAllShards(func(loadedShardsCount, totalShardsCount int) {}, 0)
+	}
+	if err != nil {
+		return
+	}
+
+	shardCount := c.Int("shards")
+	if index.ShardCount > 0 && !c.IsSet("shards") {
+		shardCount = index.ShardCount
+	}
+
+	res, err := index.BulkFilePath(c.Args().First())
+	if err != nil {
+		return
+	}
+
+	if format == "go" {
+		fmt.Printf("%+v\n", res)
+	} else if format == "json" {
+		data, _ := json.Marshal(bulkItemsOutput(res.Items))
+		fmt.Printf("%s\n", string(data))
+	} else {
+		printBulkItems(res.Items)
+	}
+
+	// The actions that succeeded are saved even if others failed
+	err = index.SaveToShards(indexName, shardCount)
+	if err != nil {
+		return
+	}
+
+	if res.Failed > 0 {
+		err = fmt.Errorf("%d of %d bulk actions failed", res.Failed, len(res.Items))
+	}
+	return
+}
+
+// bulkItemOutput is a bulk item whose error is a string so that it can be printed as JSON.
+type bulkItemOutput struct {
+	Line       int
+	Type       folder.BulkActionType
+	DocumentID string
+	Version    uint64
+	Status     folder.BulkItemStatus
+	Error      string `json:",omitempty"`
+}
+
+// bulkItemsOutput converts bulk items for JSON output.
+func bulkItemsOutput(items []folder.BulkItem) (output []bulkItemOutput) {
+	output = make([]bulkItemOutput, 0, len(items))
+	for _, item := range items {
+		o := bulkItemOutput{
+			Line:       item.Line,
+			Type:       item.Type,
+			DocumentID: item.DocumentID,
+			Version:    item.Version,
+			Status:     item.Status,
+		}
+		if item.Err != nil {
+			o.Error = item.Err.Error()
+		}
+		output = append(output, o)
+	}
+	return
+}
+
+// printBulkItems prints the results of bulk actions as a table.
+func printBulkItems(items []folder.BulkItem) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "LINE\tACTION\tID\tVERSION\tSTATUS\tERROR")
+	for _, item := range items {
+		var message string
+		if item.Err != nil {
+			message = item.Err.Error()
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%s\t%s\n", item.Line, item.Type, item.DocumentID, item.Version, item.Status, message)
+	}
+	w.Flush()
+}
+
+// printExplanation prints the tokens of an analysis explanation as a table.
+func printExplanation(explanation folder.AnalysisExplanation) {
+	fmt.Printf("Analyzer: %s\n", explanation.Analyzer)
+	if explanation.Language != "" {
+		fmt.Printf("Language: %s\n", explanation.Language)
+	}
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "POS\tSTART\tEND\tTYPE\tTERM\tCHANGES")
+	for _, token := range explanation.Tokens {
+		var changes []string
+		if token.AddedBy != "" {
+			changes = append(changes, "added by "+token.AddedBy)
+		}
+		if len(token.ChangedBy) > 0 {
+			changes = append(changes, "changed from "+token.Original+" by "+strings.Join(token.ChangedBy, ", "))
+		}
+		if token.RemovedBy != "" {
+			changes = append(changes, "removed by "+token.RemovedBy)
+		}
+		fmt.Fprintf(w, "%d\t%d\t%d\t%s\t%s\t%s\n", token.Position, token.Start, token.End, token.Type, token.Term, strings.Join(changes, "; "))
+	}
+	w.Flush()
+}
+
 func main() {
 	app := &cli.App{
 		Name:  "folder",
@@ -200,6 +398,10 @@ func main() {
 						Usage: "Field to be used for document ID",
 						Value: "",
 					},
+					&cli.StringSliceFlag{
+						Name:  "analyzer",
+						Usage: "Analyzer of a field in the form of [field]=[analyzer], or the default analyzer if no field is given",
+					},
 				},
 			},
 			{
@@ -228,6 +430,10 @@ func main() {
 						Usage: "Starting offset of documents",
 						Value: 0,
 					},
+					&cli.DurationFlag{
+						Name:  "timeout",
+						Usage: "Maximum duration of the search, partial results are returned when exceeded",
+					},
 				},
 			},
 			{
@@ -248,6 +454,67 @@ func main() {
 					},
 				},
 			},
+			{
+				Name:    "analyze",
+				Aliases: []string{"a"},
+				Usage:   "Show the tokens that text is broken down into and the filters that changed them",
+				Action:  doAnalyze,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "Format of the analysis output [table, go, json]",
+						Value: "table",
+					},
+					&cli.StringFlag{
+						Name:  "index",
+						Usage: "Name of the index whose analyzers are used",
+						Value: "index",
+					},
+					&cli.StringFlag{
+						Name:  "analyzer",
+						Usage: "Analyzer to use instead of the analyzer of the field",
+					},
+					&cli.StringFlag{
+						Name:  "field",
+						Usage: "Field whose analyzer is used",
+					},
+				},
+			},
+			{
+				Name:    "bulk",
+				Aliases: []string{"b"},
+				Usage:   "Index, update, and delete documents with the actions of an NDJSON file",
+				Action:  doBulk,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "Format of the results output [table, go, json]",
+						Value: "table",
+					},
+					&cli.StringFlag{
+						Name:  "index",
+						Usage: "Name of the index",
+						Value: "index",
+					},
+					&cli.IntFlag{
+						Name:  "shards",
+						Usage: "Number of shards of a new index, or to reshard an existing index into",
+						Value: 1000,
+					},
+				},
+			},
+			{
+				Name:   "migrate",
+				Usage:  "Rewrite an index saved by an older version in the current format",
+				Action: doMigrate,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "index",
+						Usage: "Name of the index",
+						Value: "index",
+					},
+				},
+			},
 		},
 	}
 
@@ -0,0 +1,356 @@
+package folder
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// encodedDocumentColumn is the header of the column of documents files that contains encoded
+// documents. Documents files saved before documents were encoded have a column per field instead,
+// and field names never contain a NUL character.
+const encodedDocumentColumn = "\x00document"
+
+// versionColumn is the header of the column of documents files that contains the versions of the
+// documents. Documents in files without it have version 1.
+const versionColumn = "\x00version"
+
+// unstoredTermsColumn is the header of the column of documents files that contains the terms of the
+// values of the documents that are indexed but not stored, encoded as JSON.
+const unstoredTermsColumn = "\x00unstored"
+
+// typeTagPrefix starts the key of the objects that wrap values whose Go type cannot be told from
+// JSON, e.g. {"\u0000int": 42} for an int.
+const typeTagPrefix = "\x00"
+
+// encodeDocument encodes a document into JSON that decodeDocument turns back into the same document.
+// Values of types other than the ones encoding/json decodes into, such as ints, times, and slices of
+// strings, are wrapped in objects telling their type. Pointers are encoded as the values they point
+// to.
+func encodeDocument(document map[string]interface{}) (s string, err error) {
+	var buffer bytes.Buffer
+
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	err = encoder.Encode(encodeValue(document))
+	if err != nil {
+		return
+	}
+
+	s = strings.TrimSuffix(buffer.String(), "\n")
+	return
+}
+
+func encodeValue(v interface{}) interface{} {
+	tagged := func(tag string, v interface{}) interface{} {
+		return map[string]interface{}{typeTagPrefix + tag: v}
+	}
+
+	switch value := v.(type) {
+	case map[string]interface{}:
+		encoded := make(map[string]interface{}, len(value))
+		for field, v := range value {
+			encoded[field] = encodeValue(v)
+		}
+
+		// Objects that look like tagged values are tagged themselves
+		if len(value) == 1 {
+			for field := range value {
+				if strings.HasPrefix(field, typeTagPrefix) {
+					return tagged("object", encoded)
+				}
+			}
+		}
+		return encoded
+	case []interface{}:
+		encoded := make([]interface{}, len(value))
+		for i, v := range value {
+			encoded[i] = encodeValue(v)
+		}
+		return encoded
+	case []map[string]interface{}:
+		encoded := make([]interface{}, len(value))
+		for i, v := range value {
+			encoded[i] = encodeValue(v)
+		}
+		return tagged("objects", encoded)
+	case *string:
+		if value == nil {
+			return nil
+		}
+		return *value
+	case []string:
+		return tagged("strings", value)
+	case []float64:
+		return tagged("floats", value)
+	case []float32:
+		return tagged("float32s", value)
+	case float32:
+		return tagged("float32", value)
+	case int:
+		return tagged("int", value)
+	case int8:
+		return tagged("int8", value)
+	case int16:
+		return tagged("int16", value)
+	case int32:
+		return tagged("int32", value)
+	case int64:
+		return tagged("int64", value)
+	case uint:
+		return tagged("uint", value)
+	case uint8:
+		return tagged("uint8", value)
+	case uint16:
+		return tagged("uint16", value)
+	case uint32:
+		return tagged("uint32", value)
+	case uint64:
+		return tagged("uint64", value)
+	case json.Number:
+		return tagged("number", value.String())
+	case time.Time:
+		return tagged("time", value.Format(time.RFC3339Nano))
+	case *time.Time:
+		if value == nil {
+			return nil
+		}
+		return tagged("time", value.Format(time.RFC3339Nano))
+	}
+	return v
+}
+
+// decodeDocument decodes a document encoded by encodeDocument.
+func decodeDocument(s string) (document map[string]interface{}, err error) {
+	var v interface{}
+
+	decoder := json.NewDecoder(strings.NewReader(s))
+	decoder.UseNumber()
+	err = decoder.Decode(&v)
+	if err == nil {
+		v, err = decodeValue(v)
+	}
+	if err != nil {
+		if !errors.Is(err, ErrInvalidDocumentRecord) {
+			err = fmt.Errorf("%w: %v", ErrInvalidDocumentRecord, err)
+		}
+		return
+	}
+
+	document, ok := v.(map[string]interface{})
+	if !ok {
+		err = fmt.Errorf("%w: %s", ErrInvalidDocumentRecord, s)
+	}
+	return
+}
+
+func decodeValue(v interface{}) (decoded interface{}, err error) {
+	switch value := v.(type) {
+	case json.Number:
+		return strconv.ParseFloat(value.String(), 64)
+	case []interface{}:
+		values := make([]interface{}, len(value))
+		for i, v := range value {
+			values[i], err = decodeValue(v)
+			if err != nil {
+				return
+			}
+		}
+		return values, nil
+	case map[string]interface{}:
+		if len(value) == 1 {
+			for field, v := range value {
+				if strings.HasPrefix(field, typeTagPrefix) {
+					return decodeTaggedValue(strings.TrimPrefix(field, typeTagPrefix), v)
+				}
+			}
+		}
+
+		values := make(map[string]interface{}, len(value))
+		for field, v := range value {
+			values[field], err = decodeValue(v)
+			if err != nil {
+				return
+			}
+		}
+		return values, nil
+	}
+	return v, nil
+}
+
+func decodeTaggedValue(tag string, v interface{}) (decoded interface{}, err error) {
+	invalid := func() error {
+		return fmt.Errorf("%w: %s value %v", ErrInvalidDocumentRecord, tag, v)
+	}
+
+	number := func(v interface{}) string {
+		n, _ := v.(json.Number)
+		return n.String()
+	}
+	parseInt := func(bitSize int) (i int64) {
+		i, err = strconv.ParseInt(number(v), 10, bitSize)
+		return
+	}
+	parseUint := func(bitSize int) (u uint64) {
+		u, err = strconv.ParseUint(number(v), 10, bitSize)
+		return
+	}
+
+	switch tag {
+	case "object":
+		// The only field of the object looks like a tag and must not be decoded as one
+		fields, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, invalid()
+		}
+
+		object := make(map[string]interface{}, len(fields))
+		for field, v := range fields {
+			object[field], err = decodeValue(v)
+			if err != nil {
+				return
+			}
+		}
+		decoded = object
+	case "objects":
+		values, _ := v.([]interface{})
+		objects := make([]map[string]interface{}, len(values))
+		for i, v := range values {
+			var object interface{}
+			object, err = decodeValue(v)
+			if err != nil {
+				return
+			}
+
+			var ok bool
+			objects[i], ok = object.(map[string]interface{})
+			if !ok {
+				return nil, invalid()
+			}
+		}
+		decoded = objects
+	case "strings":
+		values, _ := v.([]interface{})
+		strs := make([]string, len(values))
+		for i, v := range values {
+			var ok bool
+			strs[i], ok = v.(string)
+			if !ok {
+				return nil, invalid()
+			}
+		}
+		decoded = strs
+	case "floats", "float32s":
+		values, _ := v.([]interface{})
+		floats := make([]float64, len(values))
+		for i, v := range values {
+			floats[i], err = strconv.ParseFloat(number(v), 64)
+			if err != nil {
+				return
+			}
+		}
+		if tag == "floats" {
+			decoded = floats
+			break
+		}
+
+		float32s := make([]float32, len(floats))
+		for i, f := range floats {
+			float32s[i] = float32(f)
+		}
+		decoded = float32s
+	case "float32":
+		var f float64
+		f, err = strconv.ParseFloat(number(v), 32)
+		decoded = float32(f)
+	case "int":
+		decoded = int(parseInt(0))
+	case "int8":
+		decoded = int8(parseInt(8))
+	case "int16":
+		decoded = int16(parseInt(16))
+	case "int32":
+		decoded = int32(parseInt(32))
+	case "int64":
+		decoded = parseInt(64)
+	case "uint":
+		decoded = uint(parseUint(0))
+	case "uint8":
+		decoded = uint8(parseUint(8))
+	case "uint16":
+		decoded = uint16(parseUint(16))
+	case "uint32":
+		decoded = uint32(parseUint(32))
+	case "uint64":
+		decoded = parseUint(64)
+	case "number":
+		s, _ := v.(string)
+		decoded = json.Number(s)
+	case "time":
+		s, _ := v.(string)
+		decoded, err = time.Parse(time.RFC3339Nano, s)
+	default:
+		err = invalid()
+	}
+
+	return
+}
+
+// restoreLegacyValues converts the values of the number, boolean, and vector fields in the mapping
+// of a document read from a documents file saved before documents were encoded, which turned every
+// value into a string and joined arrays with commas. Values of the other fields stay strings since
+// that is how they were indexed.
+func (index *Index) restoreLegacyValues(document map[string]interface{}) {
+	for field, mapping := range index.Mapping.Fields {
+		var convert func(s string) (interface{}, bool)
+
+		switch mapping.Type {
+		case FieldTypeInteger, FieldTypeFloat:
+			convert = func(s string) (interface{}, bool) {
+				return numberValue(s)
+			}
+		case FieldTypeBoolean:
+			convert = func(s string) (interface{}, bool) {
+				return booleanValue(s)
+			}
+		case FieldTypeVector:
+			convert = func(s string) (interface{}, bool) {
+				var vector []float64
+				for _, part := range strings.Split(s, ",") {
+					f, ok := numberValue(part)
+					if !ok {
+						return nil, false
+					}
+					vector = append(vector, f)
+				}
+				return vector, true
+			}
+		default:
+			continue
+		}
+
+		// Legacy documents only contain nested objects and strings
+		var it interface{} = document
+		fields := strings.Split(field, ".")
+		for i, name := range fields {
+			object, ok := it.(map[string]interface{})
+			if !ok {
+				break
+			}
+			if i < len(fields)-1 {
+				it = object[name]
+				continue
+			}
+
+			if s, ok := object[name].(string); ok && s != "" {
+				if value, ok := convert(s); ok {
+					object[name] = value
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package folder
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeDocument(t *testing.T) {
+	createdAt := time.Date(2021, 2, 12, 10, 30, 0, 500, time.FixedZone("WIB", 7*60*60))
+	note := "<b>bold</b>"
+	document := map[string]interface{}{
+		"title":     "Folder",
+		"views":     42,
+		"size":      int64(1) << 60,
+		"rating":    4.5,
+		"ratio":     float32(0.25),
+		"published": true,
+		"missing":   nil,
+		"createdAt": createdAt,
+		"note":      &note,
+		"price":     json.Number("12.50"),
+		"tags":      []string{"search", "engine"},
+		"embedding": []float64{0.1, 0.2, 0.3},
+		"mixed":     []interface{}{1.0, "two", false},
+		"authors":   []map[string]interface{}{{"name": "Lilis", "age": 30.0}},
+		"author":    map[string]interface{}{"name": "Iskandar", "hobbies": []interface{}{"drawing"}},
+		"tagged":    map[string]interface{}{"\x00int": "not a tag"},
+	}
+
+	s, err := encodeDocument(document)
+	assert.Nil(t, err)
+	assert.True(t, strings.Contains(s, "<b>bold</b>"))
+
+	decoded, err := decodeDocument(s)
+	assert.Nil(t, err)
+	assert.True(t, createdAt.Equal(decoded["createdAt"].(time.Time)))
+	delete(decoded, "createdAt")
+	assert.Equal(t, "<b>bold</b>", decoded["note"])
+	delete(decoded, "note")
+	delete(document, "createdAt")
+	delete(document, "note")
+	assert.Equal(t, document, decoded)
+
+	_, err = decodeDocument(`{"views":{"\u0000int":"many"}}`)
+	assert.ErrorIs(t, err, ErrInvalidDocumentRecord)
+	_, err = decodeDocument(`[1, 2]`)
+	assert.ErrorIs(t, err, ErrInvalidDocumentRecord)
+}
+
+func TestSaveAndLoadDocuments(t *testing.T) {
+	dir, err := os.MkdirTemp("", "folder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	document := map[string]interface{}{
+		"title":     "Folder, a search engine",
+		"views":     42,
+		"rating":    4.5,
+		"published": false,
+		"embedding": []interface{}{0.1, 0.2, 0.3},
+		"authors": []interface{}{
+			map[string]interface{}{"name": "Lilis", "hobbies": []interface{}{"drawing", "gaming"}},
+			map[string]interface{}{"name": "Iskandar"},
+		},
+	}
+
+	index := New()
+	index.IndexWithID(document, "1")
+
+	err = index.SaveToShards(dir+"/index", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loadedIndex, err := LoadDeferred(dir + "/index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetched, err := loadedIndex.Fetch("1")
+	assert.Nil(t, err)
+	assert.Equal(t, document, fetched)
+
+	res, _ := loadedIndex.Search("folder")
+	assert.Equal(t, 1, res.Count)
+	assert.Equal(t, document, res.Hits[0].Source)
+
+	err = index.Save(dir + "/single")
+	if err != nil {
+		t.Fatal(err)
+	}
+	loadedIndex, err = Load(dir + "/single")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, document, loadedIndex.Documents["1"])
+}
+
+func TestMigrate(t *testing.T) {
+	dir, err := os.MkdirTemp("", "folder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	index := New()
+	err = index.SetFieldMapping("embedding", FieldMapping{Type: FieldTypeVector})
+	assert.Nil(t, err)
+	index.IndexWithID(map[string]interface{}{
+		"title":     "Folder",
+		"views":     42,
+		"published": true,
+		"embedding": []interface{}{0.1, 0.2},
+	}, "1")
+	err = index.Save(dir + "/index")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Documents files used to have a column per field and string values
+	legacy := "id,title,views,published,embedding,author.name\n1,Folder,42,true,\"0.1,0.2\",Lilis\n"
+	err = os.WriteFile(dir+"/index.dcs", []byte(legacy), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"title":     "Folder",
+		"views":     42.0,
+		"published": true,
+		"embedding": []float64{0.1, 0.2},
+		"author":    map[string]interface{}{"name": "Lilis"},
+	}
+
+	loadedIndex, err := Load(dir + "/index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, expected, loadedIndex.Documents["1"])
+
+	err = Migrate(dir + "/index")
+	assert.Nil(t, err)
+
+	b, err := os.ReadFile(dir + "/index.dcs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, strings.HasPrefix(string(b), "id,\x00document,\x00version,\x00unstored\n"))
+
+	loadedIndex, err = Load(dir + "/index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, expected, loadedIndex.Documents["1"])
+
+	// Values of fields that are not in the mapping stay strings as they were indexed
+	index = New()
+	_, err = index.IndexWithID(map[string]interface{}{"title": "Folder", "year": "2021", "published": "true"}, "1")
+	assert.Nil(t, err)
+	err = index.Save(dir + "/unmapped")
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy = "id,title,year,published\n1,Folder,2021,true\n"
+	err = os.WriteFile(dir+"/unmapped.dcs", []byte(legacy), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Remove(dir + "/unmapped.mps")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Migrate(dir + "/unmapped")
+	assert.Nil(t, err)
+	loadedIndex, err = Load(dir + "/unmapped")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, map[string]interface{}{"title": "Folder", "year": "2021", "published": "true"}, loadedIndex.Documents["1"])
+
+	// Deleting a migrated document removes all of its terms
+	err = loadedIndex.Delete("1")
+	assert.Nil(t, err)
+	for _, query := range []string{"folder", "2021", "true"} {
+		res, _ := loadedIndex.Search(query)
+		assert.Equal(t, 0, res.Count, query)
+	}
+}
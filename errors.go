@@ -8,4 +8,89 @@ var (
 	// ErrDocumentMissingIDField is returned when the document being indexed is missing a field that
 	// is specified by the user to be used as the document ID.
 	ErrDocumentMissingIDField = errors.New("document missing id field")
+
+	// ErrUnsupportedDocumentDataType is returned when data of a type other than text, JSON, or JSONL
+	// is indexed. Builds without JSON support only support text.
+	ErrUnsupportedDocumentDataType = errors.New("unsupported document data type")
+
+	// ErrFetchFailed is returned when the files of an index loaded from a URL cannot be fetched, such
+	// as when the network is down.
+	ErrFetchFailed = errors.New("fetch failed")
+
+	// ErrUnknownAnalyzer is returned when an analyzer name is neither built-in nor added to the index.
+	ErrUnknownAnalyzer = errors.New("unknown analyzer")
+
+	// ErrUnknownCharFilter is returned when an analyzer configuration contains an unregistered char
+	// filter type.
+	ErrUnknownCharFilter = errors.New("unknown char filter")
+
+	// ErrUnknownTokenizer is returned when an analyzer configuration contains an unregistered
+	// tokenizer type.
+	ErrUnknownTokenizer = errors.New("unknown tokenizer")
+
+	// ErrUnknownTokenFilter is returned when an analyzer configuration contains an unregistered token
+	// filter type.
+	ErrUnknownTokenFilter = errors.New("unknown token filter")
+
+	// ErrInvalidAnalysisRecord is returned when the saved analysis settings of an index contain a
+	// record that cannot be understood.
+	ErrInvalidAnalysisRecord = errors.New("invalid analysis record")
+
+	// ErrInvalidDictionaryRecord is returned when the embedded Japanese dictionary contains a record
+	// that cannot be understood.
+	ErrInvalidDictionaryRecord = errors.New("invalid dictionary record")
+
+	// ErrUnknownLanguage is returned when a language-specific analysis component is configured with
+	// an unsupported language.
+	ErrUnknownLanguage = errors.New("unknown language")
+
+	// ErrUnknownScript is returned when a transliteration filter is configured with an unsupported
+	// script.
+	ErrUnknownScript = errors.New("unknown script")
+
+	// ErrInvalidParameter is returned when an analysis component is configured with a parameter that
+	// it cannot use, such as a gram size that is not a positive number.
+	ErrInvalidParameter = errors.New("invalid parameter")
+
+	// ErrUnknownFieldType is returned when a field mapping has a type that doesn't exist.
+	ErrUnknownFieldType = errors.New("unknown field type")
+
+	// ErrUnknownField is returned when a document contains a field that is not in the mapping of an
+	// index whose dynamic mapping is strict.
+	ErrUnknownField = errors.New("unknown field")
+
+	// ErrInvalidFieldValue is returned when a document contains a value that doesn't match the type of
+	// its field in the mapping.
+	ErrInvalidFieldValue = errors.New("invalid field value")
+
+	// ErrInvalidMappingRecord is returned when the saved mapping of an index contains a record that
+	// cannot be understood.
+	ErrInvalidMappingRecord = errors.New("invalid mapping record")
+
+	// ErrInvalidDocumentRecord is returned when the saved documents of an index contain a record that
+	// cannot be decoded.
+	ErrInvalidDocumentRecord = errors.New("invalid document record")
+
+	// ErrInvalidStruct is returned when a value that isn't a struct is indexed as one, or when a
+	// struct contains a field whose type cannot be stored in a document such as a channel.
+	ErrInvalidStruct = errors.New("invalid struct")
+
+	// ErrDocumentNotFound is returned when a document with a specific ID doesn't exist.
+	ErrDocumentNotFound = errors.New("document not found")
+
+	// ErrVersionConflict is matched by the errors returned when a conditional update or delete
+	// expects a different version of a document. See VersionConflictError for the versions.
+	ErrVersionConflict = errors.New("version conflict")
+
+	// ErrInvalidPatch is returned when a patch cannot be applied to a document, such as when a
+	// number is added to a field that isn't a number.
+	ErrInvalidPatch = errors.New("invalid patch")
+
+	// ErrInvalidBulkAction is returned for bulk actions that cannot be run, such as an update action
+	// without a document ID or a line of bulk data that isn't an action.
+	ErrInvalidBulkAction = errors.New("invalid bulk action")
+
+	// ErrInvalidQuery is returned when a query cannot be run on a field, such as a term query on a
+	// field that isn't an exact field.
+	ErrInvalidQuery = errors.New("invalid query")
 )
@@ -0,0 +1,150 @@
+package folder
+
+import (
+	"sort"
+)
+
+// ExplainedToken is a token produced while analyzing text along with the token filters that added,
+// changed, or removed it.
+type ExplainedToken struct {
+	Token
+	Original  string   // Term of the token when it was produced
+	AddedBy   string   // Token filter that added the token, or empty if it was produced by the tokenizer
+	ChangedBy []string // Token filters that changed the term of the token
+	RemovedBy string   // Token filter that removed the token, or empty if it was kept
+}
+
+// AnalysisExplanation describes how an analyzer broke down text into tokens, e.g. to find out why a
+// document cannot be found.
+type AnalysisExplanation struct {
+	Analyzer string           // Name of the analyzer
+	Language string           // Detected language of the text if the analyzer was chosen by detecting it
+	Text     string           // Text given to the tokenizer, i.e. after the char filters
+	Tokens   []ExplainedToken // Every token ordered by position, including the removed ones
+}
+
+// Terms returns the terms of the tokens that were kept, which are the terms Analyze returns.
+func (explanation *AnalysisExplanation) Terms() (terms []string) {
+	for _, token := range explanation.Tokens {
+		if token.RemovedBy == "" {
+			terms = append(terms, token.Term)
+		}
+	}
+	return
+}
+
+// ExplainAnalysis breaks down text using a named analyzer just like Analyze but also tells which
+// token filters added, changed, or removed each token.
+func (index *Index) ExplainAnalysis(analyzerName, s string) (explanation AnalysisExplanation, err error) {
+	analyzer, err := index.Analyzer(analyzerName)
+	if err != nil {
+		return
+	}
+	config, _ := index.Analysis.analyzerConfig(analyzerName)
+
+	explanation = analyzer.explain(s, config)
+	explanation.Analyzer = analyzerName
+	return
+}
+
+// ExplainFieldAnalysis explains the analysis of a field value like ExplainAnalysis using the
+// analyzer of the field, or the analyzer of the language of the value if it is detected.
+func (index *Index) ExplainFieldAnalysis(field, s string) (explanation AnalysisExplanation, err error) {
+	var languages map[string]string
+	if index.Analysis.detectsLanguage(field) {
+		languages = index.detectLanguages(map[string]interface{}{field: s})
+	}
+
+	explanation, err = index.ExplainAnalysis(index.fieldAnalyzerIn(field, languages), s)
+	if err != nil {
+		return
+	}
+
+	for _, language := range languages {
+		explanation.Language = language
+	}
+	return
+}
+
+// explain analyzes text like Analyze and records what each token filter did to the tokens. Token
+// filters are named by their type in the configuration of the analyzer.
+func (analyzer *Analyzer) explain(s string, config AnalyzerConfig) (explanation AnalysisExplanation) {
+	s, mapping := analyzer.filterChars(s)
+	explanation.Text = s
+
+	// The explanation of each token that is still in the token stream, in the same order
+	var current []*ExplainedToken
+	var explained []*ExplainedToken
+	for _, token := range analyzer.Tokenizer.Tokenize(s) {
+		e := &ExplainedToken{Token: token, Original: token.Term}
+		current = append(current, e)
+		explained = append(explained, e)
+	}
+
+	for i, tokenFilter := range analyzer.TokenFilters {
+		name := ""
+		if i < len(config.TokenFilters) {
+			name = config.TokenFilters[i].Type
+		}
+
+		tokens := make([]Token, len(current))
+		for j, e := range current {
+			tokens[j] = e.Token
+		}
+		filtered := tokenFilter.Filter(tokens)
+
+		// Filtered tokens are matched with the tokens they came from by their offsets, preferring the
+		// ones whose term did not change
+		next := make([]*ExplainedToken, len(filtered))
+		for _, e := range current {
+			j := matchFilteredToken(filtered, next, e.Token, true)
+			if j < 0 {
+				j = matchFilteredToken(filtered, next, e.Token, false)
+			}
+			if j < 0 {
+				e.RemovedBy = name
+				continue
+			}
+
+			if filtered[j].Term != e.Term {
+				e.ChangedBy = append(e.ChangedBy, name)
+			}
+			e.Token = filtered[j]
+			next[j] = e
+		}
+
+		for j, token := range filtered {
+			if next[j] == nil {
+				next[j] = &ExplainedToken{Token: token, Original: token.Term, AddedBy: name}
+				explained = append(explained, next[j])
+			}
+		}
+		current = next
+	}
+
+	sort.SliceStable(explained, func(i, j int) bool {
+		return explained[i].Position < explained[j].Position
+	})
+	for _, e := range explained {
+		if mapping != nil {
+			e.Start, e.End = mapping.Span(e.Start, e.End)
+		}
+		explanation.Tokens = append(explanation.Tokens, *e)
+	}
+	return
+}
+
+// matchFilteredToken returns the index of the first filtered token that has not been matched yet and
+// has the same offsets as a token, and the same term if sameTerm is set. It returns -1 if there is
+// none.
+func matchFilteredToken(filtered []Token, matched []*ExplainedToken, token Token, sameTerm bool) int {
+	for j, f := range filtered {
+		if matched[j] != nil || f.Start != token.Start || f.End != token.End {
+			continue
+		}
+		if !sameTerm || f.Term == token.Term {
+			return j
+		}
+	}
+	return -1
+}
@@ -0,0 +1,79 @@
+package folder
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExplainAnalysis(t *testing.T) {
+	index := New()
+	err := index.AddAnalyzer("english", AnalyzerConfig{
+		CharFilters: []ComponentConfig{{Type: "html_strip"}},
+		Tokenizer:   ComponentConfig{Type: "unicode"},
+		TokenFilters: []ComponentConfig{
+			{Type: "lowercase"},
+			{Type: "stop"},
+			{Type: "stemmer", Params: []string{"english"}},
+			{Type: "soundex"},
+		},
+	})
+	assert.Nil(t, err)
+
+	s := "<b>The</b> Running dogs"
+	explanation, err := index.ExplainAnalysis("english", s)
+	assert.Nil(t, err)
+	assert.Equal(t, "english", explanation.Analyzer)
+	assert.Equal(t, "The Running dogs", explanation.Text)
+
+	// Every term that is kept is also returned by Analyze
+	analyzer, _ := index.Analyzer("english")
+	var terms []string
+	for _, token := range analyzer.Analyze(s) {
+		terms = append(terms, token.Term)
+	}
+	assert.Equal(t, terms, explanation.Terms())
+
+	tokens := explanation.Tokens
+	assert.Equal(t, 5, len(tokens))
+
+	assert.Equal(t, "the", tokens[0].Term)
+	assert.Equal(t, "The", tokens[0].Original)
+	assert.Equal(t, []string{"lowercase"}, tokens[0].ChangedBy)
+	assert.Equal(t, "stop", tokens[0].RemovedBy)
+	assert.Equal(t, "The", s[tokens[0].Start:tokens[0].End])
+
+	assert.Equal(t, "run", tokens[1].Term)
+	assert.Equal(t, []string{"lowercase", "stemmer"}, tokens[1].ChangedBy)
+	assert.Equal(t, "", tokens[1].AddedBy)
+	assert.Equal(t, "", tokens[1].RemovedBy)
+	assert.Equal(t, "Running", s[tokens[1].Start:tokens[1].End])
+
+	assert.Equal(t, "R500", tokens[2].Term)
+	assert.Equal(t, "soundex", tokens[2].AddedBy)
+	assert.Equal(t, tokens[1].Position, tokens[2].Position)
+
+	assert.Equal(t, "dog", tokens[3].Term)
+	assert.Equal(t, "D200", tokens[4].Term)
+
+	_, err = index.ExplainAnalysis("klingon", s)
+	assert.True(t, errors.Is(err, ErrUnknownAnalyzer))
+}
+
+func TestExplainFieldAnalysis(t *testing.T) {
+	index := languageDetectionIndex(t, false)
+	err := index.SetFieldAnalyzer("code", BasicAnalyzer)
+	assert.Nil(t, err)
+
+	explanation, err := index.ExplainFieldAnalysis("code", "Searching documents")
+	assert.Nil(t, err)
+	assert.Equal(t, BasicAnalyzer, explanation.Analyzer)
+	assert.Equal(t, "", explanation.Language)
+
+	explanation, err = index.ExplainFieldAnalysis("title", "Mencari dokumen di perpustakaan")
+	assert.Nil(t, err)
+	assert.Equal(t, "indonesian", explanation.Analyzer)
+	assert.Equal(t, "indonesian", explanation.Language)
+	assert.Equal(t, []string{"cari", "dokumen", "pustaka"}, explanation.Terms())
+}
@@ -0,0 +1,206 @@
+package folder
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SortField is an exact field that search hits are sorted by.
+type SortField struct {
+	Field      string
+	Descending bool
+}
+
+// FacetValue is a value of a facet field along with the number of matching documents that have it.
+type FacetValue struct {
+	Value string
+	Count int
+}
+
+// arrangeDocuments sorts scored document IDs by the sort fields of the search options, if any, and
+// counts the values of their facet fields. Documents are sorted by their smallest value of a field in
+// ascending order and by their largest value in descending order, and documents without a value come
+// last. Documents that are equal in every sort field stay sorted by score.
+func (index *Index) arrangeDocuments(ctx context.Context, documentIDs []string, scores []float64, opts SearchOptions) (sortedDocumentIDs []string, sortedScores []float64, facets map[string][]FacetValue, err error) {
+	sortedDocumentIDs, sortedScores = documentIDs, scores
+	if len(opts.Sort) == 0 && len(opts.Facets) == 0 {
+		return
+	}
+
+	mappings := make(map[string]FieldMapping)
+	for _, field := range opts.Facets {
+		mappings[field], err = index.storedExactMapping(field)
+		if err != nil {
+			return
+		}
+	}
+	for _, sortField := range opts.Sort {
+		mappings[sortField.Field], err = index.storedExactMapping(sortField.Field)
+		if err != nil {
+			return
+		}
+	}
+
+	// Values of each field by document ID
+	values := make(map[string]map[string][]string)
+	for field := range mappings {
+		values[field] = make(map[string][]string)
+	}
+
+	for _, documentID := range documentIDs {
+		var document map[string]interface{}
+
+		document, _, err = index.fetchDocument(ctx, documentID)
+		if err != nil {
+			return
+		}
+
+		for field, mapping := range mappings {
+			for _, s := range fieldValuesFromRoot(document, field) {
+				if value, ok := mapping.value(s); ok {
+					values[field][documentID] = append(values[field][documentID], value)
+				}
+			}
+		}
+	}
+
+	if len(opts.Facets) > 0 {
+		facets = make(map[string][]FacetValue)
+		for _, field := range opts.Facets {
+			facets[field] = countFacetValues(values[field])
+		}
+	}
+
+	if len(opts.Sort) == 0 {
+		return
+	}
+
+	type sortedDocument struct {
+		ID    string
+		Score float64
+		Keys  []string
+		Found []bool
+	}
+
+	documents := make([]sortedDocument, len(documentIDs))
+	for i, documentID := range documentIDs {
+		document := sortedDocument{ID: documentID, Score: scores[i]}
+		for _, sortField := range opts.Sort {
+			key, found := sortKey(mappings[sortField.Field].Type, values[sortField.Field][documentID], sortField.Descending)
+			document.Keys = append(document.Keys, key)
+			document.Found = append(document.Found, found)
+		}
+		documents[i] = document
+	}
+
+	sort.SliceStable(documents, func(i, j int) bool {
+		a, b := documents[i], documents[j]
+		for k, sortField := range opts.Sort {
+			if a.Found[k] != b.Found[k] {
+				return a.Found[k]
+			}
+			if !a.Found[k] {
+				continue
+			}
+
+			c := compareExactValues(mappings[sortField.Field].Type, a.Keys[k], b.Keys[k])
+			if sortField.Descending {
+				c = -c
+			}
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+
+	sortedDocumentIDs = make([]string, len(documents))
+	sortedScores = make([]float64, len(documents))
+	for i, document := range documents {
+		sortedDocumentIDs[i] = document.ID
+		sortedScores[i] = document.Score
+	}
+	return
+}
+
+// storedExactMapping returns the mapping of an exact field whose values are stored, since the values
+// that documents are sorted by and counted by are read from the documents themselves.
+func (index *Index) storedExactMapping(field string) (mapping FieldMapping, err error) {
+	mapping, err = index.exactMapping(field)
+	if err == nil && mapping.NotStored {
+		err = fmt.Errorf("%w: %s is not stored", ErrInvalidQuery, field)
+	}
+	return
+}
+
+// countFacetValues counts the documents having each value and sorts the values by their count and
+// then by the values themselves.
+func countFacetValues(values map[string][]string) (facetValues []FacetValue) {
+	counts := make(map[string]int)
+	for _, documentValues := range values {
+		set := MakeStringSet(documentValues)
+		for _, value := range set.List() {
+			counts[value]++
+		}
+	}
+
+	for value, count := range counts {
+		facetValues = append(facetValues, FacetValue{Value: value, Count: count})
+	}
+	sort.Slice(facetValues, func(i, j int) bool {
+		if facetValues[i].Count != facetValues[j].Count {
+			return facetValues[i].Count > facetValues[j].Count
+		}
+		return facetValues[i].Value < facetValues[j].Value
+	})
+	return
+}
+
+// sortKey returns the value of a document that it is sorted by, which is its smallest value in
+// ascending order and its largest value in descending order.
+func sortKey(fieldType FieldType, values []string, descending bool) (key string, found bool) {
+	for _, value := range values {
+		if !found {
+			key, found = value, true
+			continue
+		}
+
+		c := compareExactValues(fieldType, value, key)
+		if (descending && c > 0) || (!descending && c < 0) {
+			key = value
+		}
+	}
+	return
+}
+
+// compareExactValues compares two terms of an exact field by their meaning, e.g. numerically for
+// numbers and chronologically for dates.
+func compareExactValues(fieldType FieldType, a, b string) int {
+	switch fieldType {
+	case FieldTypeInteger, FieldTypeFloat:
+		fa, _ := strconv.ParseFloat(a, 64)
+		fb, _ := strconv.ParseFloat(b, 64)
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+		return 0
+	case FieldTypeDate:
+		ta, _ := time.Parse(time.RFC3339Nano, a)
+		tb, _ := time.Parse(time.RFC3339Nano, b)
+		switch {
+		case ta.Before(tb):
+			return -1
+		case ta.After(tb):
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a, b)
+}
@@ -0,0 +1,78 @@
+package folder
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFacets(t *testing.T) {
+	index := keywordIndex(t)
+
+	opts := DefaultSearchOptions
+	opts.Facets = []string{"status", "tags"}
+	res, err := index.Search("red")
+	assert.Nil(t, err)
+	assert.Nil(t, res.Facets)
+
+	res, err = index.SearchWithOptions("shoes", opts)
+	assert.Nil(t, err)
+	assert.Equal(t, []FacetValue{{"draft", 1}, {"published", 1}}, res.Facets["status"])
+	assert.Equal(t, []FacetValue{{"shoes", 2}, {"cafe", 1}}, res.Facets["tags"])
+
+	res, _ = index.SearchTerm("status", "published", opts)
+	assert.Equal(t, []FacetValue{{"published", 2}}, res.Facets["status"])
+	assert.Equal(t, []FacetValue{{"cafe", 2}, {"hats", 1}, {"shoes", 1}}, res.Facets["tags"])
+
+	opts.Facets = []string{"title"}
+	_, err = index.SearchWithOptions("shoes", opts)
+	assert.True(t, errors.Is(err, ErrInvalidQuery))
+
+	// Values that are not stored cannot be counted
+	err = index.SetFieldMapping("code", FieldMapping{Type: FieldTypeKeyword, NotStored: true})
+	assert.Nil(t, err)
+	opts.Facets = []string{"code"}
+	_, err = index.SearchWithOptions("shoes", opts)
+	assert.True(t, errors.Is(err, ErrInvalidQuery))
+
+	opts.Facets = nil
+	opts.Sort = []SortField{{Field: "code"}}
+	_, err = index.SearchWithOptions("shoes", opts)
+	assert.True(t, errors.Is(err, ErrInvalidQuery))
+}
+
+func TestSort(t *testing.T) {
+	index := keywordIndex(t)
+
+	ids := func(res SearchResult) (ids []string) {
+		for _, hit := range res.Hits {
+			ids = append(ids, hit.ID)
+		}
+		return
+	}
+
+	opts := DefaultSearchOptions
+	opts.Sort = []SortField{{Field: "views"}}
+	res, _ := index.SearchTerms("status", []string{"published", "draft"}, opts)
+	assert.Equal(t, []string{"3", "1", "2"}, ids(res))
+
+	opts.Sort = []SortField{{Field: "views", Descending: true}}
+	res, _ = index.SearchTerms("status", []string{"published", "draft"}, opts)
+	assert.Equal(t, []string{"2", "1", "3"}, ids(res))
+
+	// Documents without a value come last and the following fields break ties
+	opts.Sort = []SortField{{Field: "email"}, {Field: "status", Descending: true}, {Field: "sku"}}
+	res, _ = index.SearchTerms("status", []string{"published", "draft"}, opts)
+	assert.Equal(t, []string{"1", "2", "3"}, ids(res))
+
+	opts.Sort = []SortField{{Field: "status", Descending: true}, {Field: "views"}}
+	res, _ = index.SearchTerms("status", []string{"published", "draft"}, opts)
+	assert.Equal(t, []string{"3", "1", "2"}, ids(res))
+
+	opts.Size = 1
+	opts.From = 1
+	res, _ = index.SearchTerms("status", []string{"published", "draft"}, opts)
+	assert.Equal(t, 3, res.Count)
+	assert.Equal(t, []string{"1"}, ids(res))
+}
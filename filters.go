@@ -2,6 +2,7 @@ package folder
 
 import (
 	"strings"
+	"unicode"
 )
 
 var (
@@ -46,3 +47,85 @@ func StopWordFilter(tokens []string) (filteredTokens []string) {
 	}
 	return
 }
+
+// LowercaseTokenFilter is the token filter counterpart of LowercaseFilter.
+func LowercaseTokenFilter(tokens []Token) (filteredTokens []Token) {
+	for _, token := range tokens {
+		token.Term = strings.ToLower(token.Term)
+		filteredTokens = append(filteredTokens, token)
+	}
+	return
+}
+
+// PunctuationTokenFilter is the token filter counterpart of PunctuationFilter.
+func PunctuationTokenFilter(tokens []Token) (filteredTokens []Token) {
+	for _, token := range tokens {
+		token.Term = strings.Map(func(r rune) rune {
+			if strings.ContainsRune(punctuations, r) {
+				return -1
+			}
+			return r
+		}, token.Term)
+		filteredTokens = append(filteredTokens, token)
+	}
+	return
+}
+
+// StopWordTokenFilter is the token filter counterpart of StopWordFilter.
+func StopWordTokenFilter(tokens []Token) (filteredTokens []Token) {
+	for _, token := range tokens {
+		if !stopWordsSet.Contains(token.Term) {
+			filteredTokens = append(filteredTokens, token)
+		}
+	}
+	return
+}
+
+// isCJK reports whether a rune is a Han, Hiragana, Katakana, or Hangul character.
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) || r == 0x30fc || r == 0xff70
+}
+
+// CJKBigramTokenFilter breaks down runs of Han, Hiragana, Katakana, and Hangul characters in tokens
+// into overlapping bigrams, or a unigram if the run is a single character, so that text written
+// without spaces can be searched by any part of it. Other parts of the tokens are left as they are.
+func CJKBigramTokenFilter(tokens []Token) (filteredTokens []Token) {
+	emit := func(token Token, start, end int) {
+		token.Term = token.Term[start:end]
+		token.Start += start
+		token.End = token.Start + end - start
+		token.Position = len(filteredTokens)
+		filteredTokens = append(filteredTokens, token)
+	}
+
+	for _, token := range tokens {
+		var offsets []int // Byte offsets of the characters of the current run
+
+		// A space is appended so that the last run is emitted like the others
+		cjk := false
+		for i, r := range token.Term + " " {
+			if len(offsets) > 0 && isCJK(r) == cjk && i < len(token.Term) {
+				offsets = append(offsets, i)
+				continue
+			}
+
+			if cjk && len(offsets) == 1 {
+				emit(token, offsets[0], i)
+			} else if cjk {
+				for j := 0; j < len(offsets)-1; j++ {
+					end := i
+					if j+2 < len(offsets) {
+						end = offsets[j+2]
+					}
+					emit(token, offsets[j], end)
+				}
+			} else if len(offsets) > 0 {
+				emit(token, offsets[0], i)
+			}
+
+			offsets = []int{i}
+			cjk = isCJK(r)
+		}
+	}
+	return
+}
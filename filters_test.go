@@ -2,6 +2,8 @@ package folder
 
 import (
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 var tokens []string
@@ -28,3 +30,29 @@ func BenchmarkStopWordFilter(b *testing.B) {
 		StopWordFilter(tokens)
 	}
 }
+
+func TestCJKBigramTokenFilter(t *testing.T) {
+	tokenizer := &UnicodeTokenizer{}
+
+	tokens := CJKBigramTokenFilter(tokenizer.Tokenize("日本語を勉強する Folder 송채영 犬 abc한국"))
+	assert.Equal(t, []string{"日本", "本語", "語を", "を勉", "勉強", "強す", "する", "Folder", "송채", "채영", "犬", "abc", "한국"}, terms(tokens))
+	assert.Equal(t, Token{Term: "本語", Type: TokenTypeIdeographic, Position: 1, Start: 3, End: 9}, tokens[1])
+	assert.Equal(t, Token{Term: "犬", Type: TokenTypeIdeographic, Position: 10, Start: 42, End: 45}, tokens[10])
+}
+
+func TestCJKAnalyzer(t *testing.T) {
+	index := New()
+	index.SetFieldAnalyzer("Kanji.Expression", CJKAnalyzer)
+	index.IndexWithID(map[string]interface{}{
+		"Kanji": []interface{}{map[string]interface{}{"Expression": "日本語能力試験"}},
+	}, "1")
+
+	res, _ := index.Search("能力")
+	assert.Equal(t, 1, res.Count)
+
+	res, _ = index.Search("語能力試")
+	assert.Equal(t, 1, res.Count)
+
+	res, _ = index.Search("能試")
+	assert.Equal(t, 0, res.Count)
+}
@@ -1,6 +1,7 @@
 package folder
 
 import (
+	"context"
 	"io/fs"
 	"time"
 )
@@ -10,21 +11,34 @@ type Index struct {
 	Name                  string
 	FieldNames            []string
 	Documents             map[string]map[string]interface{}
+	Versions              map[string]uint64 // Also kept for deleted documents so that versions never go back
 	TermStats             map[string]TermStat
 	LoadedDocumentsShards map[uint32]struct{}
 	LoadedTermStatsShards map[uint32]struct{}
 	ShardCount            int
+	Analysis              AnalysisSettings
+	Mapping               Mapping
 	f                     fs.FS
 	baseURL               string
+	analyzers             *analyzerCache
+
+	// unstoredTerms contains the terms of the values that are indexed but not stored of each
+	// document, by the ID of the document or of its nested objects, so that they can be removed
+	// without going through every term when the document is deleted.
+	unstoredTerms map[string]map[string][]string
 }
 
 // New creates an empty index.
 func New() (index *Index) {
 	index = &Index{}
 	index.Documents = make(map[string]map[string]interface{})
+	index.Versions = make(map[string]uint64)
 	index.TermStats = make(map[string]TermStat)
 	index.LoadedDocumentsShards = make(map[uint32]struct{})
 	index.LoadedTermStatsShards = make(map[uint32]struct{})
+	index.Analysis = NewAnalysisSettings()
+	index.Mapping = NewMapping()
+	index.analyzers = newAnalyzerCache()
 	return
 }
 
@@ -43,9 +57,14 @@ type SearchTime struct {
 // SearchResult contains the result of a search such as matching document count, the documents
 // themselves with some metadata (a.k.a. the hits), and the search statistics.
 type SearchResult struct {
-	Count int
-	Hits  []Hit
-	Time  SearchTime
+	Count    int
+	Hits     []Hit
+	Time     SearchTime
+	TimedOut bool // Whether the search was cut short by its context and the result is partial
+
+	// Facets contains the values of each facet field of the search options among every matching
+	// document, sorted by the number of documents having them.
+	Facets map[string][]FacetValue
 }
 
 // SearchOptions contains options that can be used to alter the search operation and result.
@@ -53,6 +72,15 @@ type SearchOptions struct {
 	UseCache bool // Whether to use and/or keep relevant data in memory
 	Size     int  // Number of documents to return
 	From     int  // Starting offset for returned documents
+
+	// Sort contains the exact fields, such as keyword or number fields, that the hits are sorted by
+	// instead of their scores. Hits are sorted by the following fields when they are equal.
+	Sort []SortField
+
+	// Facets contains the exact fields whose values are counted among every matching document. Both
+	// facets and sorting read the values from the stored documents, so fields that are not stored
+	// cannot be counted or sorted by and return ErrInvalidQuery.
+	Facets []string
 }
 
 // DefaultSearchOptions returns the default search options.
@@ -64,9 +92,15 @@ var DefaultSearchOptions = SearchOptions{
 
 // Hit contains metadata of a document such as its ID and score, and also the document iself.
 type Hit struct {
-	ID     string
-	Score  float64
-	Source map[string]interface{}
+	ID      string
+	Score   float64
+	Version uint64
+	Source  map[string]interface{}
+	Details *ScoreDetails // Per-component scores, only available for hybrid searches
+
+	// InnerHits contains the objects of the nested field that matched, only available for nested
+	// searches
+	InnerHits []InnerHit
 }
 
 // IndexWithID indexes a document into the index but with user-specified document ID.
@@ -83,39 +117,77 @@ func (index *Index) Index(document map[string]interface{}) (documentID string, e
 	return
 }
 
-// Update updates an existing document in the index with new data.
+// Update updates an existing document in the index with new data. The document is validated
+// against the mapping of the index first and isn't indexed if any of its values doesn't match. Each
+// update increases the version of the document, which starts at 1 and keeps increasing when a
+// deleted document is indexed again.
 func (index *Index) Update(documentID string, document map[string]interface{}) (err error) {
 	if index.Documents == nil {
 		index.Documents = make(map[string]map[string]interface{})
 	}
+	if index.Versions == nil {
+		index.Versions = make(map[string]uint64)
+	}
+
+	languages := index.detectLanguages(document)
+	document = index.withLanguages(document, languages)
 
+	inferred, err := index.mapDocument(document)
+	if err != nil {
+		return
+	}
+
+	// The version of a deleted document is loaded along with its shard
 	err = index.Delete(documentID)
 	if err != nil {
 		return
 	}
 
-	index.Documents[documentID] = document
+	for field, mapping := range inferred {
+		index.setFieldMapping(field, mapping)
+	}
+	index.Documents[documentID] = index.storedDocument(document)
+	index.Versions[documentID]++
 
-	err = index.index(documentID, document)
+	err = index.index(documentID, document, languages)
 	if err != nil {
 		return
 	}
+
+	if index.Mapping.indexesUnstoredFields() {
+		if index.unstoredTerms == nil {
+			index.unstoredTerms = make(map[string]map[string][]string)
+		}
+		index.unstoredTerms[documentID] = index.analyzeUnstored(documentID, document, languages)
+	}
 	return
 }
 
-// Delete deletes an existing document in the index.
+// Delete deletes an existing document in the index. Its version is kept so that indexing the
+// document again gives it a newer version.
 func (index *Index) Delete(documentID string) (err error) {
-	document, ok := index.Documents[documentID]
-	if !ok {
+	// The shard of the document is loaded first if the index is loaded deferred
+	document, ok, err := index.fetchDocument(context.Background(), documentID)
+	if err != nil || !ok {
 		return
 	}
 
 	debug("Delete", documentID)
+	delete(index.Documents, documentID)
+
+	unstoredTerms, ok := index.unstoredTerms[documentID]
+	delete(index.unstoredTerms, documentID)
+	if !ok && index.Mapping.indexesUnstoredFields() {
+		// The terms of the values that are not stored of documents saved without them can only be
+		// found by going through every term
+		err = index.removeDocumentFromAllTermStats(documentID)
+		return
+	}
 
 	m := make(map[string][]string)
-	index.analyze("", document, m)
+	index.analyze("", document, index.detectLanguages(document), m)
 
-	allTokens := MakeStringSet([]string{})
+	allTokens := MakeStringSet(unstoredTerms[documentID])
 	for _, tokens := range m {
 		for _, token := range tokens {
 			allTokens.Add(token)
@@ -123,13 +195,38 @@ func (index *Index) Delete(documentID string) (err error) {
 	}
 
 	err = index.removeDocumentFromTermStats(documentID, allTokens.List())
+	if err != nil {
+		return
+	}
+
+	objects := index.analyzeNested(documentID, document, index.detectLanguages(document))
+	for objectID, terms := range unstoredTerms {
+		if objectID != documentID {
+			if objects == nil {
+				objects = make(map[string][]string)
+			}
+			objects[objectID] = append(objects[objectID], terms...)
+		}
+	}
+	for objectID, terms := range objects {
+		objectTerms := MakeStringSet(terms)
+		err = index.removeDocumentFromTermStats(objectID, objectTerms.List())
+		if err != nil {
+			return
+		}
+	}
 	return
 }
 
 // Fetch fetches a document with specific ID.
 func (index *Index) Fetch(documentID string) (document map[string]interface{}, err error) {
-	shardID := index.CalculateShardID(documentID)
-	document, err = index.fetchDocumentFromShard(shardID, documentID)
+	return index.FetchContext(context.Background(), documentID)
+}
+
+// FetchContext fetches a document just like Fetch but stops reading the shard when the context is
+// done.
+func (index *Index) FetchContext(ctx context.Context, documentID string) (document map[string]interface{}, err error) {
+	document, _, err = index.FetchWithVersionContext(ctx, documentID)
 	return
 }
 
@@ -141,57 +238,94 @@ func (index *Index) Search(s string) (res SearchResult, err error) {
 
 // SearchWithOptions searches a term just like Search but it also accepts user-provided SearchOptions.
 func (index *Index) SearchWithOptions(s string, opts SearchOptions) (res SearchResult, err error) {
+	return index.SearchContext(context.Background(), s, opts)
+}
+
+// SearchContext searches a term just like SearchWithOptions but stops loading shards and scoring
+// documents once the context is done. In that case, the documents scored so far are returned and the
+// result is flagged as timed out instead of returning an error.
+func (index *Index) SearchContext(ctx context.Context, s string, opts SearchOptions) (res SearchResult, err error) {
 	if !opts.UseCache {
 		debug("Search", s, "(not cached)")
-		tmp := New()
-		tmp.Name = index.Name
-		tmp.ShardCount = index.ShardCount
-		tmp.f = index.f
-		tmp.baseURL = index.baseURL
-		return tmp.searchWithOptions(s, opts)
+		return index.uncached().searchWithOptions(ctx, s, opts)
 	}
 	debug("Search", s, "(cached)")
-	return index.searchWithOptions(s, opts)
+	return index.searchWithOptions(ctx, s, opts)
 }
 
-func (index *Index) searchWithOptions(s string, opts SearchOptions) (res SearchResult, err error) {
+// uncached returns an empty index that loads its data from the same location as the index so that
+// searches do not keep the loaded data in memory.
+func (index *Index) uncached() (tmp *Index) {
+	tmp = New()
+	tmp.Name = index.Name
+	tmp.ShardCount = index.ShardCount
+	tmp.Analysis = index.Analysis
+	tmp.Mapping = index.Mapping
+	tmp.f = index.f
+	tmp.baseURL = index.baseURL
+	tmp.analyzers = index.analyzers
+	return
+}
+
+func (index *Index) searchWithOptions(ctx context.Context, s string, opts SearchOptions) (res SearchResult, err error) {
 	var matchedDocumentIDs []string
 	var sortedDocumentIDs []string
+	var tokens []string
 	var scores []float64
 
 	startTime := time.Now()
-	tokens := index.Analyze(s)
-	debug("  Analyzed", s, "into", tokens)
+	defer func() {
+		res.Time.Total = time.Since(startTime)
+	}()
 
-	matchedDocumentIDs, res.Time.Match, err = index.findDocuments(tokens)
+	matchedDocumentIDs, tokens, res.Time.Match, err = index.matchQuery(ctx, s)
 	if err != nil {
-		return
+		res.TimedOut, err = timedOut(ctx, err)
+		if err != nil {
+			return
+		}
+	}
+
+	sortedDocumentIDs, scores, res.Time.Sort, err = index.sortDocuments(ctx, matchedDocumentIDs, tokens)
+	if err != nil {
+		res.TimedOut, err = timedOut(ctx, err)
+		if err != nil {
+			return
+		}
 	}
 
-	sortedDocumentIDs, scores, res.Time.Sort, err = index.sortDocuments(matchedDocumentIDs, tokens)
+	sortedDocumentIDs, scores, res.Facets, err = index.arrangeDocuments(ctx, sortedDocumentIDs, scores, opts)
 	if err != nil {
+		res.TimedOut, err = timedOut(ctx, err)
 		return
 	}
+	res.Count = len(sortedDocumentIDs)
 
-	res.Hits, err = index.fetchHits(sortedDocumentIDs, scores, opts.Size, opts.From)
+	res.Hits, err = index.fetchHits(ctx, sortedDocumentIDs, scores, opts.Size, opts.From)
 	if err != nil {
+		res.TimedOut, err = timedOut(ctx, err)
 		return
 	}
 
-	res.Count = len(sortedDocumentIDs)
-	res.Time.Total = time.Since(startTime)
 	return
 }
 
-// AnalyzeString breaks down string into list of tokens with some metadata such positions.
-func (index *Index) Analyze(s string) (tokens []string) {
-	tokens = splitWithRunes(s, ",、　 ​")
-	tokens = LowercaseFilter(tokens)
-	tokens = PunctuationFilter(tokens)
-	tokens = StopWordFilter(tokens)
+// timedOut reports whether an error was caused by the context being done, in which case the error
+// is dropped so that the partial result can be returned instead.
+func timedOut(ctx context.Context, e error) (ok bool, err error) {
+	if ctx.Err() != nil {
+		ok = true
+		return
+	}
+	err = e
 	return
 }
 
+// Analyze breaks down string into list of tokens using the default analyzer of the index.
+func (index *Index) Analyze(s string) (tokens []string) {
+	return index.analyzeWith(index.Analysis.fieldAnalyzer(""), s)
+}
+
 func (index *Index) CalculateShardID(s string) (shardID uint32) {
 	const Q = 123456789
 
@@ -1,7 +1,9 @@
 package folder
 
 import (
+	"context"
 	"fmt"
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -34,7 +36,7 @@ func BenchmarkAnalyze(b *testing.B) {
 
 func BenchmarkFindDocuments(b *testing.B) {
 	for n := 0; n < b.N; n++ {
-		index.findDocuments([]string{"lunar", "new", "year"})
+		index.findDocuments(context.Background(), []string{"lunar", "new", "year"})
 	}
 }
 
@@ -155,3 +157,45 @@ func TestUpdate(t *testing.T) {
 	assert.Equal(t, len(searchResult.Hits), 1)
 	fmt.Printf("%+v\n", searchResult)
 }
+
+func TestSearchContext(t *testing.T) {
+	dir, err := os.MkdirTemp("", "folder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	index := New()
+	err = index.IndexFilePath("assets/users_test.jsonl", "jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = index.SaveToShards(dir+"/index", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = LoadDeferredContext(ctx, dir+"/index")
+	assert.Equal(t, context.Canceled, err)
+
+	index, err = LoadDeferred(dir + "/index")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := index.SearchContext(ctx, "cooking", DefaultSearchOptions)
+	assert.Nil(t, err)
+	assert.True(t, res.TimedOut)
+	assert.Equal(t, 0, len(res.Hits))
+
+	err = index.LoadAllShardsContext(ctx, func(loadedShardsCount, totalShardsCount int) {}, 0)
+	assert.Equal(t, context.Canceled, err)
+
+	res, err = index.SearchContext(context.Background(), "cooking", DefaultSearchOptions)
+	assert.Nil(t, err)
+	assert.False(t, res.TimedOut)
+	assert.Equal(t, 1, len(res.Hits))
+}
@@ -5,4 +5,5 @@ go 1.16
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/stretchr/testify v1.7.0
+	golang.org/x/text v0.3.7
 )
@@ -0,0 +1,311 @@
+package folder
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FusionMethod specifies how the lexical and vector rankings of a hybrid search are combined.
+type FusionMethod int
+
+const (
+	// ReciprocalRankFusion scores each document by the sum of weight / (rank constant + rank) of
+	// every ranking it appears in.
+	ReciprocalRankFusion FusionMethod = iota
+	// LinearCombination scores each document by the weighted sum of its normalized text score and
+	// its normalized vector similarity.
+	LinearCombination
+)
+
+// DefaultRankConstant is the rank constant used by reciprocal rank fusion when none is specified.
+const DefaultRankConstant = 60
+
+// KNNQuery contains the information needed to find the nearest documents to a vector.
+type KNNQuery struct {
+	Field  string    // Field path containing the document vectors
+	Vector []float64 // Query vector
+	K      int       // Number of nearest documents to consider, defaults to From + Size
+}
+
+// HybridQuery contains a text query and a kNN query whose results are fused into a single ranking.
+type HybridQuery struct {
+	Text         string
+	KNN          KNNQuery
+	Fusion       FusionMethod
+	RankConstant float64 // Rank constant for reciprocal rank fusion, defaults to DefaultRankConstant
+	TextWeight   float64 // Weight of the text ranking, both weights default to 1 when unset
+	VectorWeight float64 // Weight of the vector ranking, both weights default to 1 when unset
+}
+
+// ScoreDetails contains the per-component scores of a hybrid search hit. Ranks start from 1 and are
+// 0 when the document was not found by that component.
+type ScoreDetails struct {
+	TextScore   float64
+	TextRank    int
+	VectorScore float64
+	VectorRank  int
+}
+
+// SearchKNN finds the documents whose vectors are the most similar to the query vector using cosine
+// similarity.
+func (index *Index) SearchKNN(q KNNQuery, opts SearchOptions) (res SearchResult, err error) {
+	return index.SearchHybrid(HybridQuery{KNN: q}, opts)
+}
+
+// SearchHybrid runs the text query and the kNN query of a hybrid query and returns the fused hits.
+// The score of each component is available in Hit.Details.
+func (index *Index) SearchHybrid(q HybridQuery, opts SearchOptions) (res SearchResult, err error) {
+	return index.SearchHybridContext(context.Background(), q, opts)
+}
+
+// SearchHybridContext performs a hybrid search just like SearchHybrid but stops loading shards and
+// scoring documents once the context is done, in which case the hits found so far are returned with
+// TimedOut set.
+func (index *Index) SearchHybridContext(ctx context.Context, q HybridQuery, opts SearchOptions) (res SearchResult, err error) {
+	if !opts.UseCache {
+		debug("Hybrid search", q.Text, "(not cached)")
+		return index.uncached().searchHybrid(ctx, q, opts)
+	}
+	debug("Hybrid search", q.Text, "(cached)")
+	return index.searchHybrid(ctx, q, opts)
+}
+
+func (index *Index) searchHybrid(ctx context.Context, q HybridQuery, opts SearchOptions) (res SearchResult, err error) {
+	var textIDs, vectorIDs []string
+	var textScores, vectorScores []float64
+
+	startTime := time.Now()
+	defer func() {
+		res.Time.Total = time.Since(startTime)
+	}()
+
+	if q.Text != "" {
+		var matchedDocumentIDs []string
+		var tokens []string
+		var elapsedTime time.Duration
+
+		matchedDocumentIDs, tokens, res.Time.Match, err = index.matchQuery(ctx, q.Text)
+		if err != nil {
+			res.TimedOut, err = timedOut(ctx, err)
+			if err != nil {
+				return
+			}
+		}
+
+		textIDs, textScores, elapsedTime, err = index.sortDocuments(ctx, matchedDocumentIDs, tokens)
+		if err != nil {
+			res.TimedOut, err = timedOut(ctx, err)
+			if err != nil {
+				return
+			}
+		}
+		res.Time.Sort += elapsedTime
+	}
+
+	if len(q.KNN.Vector) > 0 {
+		var elapsedTime time.Duration
+
+		k := q.KNN.K
+		if k <= 0 {
+			k = opts.From + opts.Size
+		}
+
+		vectorIDs, vectorScores, elapsedTime, err = index.nearestDocuments(ctx, q.KNN.Field, q.KNN.Vector, k)
+		if err != nil {
+			res.TimedOut, err = timedOut(ctx, err)
+			if err != nil {
+				return
+			}
+		}
+		res.Time.Match += elapsedTime
+	}
+
+	sortStartTime := time.Now()
+	sortedDocumentIDs, scores, details := fuseRankings(q, textIDs, textScores, vectorIDs, vectorScores)
+	sortedDocumentIDs, scores, res.Facets, err = index.arrangeDocuments(ctx, sortedDocumentIDs, scores, opts)
+	if err != nil {
+		res.TimedOut, err = timedOut(ctx, err)
+		return
+	}
+	res.Time.Sort += time.Since(sortStartTime)
+	res.Count = len(sortedDocumentIDs)
+
+	res.Hits, err = index.fetchHits(ctx, sortedDocumentIDs, scores, opts.Size, opts.From)
+	for i := range res.Hits {
+		res.Hits[i].Details = details[res.Hits[i].ID]
+	}
+	if err != nil {
+		res.TimedOut, err = timedOut(ctx, err)
+		return
+	}
+	return
+}
+
+// nearestDocuments returns up to k document IDs sorted by the cosine similarity between their
+// vectors and the query vector. All document shards are loaded as every document has to be compared.
+// If the context is done, only the documents loaded so far are compared and the context error is
+// returned along with them.
+func (index *Index) nearestDocuments(ctx context.Context, field string, vector []float64, k int) (documentIDs []string, scores []float64, elapsedTime time.Duration, err error) {
+	startTime := time.Now()
+
+	debug("  Find", k, "nearest documents in field", field)
+
+	for i := 0; i < index.ShardCount; i++ {
+		err = index.loadDocumentsFromShard(ctx, uint32(i))
+		if err != nil {
+			if ctx.Err() == nil {
+				return
+			}
+			break
+		}
+	}
+
+	for documentID, document := range index.Documents {
+		values := fieldValuesFromRoot(document, field)
+		documentVector, ok := vectorFromValues(values)
+		if !ok || len(documentVector) != len(vector) {
+			continue
+		}
+
+		documentIDs = append(documentIDs, documentID)
+		scores = append(scores, cosineSimilarity(vector, documentVector))
+	}
+
+	sortByScore(documentIDs, scores)
+
+	if k < len(documentIDs) {
+		documentIDs = documentIDs[:k]
+		scores = scores[:k]
+	}
+
+	elapsedTime = time.Since(startTime)
+	return
+}
+
+// fuseRankings combines the text and vector rankings of a hybrid query into a single ranking.
+func fuseRankings(q HybridQuery, textIDs []string, textScores []float64, vectorIDs []string, vectorScores []float64) (sortedDocumentIDs []string, sortedScores []float64, details map[string]*ScoreDetails) {
+	textWeight, vectorWeight := q.TextWeight, q.VectorWeight
+	if textWeight == 0 && vectorWeight == 0 {
+		textWeight, vectorWeight = 1, 1
+	}
+
+	rankConstant := q.RankConstant
+	if rankConstant <= 0 {
+		rankConstant = DefaultRankConstant
+	}
+
+	details = make(map[string]*ScoreDetails)
+	for i, id := range textIDs {
+		details[id] = &ScoreDetails{TextScore: textScores[i], TextRank: i + 1}
+	}
+	for i, id := range vectorIDs {
+		d, ok := details[id]
+		if !ok {
+			d = &ScoreDetails{}
+			details[id] = d
+		}
+		d.VectorScore = vectorScores[i]
+		d.VectorRank = i + 1
+	}
+
+	maxTextScore := 0.0
+	for _, score := range textScores {
+		maxTextScore = math.Max(maxTextScore, score)
+	}
+
+	for id, d := range details {
+		score := 0.0
+
+		switch q.Fusion {
+		case LinearCombination:
+			if d.TextRank > 0 && maxTextScore > 0 {
+				score += textWeight * d.TextScore / maxTextScore
+			}
+			if d.VectorRank > 0 {
+				score += vectorWeight * (d.VectorScore + 1) / 2
+			}
+		default:
+			if d.TextRank > 0 {
+				score += textWeight / (rankConstant + float64(d.TextRank))
+			}
+			if d.VectorRank > 0 {
+				score += vectorWeight / (rankConstant + float64(d.VectorRank))
+			}
+		}
+
+		sortedDocumentIDs = append(sortedDocumentIDs, id)
+		sortedScores = append(sortedScores, score)
+	}
+
+	sortByScore(sortedDocumentIDs, sortedScores)
+	return
+}
+
+// sortByScore sorts document IDs collected from a map by their scores from the highest. Documents
+// with the same score are sorted by their IDs so that they come back in the same order every time.
+func sortByScore(documentIDs []string, scores []float64) {
+	order := make([]int, len(documentIDs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := order[i], order[j]
+		if scores[a] != scores[b] {
+			return scores[a] > scores[b]
+		}
+		return documentIDs[a] < documentIDs[b]
+	})
+
+	sortedIDs := make([]string, len(order))
+	sortedScores := make([]float64, len(order))
+	for i, j := range order {
+		sortedIDs[i] = documentIDs[j]
+		sortedScores[i] = scores[j]
+	}
+	copy(documentIDs, sortedIDs)
+	copy(scores, sortedScores)
+}
+
+// vectorFromValues converts field values into a vector. A single value is assumed to be a vector
+// whose components are separated by commas, as is the case for documents loaded from CSV.
+func vectorFromValues(values []string) (vector []float64, ok bool) {
+	if len(values) == 1 {
+		values = strings.Split(values[0], ",")
+	}
+	if len(values) == 0 {
+		return
+	}
+
+	vector = make([]float64, len(values))
+	for i, value := range values {
+		var err error
+
+		vector[i], err = strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return nil, false
+		}
+	}
+
+	ok = true
+	return
+}
+
+func cosineSimilarity(a, b []float64) (similarity float64) {
+	var dot, normA, normB float64
+
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return
+	}
+
+	similarity = dot / (math.Sqrt(normA) * math.Sqrt(normB))
+	return
+}
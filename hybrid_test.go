@@ -0,0 +1,147 @@
+package folder
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func hybridTestIndex() *Index {
+	index := New()
+	index.IndexWithID(map[string]interface{}{
+		"title":     "Drawing with pencils",
+		"embedding": []interface{}{1.0, 0.0},
+	}, "1")
+	index.IndexWithID(map[string]interface{}{
+		"title":     "Swimming in the sea",
+		"embedding": []interface{}{0.0, 1.0},
+	}, "2")
+	index.IndexWithID(map[string]interface{}{
+		"title":     "Drawing the sea",
+		"embedding": []float64{0.6, 0.8},
+	}, "3")
+	return index
+}
+
+func TestSearchKNN(t *testing.T) {
+	index := hybridTestIndex()
+
+	res, err := index.SearchKNN(KNNQuery{Field: "embedding", Vector: []float64{0.0, 1.0}, K: 2}, DefaultSearchOptions)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, res.Count)
+	assert.Equal(t, "2", res.Hits[0].ID)
+	assert.Equal(t, "3", res.Hits[1].ID)
+	assert.Equal(t, 1, res.Hits[0].Details.VectorRank)
+	assert.InDelta(t, 0.8, res.Hits[1].Details.VectorScore, 1e-9)
+}
+
+func TestSearchHybrid(t *testing.T) {
+	index := hybridTestIndex()
+
+	q := HybridQuery{
+		Text: "drawing",
+		KNN:  KNNQuery{Field: "embedding", Vector: []float64{0.0, 1.0}, K: 2},
+	}
+	res, err := index.SearchHybrid(q, DefaultSearchOptions)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, res.Count)
+	// Document 3 is found by both the text query and the kNN query
+	assert.Equal(t, "3", res.Hits[0].ID)
+	assert.True(t, res.Hits[0].Details.TextRank > 0)
+	assert.True(t, res.Hits[0].Details.VectorRank > 0)
+
+	q.Fusion = LinearCombination
+	q.VectorWeight = 0
+	q.TextWeight = 1
+	res, err = index.SearchHybrid(q, DefaultSearchOptions)
+	assert.Nil(t, err)
+	assert.Equal(t, 0.0, res.Hits[2].Score)
+	assert.Equal(t, "2", res.Hits[2].ID)
+}
+
+func TestSearchKNNFromShards(t *testing.T) {
+	dir, err := os.MkdirTemp("", "folder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	err = hybridTestIndex().SaveToShards(dir+"/index", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	index, err := LoadDeferred(dir + "/index")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := index.SearchKNN(KNNQuery{Field: "embedding", Vector: []float64{1.0, 0.0}, K: 1}, DefaultSearchOptions)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, res.Count)
+	assert.Equal(t, "1", res.Hits[0].ID)
+}
+
+func TestSearchHybridTimeout(t *testing.T) {
+	dir, err := os.MkdirTemp("", "folder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	err = hybridTestIndex().SaveToShards(dir+"/index", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	index, err := LoadDeferred(dir + "/index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = index.Search("drawing")
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// The shards loaded before the context is done are still searched
+	res, err := index.SearchContext(ctx, "drawing", DefaultSearchOptions)
+	assert.Nil(t, err)
+	assert.True(t, res.TimedOut)
+	assert.Equal(t, 2, len(res.Hits))
+
+	q := HybridQuery{
+		Text: "drawing",
+		KNN:  KNNQuery{Field: "embedding", Vector: []float64{0.0, 1.0}, K: 3},
+	}
+	res, err = index.SearchHybridContext(ctx, q, DefaultSearchOptions)
+	assert.Nil(t, err)
+	assert.True(t, res.TimedOut)
+	// Document 2 is not compared as its shard was never loaded
+	assert.Equal(t, 2, res.Count)
+	for _, hit := range res.Hits {
+		assert.True(t, hit.Details.TextRank > 0)
+		assert.True(t, hit.Details.VectorRank > 0)
+	}
+}
+
+func TestSearchKNNTies(t *testing.T) {
+	index := New()
+	for _, id := range []string{"c", "a", "d", "b"} {
+		_, err := index.IndexWithID(map[string]interface{}{"embedding": []float64{1.0, 1.0}}, id)
+		assert.Nil(t, err)
+	}
+
+	// Documents with the same score are sorted by their IDs every time
+	for i := 0; i < 10; i++ {
+		res, err := index.SearchKNN(KNNQuery{Field: "embedding", Vector: []float64{1.0, 0.0}}, DefaultSearchOptions)
+		assert.Nil(t, err)
+		var ids []string
+		for _, hit := range res.Hits {
+			ids = append(ids, hit.ID)
+		}
+		assert.Equal(t, []string{"a", "b", "c", "d"}, ids)
+	}
+}
@@ -0,0 +1,122 @@
+package folder
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// identifierSeparators are the runes that separate the words of identifiers such as snake_case,
+// kebab-case, dotted.paths, and std::namespaces.
+const identifierSeparators = "_-.:/"
+
+// isIdentifierRune returns whether a rune is part of an identifier other than a separator.
+func isIdentifierRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r) || r == '$' || r == '_'
+}
+
+// splitIdentifier returns the byte offsets of the words of an identifier. Words are separated by
+// separators and by changes of case, e.g. "parseHTTPResponse_v2" is made of "parse", "HTTP",
+// "Response", and "v2". Digits belong to the word before them.
+func splitIdentifier(s string) (words [][2]int) {
+	start := -1
+	var prev rune
+
+	for i, r := range s {
+		if strings.ContainsRune(identifierSeparators, r) {
+			if start >= 0 {
+				words = append(words, [2]int{start, i})
+			}
+			start, prev = -1, r
+			continue
+		}
+
+		if start >= 0 {
+			next, _ := utf8.DecodeRuneInString(s[i+utf8.RuneLen(r):])
+			switch {
+			case unicode.IsUpper(r) && (unicode.IsLower(prev) || unicode.IsDigit(prev)),
+				unicode.IsUpper(r) && unicode.IsUpper(prev) && unicode.IsLower(next):
+				words = append(words, [2]int{start, i})
+				start = i
+			}
+		} else {
+			start = i
+		}
+		prev = r
+	}
+
+	if start >= 0 {
+		words = append(words, [2]int{start, len(s)})
+	}
+	return
+}
+
+// IdentifierTokenFilter adds the words of tokens that are code identifiers such as camelCase,
+// PascalCase, snake_case, kebab-case, and dotted.paths after the tokens so that
+// "fetchDocumentFromShard" can be found by "fetch document shard". The first word shares the
+// position of its token and the other words take the following positions so that a query made of
+// an identifier still requires all of its words.
+func IdentifierTokenFilter(tokens []Token) (filteredTokens []Token) {
+	shift := 0
+	for _, token := range tokens {
+		token.Position += shift
+		filteredTokens = append(filteredTokens, token)
+
+		words := splitIdentifier(token.Term)
+		if len(words) == 0 || (len(words) == 1 && words[0] == [2]int{0, len(token.Term)}) {
+			continue
+		}
+
+		for i, word := range words {
+			part := token
+			part.Term = token.Term[word[0]:word[1]]
+			part.Position = token.Position + i
+			part.Start = token.Start + word[0]
+			part.End = token.Start + word[1]
+			part.BaseForm = ""
+			part.Reading = ""
+			filteredTokens = append(filteredTokens, part)
+		}
+		shift += len(words) - 1
+	}
+	return
+}
+
+// IdentifierTokenizer splits source code into identifiers, keeping dotted paths such as
+// "index.fetchDocument" together, and then adds the words of the identifiers like
+// IdentifierTokenFilter. Operators, brackets, and other punctuations are dropped.
+type IdentifierTokenizer struct{}
+
+func newIdentifierTokenizer(params []string) (Tokenizer, error) {
+	return &IdentifierTokenizer{}, nil
+}
+
+// Tokenize splits source code into tokens.
+func (tokenizer *IdentifierTokenizer) Tokenize(s string) (tokens []Token) {
+	start := -1
+	end := -1 // End of the last identifier rune, which excludes trailing separators
+
+	emit := func() {
+		if start >= 0 {
+			tokens = append(tokens, Token{Term: s[start:end], Type: TokenTypeWord, Position: len(tokens), Start: start, End: end})
+		}
+		start, end = -1, -1
+	}
+
+	for i, r := range s {
+		switch {
+		case isIdentifierRune(r):
+			if start < 0 {
+				start = i
+			}
+			end = i + utf8.RuneLen(r)
+		case start >= 0 && strings.ContainsRune(identifierSeparators, r):
+			// Separators are only kept between identifier runes
+		default:
+			emit()
+		}
+	}
+	emit()
+
+	return IdentifierTokenFilter(tokens)
+}
@@ -0,0 +1,49 @@
+package folder
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdentifierTokenFilter(t *testing.T) {
+	split := func(s string) []string {
+		return terms(IdentifierTokenFilter([]Token{{Term: s}}))
+	}
+	assert.Equal(t, []string{"fetchDocumentFromShard", "fetch", "Document", "From", "Shard"}, split("fetchDocumentFromShard"))
+	assert.Equal(t, []string{"parseHTTPResponse_v2", "parse", "HTTP", "Response", "v2"}, split("parseHTTPResponse_v2"))
+	assert.Equal(t, []string{"shard-count", "shard", "count"}, split("shard-count"))
+	assert.Equal(t, []string{"os.path.join", "os", "path", "join"}, split("os.path.join"))
+	assert.Equal(t, []string{"_private", "private"}, split("_private"))
+	assert.Equal(t, []string{"folder"}, split("folder"))
+
+	tokens := IdentifierTokenFilter([]Token{{Term: "snake_case", Start: 4, End: 14}, {Term: "next", Position: 1}})
+	assert.Equal(t, 1, tokens[2].Position)
+	assert.Equal(t, 10, tokens[2].Start)
+	assert.Equal(t, 2, tokens[3].Position)
+}
+
+func TestIdentifierTokenizer(t *testing.T) {
+	tokenizer := &IdentifierTokenizer{}
+	assert.Equal(t, []string{"err", "index.LoadDeferred", "index", "Load", "Deferred", "name"},
+		terms(tokenizer.Tokenize("err := index.LoadDeferred(name).")))
+}
+
+func TestIdentifierSearch(t *testing.T) {
+	index := New()
+	err := index.AddAnalyzer("code", AnalyzerConfig{
+		Tokenizer:    ComponentConfig{Type: "identifier"},
+		TokenFilters: []ComponentConfig{{Type: "lowercase"}},
+	})
+	assert.Nil(t, err)
+	index.SetFieldAnalyzer("snippet", "code")
+	index.IndexWithID(map[string]interface{}{"snippet": "func (index *Index) fetchDocumentFromShard(id string)"}, "1")
+	index.IndexWithID(map[string]interface{}{"snippet": "func fetchTermStats(shardID int)"}, "2")
+
+	for _, query := range []string{"fetch document shard", "fetchDocumentFromShard", "fetch_document"} {
+		res, _ := index.Search(query)
+		assert.Equal(t, 1, res.Count, query)
+	}
+	res, _ := index.Search("fetch")
+	assert.Equal(t, 2, res.Count)
+}
@@ -1,6 +1,7 @@
 package folder
 
 import (
+	"context"
 	"math"
 	"reflect"
 	"sort"
@@ -63,12 +64,30 @@ func setField(document map[string]interface{}, fieldPath, record string) {
 	}
 }
 
-// analyze analyzes an arbitrary value and returns the tokens for each field
-func (index *Index) analyze(parentField string, v interface{}, m map[string][]string) {
+// analyze analyzes an arbitrary value and returns the tokens for each field. Fields whose language
+// is detected are analyzed with the analyzer of their language.
+func (index *Index) analyze(parentField string, v interface{}, languages map[string]string, m map[string][]string) {
 	if m == nil {
 		return
 	}
 
+	if parentField != "" {
+		// The fields of nested objects are also analyzed as fields of the document itself
+		mapping, ok := index.Mapping.Fields[parentField]
+		if ok && mapping.Type != FieldTypeNested && (!mapping.Inferred || mapping.matches(parentField, v)) {
+			index.analyzeMapped(parentField, mapping, v, languages, m)
+			return
+		}
+
+		if index.Mapping.Dynamic == DynamicIgnore && isLeafValue(v) {
+			debug("  Ignore field " + parentField)
+			if _, ok := m[parentField]; !ok {
+				m[parentField] = []string{}
+			}
+			return
+		}
+	}
+
 	switch value := v.(type) {
 	case map[string]interface{}:
 		if len(parentField) > 0 {
@@ -78,46 +97,105 @@ func (index *Index) analyze(parentField string, v interface{}, m map[string][]st
 			if parentField != "" {
 				field = parentField + "." + field
 			}
-			index.analyze(field, value, m)
+			index.analyze(field, value, languages, m)
 		}
 	case []map[string]interface{}:
 		debug("  Analyze field " + parentField + ": []map[string]interface{}")
 		for _, v := range value {
-			index.analyze(parentField, v, m)
+			index.analyze(parentField, v, languages, m)
 		}
 	case []interface{}:
 		debug("  Analyze field " + parentField + ": []interface{}")
 		for _, v := range value {
-			index.analyze(parentField, v, m)
+			index.analyze(parentField, v, languages, m)
 		}
 	case []string:
 		debug("  Analyze field " + parentField + ": []string")
 		tokens := []string{}
+		analyzerName := index.fieldAnalyzerIn(parentField, languages)
 		for _, v := range value {
-			tokens = append(tokens, index.Analyze(v)...)
+			tokens = append(tokens, index.analyzeWith(analyzerName, v)...)
 		}
 		m[parentField] = append(m[parentField], tokens...)
 	case *string:
 		debug("  Analyze field " + parentField + ": *string")
 		if value != nil {
-			m[parentField] = append(m[parentField], index.Analyze(*value)...)
+			m[parentField] = append(m[parentField], index.analyzeWith(index.fieldAnalyzerIn(parentField, languages), *value)...)
 		}
 	case string:
 		debug("  Analyze field " + parentField + ": string")
-		m[parentField] = append(m[parentField], index.Analyze(value)...)
+		m[parentField] = append(m[parentField], index.analyzeWith(index.fieldAnalyzerIn(parentField, languages), value)...)
+	case float64, []float64:
+		// Numbers are not tokenized but the field is kept so that values such as vectors are saved
+		debug("  Analyze field " + parentField + ": number")
+		if _, ok := m[parentField]; !ok {
+			m[parentField] = []string{}
+		}
 	case int:
 		// TODO
 	}
 }
 
-func (index *Index) index(documentID string, document map[string]interface{}) (err error) {
+// isLeafValue returns whether a value is not an object or an array that may contain objects.
+func isLeafValue(v interface{}) bool {
+	switch v.(type) {
+	case map[string]interface{}, []map[string]interface{}, []interface{}:
+		return false
+	}
+	return true
+}
+
+// analyzeMapped analyzes the value of a field in the mapping. Text fields are analyzed into terms
+// while the values of exact fields become a single term each. Terms are repeated as many times as
+// the boost of the field.
+func (index *Index) analyzeMapped(field string, mapping FieldMapping, v interface{}, languages map[string]string, m map[string][]string) {
+	debug("  Analyze field "+field+":", mapping.Type)
+
+	// The field is kept even without terms so that its values are saved
+	if _, ok := m[field]; !ok {
+		m[field] = []string{}
+	}
+
+	values, err := mapping.values(field, v)
+	if err != nil {
+		debug("  Failed to analyze", field+":", err)
+		return
+	}
+
+	var tokens []string
+	switch {
+	case mapping.Type == FieldTypeText:
+		analyzerName := index.fieldAnalyzerIn(field, languages)
+		for _, value := range values {
+			tokens = append(tokens, index.analyzeWith(analyzerName, value)...)
+		}
+	case mapping.Type.exact():
+		for _, value := range values {
+			tokens = append(tokens, exactTerm(field, value))
+		}
+	}
+
+	for i := 0; i < mapping.frequency(); i++ {
+		m[field] = append(m[field], tokens...)
+	}
+}
+
+func (index *Index) index(documentID string, document map[string]interface{}, languages map[string]string) (err error) {
 	debug("Index", documentID)
 	m := make(map[string][]string)
-	index.analyze("", document, m)
+	index.analyze("", document, languages, m)
 	for field, tokens := range m {
 		debug("  Index field", field)
 		index.indexTokens(documentID, field, tokens)
 	}
+
+	// Objects of nested fields are indexed on their own as well
+	for objectID, terms := range index.analyzeNested(documentID, document, languages) {
+		err = index.updateTermStat(objectID, terms)
+		if err != nil {
+			return
+		}
+	}
 	return
 }
 
@@ -146,7 +224,7 @@ func (index *Index) updateTermStat(documentID string, tokens []string) (err erro
 	}
 
 	for _, token := range tokens {
-		termStat, ok, err = index.fetchTermStat(token)
+		termStat, ok, err = index.fetchTermStat(context.Background(), token)
 		if err != nil {
 			return
 		}
@@ -172,7 +250,7 @@ func (index *Index) removeDocumentFromTermStats(documentID string, tokens []stri
 	debug("  Remove document ID", documentID, "from term stats with tokens", tokens)
 
 	for _, token := range tokens {
-		termStat, ok, err = index.fetchTermStat(token)
+		termStat, ok, err = index.fetchTermStat(context.Background(), token)
 		if err != nil {
 			return
 		}
@@ -187,6 +265,65 @@ func (index *Index) removeDocumentFromTermStats(documentID string, tokens []stri
 	return
 }
 
+// analyzeUnstored returns the distinct terms of the values of a document that are indexed but not
+// stored, by the ID of the document or of its nested objects.
+func (index *Index) analyzeUnstored(documentID string, document map[string]interface{}, languages map[string]string) (terms map[string][]string) {
+	unstoredFields := index.Mapping.unstoredFields()
+	isUnstored := func(field string) bool {
+		for _, unstoredField := range unstoredFields {
+			if field == unstoredField || strings.HasPrefix(field, unstoredField+".") {
+				return true
+			}
+		}
+		return false
+	}
+
+	m := make(map[string][]string)
+	index.analyze("", document, languages, m)
+
+	documentTerms := MakeStringSet([]string{})
+	for field, tokens := range m {
+		if isUnstored(field) {
+			for _, token := range tokens {
+				documentTerms.Add(token)
+			}
+		}
+	}
+
+	terms = make(map[string][]string)
+	if list := documentTerms.List(); len(list) > 0 {
+		terms[documentID] = list
+	}
+	for objectID, objectTerms := range index.analyzeNestedFields(documentID, document, languages, isUnstored) {
+		set := MakeStringSet(objectTerms)
+		terms[objectID] = set.List()
+	}
+	return
+}
+
+// removeDocumentFromAllTermStats removes a document and its nested objects from every term stat,
+// loading every term stats shard first.
+func (index *Index) removeDocumentFromAllTermStats(documentID string) (err error) {
+	debug("  Remove document ID", documentID, "from every term stat")
+
+	for i := 0; i < index.ShardCount; i++ {
+		err = index.loadTermStatsFromShard(context.Background(), uint32(i))
+		if err != nil {
+			return
+		}
+	}
+
+	for _, termStat := range index.TermStats {
+		delete(termStat.TermFrequencies, documentID)
+		for id := range termStat.TermFrequencies {
+			if strings.HasPrefix(id, documentID+"\x00") {
+				delete(termStat.TermFrequencies, id)
+			}
+		}
+	}
+	return
+}
+
 func (index *Index) nextDocumentID() (id string) {
 	for {
 		id = generateRandomID(8)
@@ -197,28 +334,88 @@ func (index *Index) nextDocumentID() (id string) {
 	return
 }
 
+// matchQuery finds the document IDs matching any of the analyses of a query and returns them along
+// with the distinct tokens of every analysis which are used for scoring. If the context is done, the
+// documents found so far are returned along with the context error.
+func (index *Index) matchQuery(ctx context.Context, s string) (documentIDs []string, tokens []string, elapsedTime time.Duration, err error) {
+	var ids []string
+
+	startTime := time.Now()
+	documentIDsSet := MakeStringSet([]string{})
+	tokensSet := MakeStringSet([]string{})
+
+	for _, groups := range index.analyzeQuery(s) {
+		// The documents found before the context is done are kept for the partial result
+		ids, _, err = index.findDocumentsInGroups(ctx, groups)
+
+		for _, id := range ids {
+			documentIDsSet.Add(id)
+		}
+		for _, group := range groups {
+			for _, token := range group {
+				if !tokensSet.Contains(token) {
+					tokensSet.Add(token)
+					tokens = append(tokens, token)
+				}
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	documentIDs = documentIDsSet.List()
+	elapsedTime = time.Since(startTime)
+	return
+}
+
 // findDocuments finds document IDs which contain the tokens. The more tokens provided, the fewer number of documents would be found as they are narrowed down.
-func (index *Index) findDocuments(tokens []string) (documentIDs []string, elapsedTime time.Duration, err error) {
+func (index *Index) findDocuments(ctx context.Context, tokens []string) (documentIDs []string, elapsedTime time.Duration, err error) {
+	groups := make([][]string, len(tokens))
+	for i, token := range tokens {
+		groups[i] = []string{token}
+	}
+	return index.findDocumentsInGroups(ctx, groups)
+}
+
+// findDocumentsInGroups finds document IDs which contain at least one token of every group of
+// tokens. Groups whose tokens are not found in any document are ignored. If the context is done, the
+// documents containing the groups checked so far are returned along with the context error.
+func (index *Index) findDocumentsInGroups(ctx context.Context, groups [][]string) (documentIDs []string, elapsedTime time.Duration, err error) {
 	var documentIDsSet StringSet
 	var termStat TermStat
 	var ok bool
 
 	startTime := time.Now()
-	debug("  Find document IDs with tokens", tokens)
+	debug("  Find document IDs with token groups", groups)
 
-	for _, token := range tokens {
+	defer func() {
+		documentIDs = documentIDsSet.List()
+		elapsedTime = time.Since(startTime)
+	}()
+
+	for _, group := range groups {
 		ids := MakeStringSet([]string{})
+		found := false
+
+		for _, token := range group {
+			// Only loading shards stops once the context is done so that the terms already loaded are
+			// still found
+			termStat, ok, err = index.fetchTermStat(ctx, token)
+			if err != nil {
+				return
+			}
+			if !ok {
+				continue
+			}
 
-		termStat, ok, err = index.fetchTermStat(token)
-		if !ok {
-			continue
-		}
-		if err != nil {
-			return
+			found = true
+			for id, _ := range termStat.TermFrequencies {
+				ids.Add(id)
+			}
 		}
-
-		for id, _ := range termStat.TermFrequencies {
-			ids.Add(id)
+		if !found {
+			continue
 		}
 
 		if documentIDsSet.Len() == 0 {
@@ -229,20 +426,17 @@ func (index *Index) findDocuments(tokens []string) (documentIDs []string, elapse
 			documentIDsSet.Intersects(ids)
 		}
 	}
-
-	documentIDs = documentIDsSet.List()
-	elapsedTime = time.Since(startTime)
 	return
 }
 
-func (index *Index) fetchTermStat(token string) (termStat TermStat, ok bool, err error) {
+func (index *Index) fetchTermStat(ctx context.Context, token string) (termStat TermStat, ok bool, err error) {
 	termStat, ok = index.TermStats[token]
 	if ok || index.ShardCount == 0 {
 		return
 	}
 
 	shardID := index.CalculateShardID(token)
-	err = index.loadTermStatsFromShard(shardID)
+	err = index.loadTermStatsFromShard(ctx, shardID)
 	if err != nil {
 		return
 	}
@@ -251,17 +445,28 @@ func (index *Index) fetchTermStat(token string) (termStat TermStat, ok bool, err
 	return
 }
 
-func (index *Index) sortDocuments(documentIDs []string, tokens []string) (sortedDocumentIDs []string, sortedScores []float64, elapsedTime time.Duration, err error) {
+// sortDocuments sorts document IDs by their scores. If the context is done before every document is
+// scored, the rest of the documents are scored with the term stats loaded so far and the context
+// error is returned along with the documents.
+func (index *Index) sortDocuments(ctx context.Context, documentIDs []string, tokens []string) (sortedDocumentIDs []string, sortedScores []float64, elapsedTime time.Duration, err error) {
 	startTime := time.Now()
 
 	debug("  Sort", len(documentIDs), "documents with tokens", tokens)
 
 	scores := make([]float64, len(documentIDs))
 	for i, id := range documentIDs {
-		scores[i], err = index.CalculateScore(id, tokens)
-		if err != nil {
-			return
+		if ctx.Err() == nil {
+			scores[i], err = index.calculateScore(ctx, id, tokens)
+			if err == nil {
+				continue
+			}
+			if ctx.Err() == nil {
+				return
+			}
 		}
+
+		scores[i] = index.calculateLoadedScore(id, tokens)
+		err = ctx.Err()
 	}
 	idScores := IDScores{IDs: documentIDs, Scores: scores}
 	sort.Sort(sort.Reverse(idScores))
@@ -274,10 +479,26 @@ func (index *Index) sortDocuments(documentIDs []string, tokens []string) (sorted
 }
 
 func (index *Index) CalculateScore(documentID string, tokens []string) (score float64, err error) {
+	return index.calculateScore(context.Background(), documentID, tokens)
+}
+
+// calculateLoadedScore calculates the score of a document like calculateScore but only with the
+// term stats that are already loaded, for when shards can no longer be loaded.
+func (index *Index) calculateLoadedScore(documentID string, tokens []string) (score float64) {
+	for _, token := range tokens {
+		tf := index.TermStats[token].TermFrequencies[documentID]
+		if tf > 0 {
+			score += float64(tf) * float64(index.inverseDocumentFrequency(token))
+		}
+	}
+	return
+}
+
+func (index *Index) calculateScore(ctx context.Context, documentID string, tokens []string) (score float64, err error) {
 	var tf int
 
 	for _, token := range tokens {
-		tf, err = index.termFrequency(documentID, token)
+		tf, err = index.termFrequency(ctx, documentID, token)
 		if err != nil {
 			return
 		}
@@ -288,7 +509,9 @@ func (index *Index) CalculateScore(documentID string, tokens []string) (score fl
 	return
 }
 
-func (index *Index) fetchHits(documentIDs []string, scores []float64, size, from int) (hits []Hit, err error) {
+// fetchHits fetches the documents of a page of document IDs. If an error occurs, the hits fetched so
+// far are returned along with the error.
+func (index *Index) fetchHits(ctx context.Context, documentIDs []string, scores []float64, size, from int) (hits []Hit, err error) {
 	var document map[string]interface{}
 
 	if from < 0 {
@@ -306,28 +529,29 @@ func (index *Index) fetchHits(documentIDs []string, scores []float64, size, from
 
 	hits = make([]Hit, 0)
 	for i, documentID := range documentIDs[from : from+n] {
-		document, _, err = index.fetchDocument(documentID)
+		document, _, err = index.fetchDocument(ctx, documentID)
 		if err != nil {
 			return
 		}
 
 		hits = append(hits, Hit{
-			ID:     documentID,
-			Score:  scores[from+i],
-			Source: document,
+			ID:      documentID,
+			Score:   scores[from+i],
+			Version: index.Versions[documentID],
+			Source:  document,
 		})
 	}
 	return
 }
 
-func (index *Index) fetchDocument(documentID string) (document map[string]interface{}, ok bool, err error) {
+func (index *Index) fetchDocument(ctx context.Context, documentID string) (document map[string]interface{}, ok bool, err error) {
 	document, ok = index.Documents[documentID]
 	if ok || index.ShardCount == 0 {
 		return
 	}
 
 	shardID := index.CalculateShardID(documentID)
-	err = index.loadDocumentsFromShard(shardID)
+	err = index.loadDocumentsFromShard(ctx, shardID)
 	if err != nil {
 		return
 	}
@@ -337,11 +561,11 @@ func (index *Index) fetchDocument(documentID string) (document map[string]interf
 }
 
 // termFrequency returns the number of times a token appears in a certain document
-func (index *Index) termFrequency(documentID, token string) (frequency int, err error) {
+func (index *Index) termFrequency(ctx context.Context, documentID, token string) (frequency int, err error) {
 	var termStat TermStat
 	var ok bool
 
-	termStat, _, err = index.fetchTermStat(token)
+	termStat, _, err = index.fetchTermStat(ctx, token)
 	if err != nil {
 		return
 	}
@@ -389,6 +613,10 @@ func fieldValuesFromMapStringInterface(document map[string]interface{}, fields [
 		values = []string{*t}
 	case []string:
 		values = append(values, t...)
+	case []float64:
+		for _, v := range t {
+			values = append(values, strconv.FormatFloat(v, 'g', -1, 64))
+		}
 	case []map[string]interface{}:
 		for _, node := range t {
 			values = append(values, fieldValuesFromMapStringInterface(node, fields, depth+1)...)
@@ -396,9 +624,13 @@ func fieldValuesFromMapStringInterface(document map[string]interface{}, fields [
 	case map[string]interface{}:
 		values = append(values, fieldValuesFromMapStringInterface(t, fields, depth+1)...)
 	case float64:
-		values = append(values, strconv.FormatFloat(t, 'g', 'g', 64))
+		values = append(values, strconv.FormatFloat(t, 'g', -1, 64))
 	case int:
 		values = append(values, strconv.FormatInt(int64(t), 10))
+	case bool:
+		values = append(values, strconv.FormatBool(t))
+	case time.Time:
+		values = append(values, t.Format(time.RFC3339Nano))
 	default:
 		debug("fieldValuesFromMapStringInterface(): Unimplemented for", reflect.TypeOf(t))
 	}
@@ -414,6 +646,10 @@ func fieldValuesFromArrayInterface(node []interface{}, fields []string, depth in
 			values = append(values, *value)
 		case []string:
 			values = append(values, value...)
+		case float64:
+			values = append(values, strconv.FormatFloat(value, 'g', -1, 64))
+		case bool:
+			values = append(values, strconv.FormatBool(value))
 		case []interface{}:
 			values = append(values, fieldValuesFromArrayInterface(value, fields, depth)...)
 		case map[string]interface{}:
@@ -64,7 +64,7 @@ func TestInternalAnalyze(t *testing.T) {
 	}
 	m := make(map[string][]string)
 	index := New()
-	index.analyze("", testDocument, m)
+	index.analyze("", testDocument, nil, m)
 	assert.Equal(t, m, expectedResult)
 }
 
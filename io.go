@@ -4,7 +4,10 @@
 package folder
 
 import (
+	"context"
 	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
@@ -20,6 +23,8 @@ const (
 	FieldNamesFileExtension = "fns"
 	DocumentsFileExtension  = "dcs"
 	TermStatsFileExtension  = "tst"
+	AnalysisFileExtension   = "als"
+	MappingFileExtension    = "mps"
 	ShardCountFileName      = "shard_count"
 )
 
@@ -35,6 +40,16 @@ func Load(indexName string) (index *Index, err error) {
 		return
 	}
 
+	err = index.loadAnalysis()
+	if err != nil {
+		return
+	}
+
+	err = index.loadMapping()
+	if err != nil {
+		return
+	}
+
 	err = index.loadDocuments()
 	if err != nil {
 		return
@@ -50,15 +65,31 @@ func Load(indexName string) (index *Index, err error) {
 
 // LoadDeferred loads an index metadata only the rest of the data is loaded when needed.
 func LoadDeferred(indexName string) (index *Index, err error) {
+	return LoadDeferredContext(context.Background(), indexName)
+}
+
+// LoadDeferredContext loads an index metadata just like LoadDeferred but stops once the context is
+// done.
+func LoadDeferredContext(ctx context.Context, indexName string) (index *Index, err error) {
 	index = New()
 	index.Name = indexName
 
-	err = index.loadShardCount()
+	err = index.loadShardCount(ctx)
 	if err != nil {
 		return
 	}
 
-	err = index.loadFieldNamesDeferred()
+	err = index.loadFieldNamesDeferred(ctx)
+	if err != nil {
+		return
+	}
+
+	err = index.loadAnalysisDeferred(ctx)
+	if err != nil {
+		return
+	}
+
+	err = index.loadMappingDeferred(ctx)
 	if err != nil {
 		return
 	}
@@ -66,9 +97,14 @@ func LoadDeferred(indexName string) (index *Index, err error) {
 	return
 }
 
-func (index *Index) loadShardCount() (err error) {
+func (index *Index) loadShardCount(ctx context.Context) (err error) {
 	var file *os.File
 
+	err = ctx.Err()
+	if err != nil {
+		return
+	}
+
 	url := fmt.Sprintf("%s/shard_count", index.Name)
 	file, err = os.Open(url)
 	if err != nil {
@@ -80,7 +116,7 @@ func (index *Index) loadShardCount() (err error) {
 	return
 }
 
-func (index *Index) loadShardCountFS(f fs.FS) (err error) {
+func (index *Index) loadShardCountFS(ctx context.Context, f fs.FS) (err error) {
 	dirPath := index.Name
 
 	err = fs.WalkDir(f, dirPath, func(path string, d fs.DirEntry, e error) (err error) {
@@ -89,6 +125,11 @@ func (index *Index) loadShardCountFS(f fs.FS) (err error) {
 			return
 		}
 
+		err = ctx.Err()
+		if err != nil {
+			return
+		}
+
 		if !d.IsDir() {
 			return
 		}
@@ -119,6 +160,16 @@ func LoadFS(f fs.FS, indexName string) (index *Index, err error) {
 		return
 	}
 
+	err = index.loadAnalysisFS(f)
+	if err != nil {
+		return
+	}
+
+	err = index.loadMappingFS(f)
+	if err != nil {
+		return
+	}
+
 	err = index.loadDocumentsFS(f)
 	if err != nil {
 		return
@@ -134,16 +185,32 @@ func LoadFS(f fs.FS, indexName string) (index *Index, err error) {
 
 // LoadDeferredFS loads an index metadata only the rest of the data is loaded when needed.
 func LoadDeferredFS(f fs.FS, indexName string) (index *Index, err error) {
+	return LoadDeferredFSContext(context.Background(), f, indexName)
+}
+
+// LoadDeferredFSContext loads an index metadata just like LoadDeferredFS but stops once the context
+// is done.
+func LoadDeferredFSContext(ctx context.Context, f fs.FS, indexName string) (index *Index, err error) {
 	index = New()
 	index.Name = indexName
 	index.f = f
 
-	err = index.loadShardCountFS(f)
+	err = index.loadShardCountFS(ctx, f)
+	if err != nil {
+		return
+	}
+
+	err = index.loadFieldNamesDeferred(ctx)
+	if err != nil {
+		return
+	}
+
+	err = index.loadAnalysisDeferred(ctx)
 	if err != nil {
 		return
 	}
 
-	err = index.loadFieldNamesDeferred()
+	err = index.loadMappingDeferred(ctx)
 	if err != nil {
 		return
 	}
@@ -157,12 +224,22 @@ func LoadWithProgressFS(f fs.FS, indexName string, progressCallback ProgressCall
 	index.Name = indexName
 	index.f = f
 
-	err = index.loadShardCountFS(f)
+	err = index.loadShardCountFS(context.Background(), f)
 	if err != nil {
 		return
 	}
 
-	err = index.loadFieldNamesDeferred()
+	err = index.loadFieldNamesDeferred(context.Background())
+	if err != nil {
+		return
+	}
+
+	err = index.loadAnalysisDeferred(context.Background())
+	if err != nil {
+		return
+	}
+
+	err = index.loadMappingDeferred(context.Background())
 	if err != nil {
 		return
 	}
@@ -188,9 +265,14 @@ func (index *Index) loadFieldNames() (err error) {
 	return
 }
 
-func (index *Index) loadFieldNamesDeferred() (err error) {
+func (index *Index) loadFieldNamesDeferred(ctx context.Context) (err error) {
 	var file fs.File
 
+	err = ctx.Err()
+	if err != nil {
+		return
+	}
+
 	dirPath := index.Name
 	filePath := fmt.Sprintf("%s/%s", dirPath, FieldNamesFileExtension)
 	if index.f == nil {
@@ -220,20 +302,151 @@ func (index *Index) loadFieldNamesFS(f fs.FS) (err error) {
 	return
 }
 
+func (index *Index) loadAnalysis() (err error) {
+	var file *os.File
+
+	file, err = os.Open(fmt.Sprintf("%s.%s", index.Name, AnalysisFileExtension))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			index.useLegacyAnalysis()
+			err = nil
+		}
+		return
+	}
+	defer file.Close()
+
+	err = index.loadAnalysisFromReader(file)
+	return
+}
+
+func (index *Index) loadAnalysisDeferred(ctx context.Context) (err error) {
+	var file fs.File
+
+	err = ctx.Err()
+	if err != nil {
+		return
+	}
+
+	filePath := fmt.Sprintf("%s/%s", index.Name, AnalysisFileExtension)
+	if index.f == nil {
+		file, err = os.Open(filePath)
+	} else {
+		file, err = index.f.Open(filePath)
+	}
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			index.useLegacyAnalysis()
+			err = nil
+		}
+		return
+	}
+	defer file.Close()
+
+	err = index.loadAnalysisFromReader(file)
+	return
+}
+
+func (index *Index) loadAnalysisFS(f fs.FS) (err error) {
+	var file fs.File
+
+	file, err = f.Open(fmt.Sprintf("%s.%s", index.Name, AnalysisFileExtension))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			index.useLegacyAnalysis()
+			err = nil
+		}
+		return
+	}
+	defer file.Close()
+
+	err = index.loadAnalysisFromReader(file)
+	return
+}
+
+func (index *Index) loadMapping() (err error) {
+	var file *os.File
+
+	file, err = os.Open(fmt.Sprintf("%s.%s", index.Name, MappingFileExtension))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			// Indexes saved before mappings existed add their fields dynamically
+			err = nil
+		}
+		return
+	}
+	defer file.Close()
+
+	err = index.loadMappingFromReader(file)
+	return
+}
+
+func (index *Index) loadMappingDeferred(ctx context.Context) (err error) {
+	var file fs.File
+
+	err = ctx.Err()
+	if err != nil {
+		return
+	}
+
+	filePath := fmt.Sprintf("%s/%s", index.Name, MappingFileExtension)
+	if index.f == nil {
+		file, err = os.Open(filePath)
+	} else {
+		file, err = index.f.Open(filePath)
+	}
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			// Indexes saved before mappings existed add their fields dynamically
+			err = nil
+		}
+		return
+	}
+	defer file.Close()
+
+	err = index.loadMappingFromReader(file)
+	return
+}
+
+func (index *Index) loadMappingFS(f fs.FS) (err error) {
+	var file fs.File
+
+	file, err = f.Open(fmt.Sprintf("%s.%s", index.Name, MappingFileExtension))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			// Indexes saved before mappings existed add their fields dynamically
+			err = nil
+		}
+		return
+	}
+	defer file.Close()
+
+	err = index.loadMappingFromReader(file)
+	return
+}
+
 func (index *Index) LoadAllShards(progressCallback ProgressCallback, sleepDuration time.Duration) (err error) {
+	return index.LoadAllShardsContext(context.Background(), progressCallback, sleepDuration)
+}
+
+// LoadAllShardsContext loads every shard just like LoadAllShards but stops once the context is done.
+func (index *Index) LoadAllShardsContext(ctx context.Context, progressCallback ProgressCallback, sleepDuration time.Duration) (err error) {
 	for i := 0; i < index.ShardCount; i++ {
-		err = index.loadDocumentsFromShard(uint32(i))
+		err = index.loadDocumentsFromShard(ctx, uint32(i))
 		if err != nil {
 			return
 		}
 
-		err = index.loadTermStatsFromShard(uint32(i))
+		err = index.loadTermStatsFromShard(ctx, uint32(i))
 		if err != nil {
 			return
 		}
 
 		progressCallback(i+1, index.ShardCount)
-		time.Sleep(sleepDuration)
+
+		err = sleepContext(ctx, sleepDuration)
+		if err != nil {
+			return
+		}
 	}
 	return
 }
@@ -251,13 +464,19 @@ func (index *Index) loadDocuments() (err error) {
 	return
 }
 
-func (index *Index) fetchDocumentFromShard(shardID uint32, documentID string) (document map[string]interface{}, err error) {
+func (index *Index) fetchDocumentFromShard(ctx context.Context, shardID uint32, documentID string) (document map[string]interface{}, version uint64, err error) {
 	var file fs.File
 
+	// Loaded shards can still be used once the context is done
 	if _, ok := index.LoadedDocumentsShards[shardID]; ok {
 		return
 	}
 
+	err = ctx.Err()
+	if err != nil {
+		return
+	}
+
 	filePath := fmt.Sprintf("%s/%d/%s", index.Name, shardID, DocumentsFileExtension)
 	debug("  Fetching document from shard: ", filePath)
 
@@ -267,11 +486,14 @@ func (index *Index) fetchDocumentFromShard(shardID uint32, documentID string) (d
 		file, err = index.f.Open(filePath)
 	}
 	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
 		return
 	}
 	defer file.Close()
 
-	document, err = index.fetchDocumentFromReader(file, documentID)
+	document, version, err = index.fetchDocumentFromReader(file, documentID)
 	if err != nil {
 		return
 	}
@@ -279,13 +501,19 @@ func (index *Index) fetchDocumentFromShard(shardID uint32, documentID string) (d
 	return
 }
 
-func (index *Index) loadDocumentsFromShard(shardID uint32) (err error) {
+func (index *Index) loadDocumentsFromShard(ctx context.Context, shardID uint32) (err error) {
 	var file fs.File
 
+	// Loaded shards can still be used once the context is done
 	if _, ok := index.LoadedDocumentsShards[shardID]; ok {
 		return
 	}
 
+	err = ctx.Err()
+	if err != nil {
+		return
+	}
+
 	filePath := fmt.Sprintf("%s/%d/%s", index.Name, shardID, DocumentsFileExtension)
 	debug("  Loading documents shard: ", filePath)
 
@@ -295,6 +523,11 @@ func (index *Index) loadDocumentsFromShard(shardID uint32) (err error) {
 		file, err = index.f.Open(filePath)
 	}
 	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			// Shards without any data are not saved
+			index.LoadedDocumentsShards[shardID] = struct{}{}
+			err = nil
+		}
 		return
 	}
 	defer file.Close()
@@ -327,16 +560,6 @@ func (index *Index) loadDocumentsFS(f fs.FS) (err error) {
 	return
 }
 
-func documentFromRecord(headers, record []string) (document map[string]interface{}) {
-	document = make(map[string]interface{})
-
-	for i, header := range headers {
-		setField(document, header, record[i])
-	}
-
-	return
-}
-
 func (index *Index) loadTermStats() (err error) {
 	var file *os.File
 
@@ -350,13 +573,19 @@ func (index *Index) loadTermStats() (err error) {
 	return
 }
 
-func (index *Index) loadTermStatsFromShard(shardID uint32) (err error) {
+func (index *Index) loadTermStatsFromShard(ctx context.Context, shardID uint32) (err error) {
 	var file fs.File
 
+	// Loaded shards can still be used once the context is done
 	if _, ok := index.LoadedTermStatsShards[shardID]; ok {
 		return
 	}
 
+	err = ctx.Err()
+	if err != nil {
+		return
+	}
+
 	filePath := fmt.Sprintf("%s/%d/%s", index.Name, shardID, TermStatsFileExtension)
 	debug("  Loading term stats shard: ", filePath)
 
@@ -366,6 +595,11 @@ func (index *Index) loadTermStatsFromShard(shardID uint32) (err error) {
 		file, err = index.f.Open(filePath)
 	}
 	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			// Shards without any data are not saved
+			index.LoadedTermStatsShards[shardID] = struct{}{}
+			err = nil
+		}
 		return
 	}
 	defer file.Close()
@@ -401,6 +635,16 @@ func (index *Index) Save(indexName string) (err error) {
 		return
 	}
 
+	err = index.saveAnalysis()
+	if err != nil {
+		return
+	}
+
+	err = index.saveMapping()
+	if err != nil {
+		return
+	}
+
 	err = index.saveDocuments()
 	if err != nil {
 		return
@@ -428,6 +672,16 @@ func (index *Index) SaveToShards(indexName string, shardCount int) (err error) {
 		return
 	}
 
+	err = index.saveAnalysisToShards()
+	if err != nil {
+		return
+	}
+
+	err = index.saveMappingToShards()
+	if err != nil {
+		return
+	}
+
 	err = index.saveDocumentsToShards()
 	if err != nil {
 		return
@@ -438,9 +692,48 @@ func (index *Index) SaveToShards(indexName string, shardCount int) (err error) {
 		return
 	}
 
+	// The shards now contain what is in memory so loading them again would only undo later changes
+	for i := 0; i < shardCount; i++ {
+		index.LoadedDocumentsShards[uint32(i)] = struct{}{}
+		index.LoadedTermStatsShards[uint32(i)] = struct{}{}
+	}
 	return
 }
 
+// Migrate rewrites the files of an index saved by an older version in the current format, such as
+// documents files that flattened every document into strings. Indexes saved to shards are rewritten
+// with the same shard count.
+//
+// Only the values of number, boolean, and vector fields in the mapping are converted back, and older
+// indexes have no mapping unless one is added before migrating. Values of other fields, including
+// numbers and arrays joined by commas, stay strings as they were stored and indexed.
+func Migrate(indexName string) (err error) {
+	var index *Index
+
+	_, err = os.Stat(fmt.Sprintf("%s.%s", indexName, DocumentsFileExtension))
+	if err == nil {
+		index, err = Load(indexName)
+		if err != nil {
+			return
+		}
+		return index.Save(indexName)
+	}
+
+	index, err = LoadDeferred(indexName)
+	if err != nil {
+		return
+	}
+
+	err = index.LoadAllShards(func(loadedShardsCount, totalShardsCount int) {
+		debug("Migrate", indexName, loadedShardsCount, "of", totalShardsCount, "shards loaded")
+	}, 0)
+	if err != nil {
+		return
+	}
+
+	return index.SaveToShards(indexName, index.ShardCount)
+}
+
 func (index *Index) saveShardCount() (err error) {
 	var file *os.File
 
@@ -510,6 +803,86 @@ func (index *Index) saveFieldNamesToShards() (err error) {
 	return
 }
 
+func (index *Index) saveAnalysis() (err error) {
+	var file *os.File
+
+	file, err = os.OpenFile(fmt.Sprintf("%s.%s", index.Name, AnalysisFileExtension), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	err = index.saveAnalysisToWriter(file)
+	return
+}
+
+func (index *Index) saveAnalysisToShards() (err error) {
+	var file *os.File
+
+	dirPath := index.Name
+	err = os.MkdirAll(dirPath, 0700)
+	if err != nil {
+		return
+	}
+
+	filePath := fmt.Sprintf("%s/%s", dirPath, AnalysisFileExtension)
+	file, err = os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	err = index.saveAnalysisToWriter(file)
+	return
+}
+
+func (index *Index) saveMapping() (err error) {
+	var file *os.File
+
+	file, err = os.OpenFile(fmt.Sprintf("%s.%s", index.Name, MappingFileExtension), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	err = index.saveMappingToWriter(file)
+	return
+}
+
+func (index *Index) saveMappingToShards() (err error) {
+	var file *os.File
+
+	dirPath := index.Name
+	err = os.MkdirAll(dirPath, 0700)
+	if err != nil {
+		return
+	}
+
+	filePath := fmt.Sprintf("%s/%s", dirPath, MappingFileExtension)
+	file, err = os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	err = index.saveMappingToWriter(file)
+	return
+}
+
+// savedDocumentIDs returns the IDs of the documents in the index along with the IDs of the deleted
+// documents whose versions are kept.
+func (index *Index) savedDocumentIDs() (documentIDs []string) {
+	for documentID := range index.Documents {
+		documentIDs = append(documentIDs, documentID)
+	}
+	for documentID := range index.Versions {
+		if _, ok := index.Documents[documentID]; !ok {
+			documentIDs = append(documentIDs, documentID)
+		}
+	}
+	return
+}
+
 func (index *Index) saveDocuments() (err error) {
 	var file *os.File
 
@@ -520,13 +893,15 @@ func (index *Index) saveDocuments() (err error) {
 	defer file.Close()
 
 	w := csv.NewWriter(file)
+	w.Write([]string{"id", encodedDocumentColumn, versionColumn, unstoredTermsColumn})
 
-	headers := []string{"id"}
-	headers = append(headers, index.FieldNames...)
-	w.Write(headers)
+	for _, id := range index.savedDocumentIDs() {
+		var record []string
 
-	for id, document := range index.Documents {
-		record := recordFromDocument(id, headers, document)
+		record, err = recordFromDocument(id, index.Documents[id], index.Versions[id], index.unstoredTerms[id])
+		if err != nil {
+			return
+		}
 		w.Write(record)
 	}
 	w.Flush()
@@ -536,10 +911,8 @@ func (index *Index) saveDocuments() (err error) {
 
 func (index *Index) saveDocumentsToShards() (err error) {
 	shardDocumentIDsMap := make(map[int][]string)
-	headers := []string{"id"}
-	headers = append(headers, index.FieldNames...)
 
-	for documentID := range index.Documents {
+	for _, documentID := range index.savedDocumentIDs() {
 		shardID := index.CalculateShardID(documentID)
 		shardDocumentIDsMap[int(shardID)] = append(shardDocumentIDsMap[int(shardID)], documentID)
 	}
@@ -561,11 +934,15 @@ func (index *Index) saveDocumentsToShards() (err error) {
 		defer file.Close()
 
 		w := csv.NewWriter(file)
-		w.Write(headers)
+		w.Write([]string{"id", encodedDocumentColumn, versionColumn, unstoredTermsColumn})
 
 		for _, documentID := range documentIDs {
-			document := index.Documents[documentID]
-			record := recordFromDocument(documentID, headers, document)
+			var record []string
+
+			record, err = recordFromDocument(documentID, index.Documents[documentID], index.Versions[documentID], index.unstoredTerms[documentID])
+			if err != nil {
+				return
+			}
 			w.Write(record)
 		}
 
@@ -576,17 +953,27 @@ func (index *Index) saveDocumentsToShards() (err error) {
 	return
 }
 
-func recordFromDocument(id string, headers []string, document map[string]interface{}) (record []string) {
-	for _, header := range headers {
-		if header == "id" {
-			record = append(record, id)
-			continue
+// recordFromDocument returns the record of a document in a documents file, which is the document ID
+// followed by the encoded document, its version, and the terms of its values that are not stored if
+// the index has any. Deleted documents have no encoded document.
+func recordFromDocument(id string, document map[string]interface{}, version uint64, unstoredTerms map[string][]string) (record []string, err error) {
+	var encoded string
+	if document != nil {
+		encoded, err = encodeDocument(document)
+		if err != nil {
+			return
 		}
+	}
 
-		values := fieldValuesFromRoot(document, header)
-		value := strings.Join(values, ",")
-		record = append(record, value)
+	var encodedTerms []byte
+	if unstoredTerms != nil {
+		encodedTerms, err = json.Marshal(unstoredTerms)
+		if err != nil {
+			return
+		}
 	}
+
+	record = []string{id, encoded, strconv.FormatUint(version, 10), string(encodedTerms)}
 	return
 }
 
@@ -722,3 +1109,30 @@ func (index *Index) IndexReaderWithIDField(r io.Reader, dataType, idField string
 	err = index.IndexDataWithIDField(data, dataType, idField)
 	return
 }
+
+// BulkFilePath runs the actions of a file containing NDJSON bulk data. See BulkData for the format.
+func (index *Index) BulkFilePath(filePath string) (res BulkResult, err error) {
+	var file *os.File
+
+	file, err = os.Open(filePath)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	res, err = index.BulkReader(file)
+	return
+}
+
+// BulkReader runs the actions of a reader of NDJSON bulk data. See BulkData for the format.
+func (index *Index) BulkReader(r io.Reader) (res BulkResult, err error) {
+	var data []byte
+
+	data, err = ioutil.ReadAll(r)
+	if err != nil {
+		return
+	}
+
+	res, err = index.BulkData(data)
+	return
+}
@@ -2,13 +2,30 @@ package folder
 
 import (
 	"bufio"
+	"context"
 	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"io"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
+// sleepContext sleeps for a duration or until the context is done, whichever comes first.
+func sleepContext(ctx context.Context, d time.Duration) (err error) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-timer.C:
+	}
+	return
+}
+
 func (index *Index) loadShardCountFromReader(r io.Reader) (err error) {
 	_, err = fmt.Fscanf(r, "%d", &index.ShardCount)
 	if err != nil {
@@ -27,7 +44,7 @@ func (index *Index) loadFieldNamesFromReader(r io.Reader) (err error) {
 	return
 }
 
-func (index *Index) fetchDocumentFromReader(r io.Reader, documentID string) (document map[string]interface{}, err error) {
+func (index *Index) fetchDocumentFromReader(r io.Reader, documentID string) (document map[string]interface{}, version uint64, err error) {
 	csvr := csv.NewReader(r)
 
 	var record []string
@@ -54,7 +71,11 @@ func (index *Index) fetchDocumentFromReader(r io.Reader, documentID string) (doc
 
 		id := record[0]
 		if id == documentID {
-			document = documentFromRecord(headers[1:], record[1:])
+			document, version, _, err = index.documentFromRecord(headers, record)
+			if document == nil {
+				// Deleted documents have no version
+				version = 0
+			}
 			return
 		}
 	}
@@ -87,8 +108,67 @@ func (index *Index) loadDocumentsFromReader(r io.Reader) (err error) {
 		}
 
 		id := record[0]
-		index.Documents[id] = documentFromRecord(headers[1:], record[1:])
+		var document map[string]interface{}
+		var unstoredTerms map[string][]string
+		document, index.Versions[id], unstoredTerms, err = index.documentFromRecord(headers, record)
+		if err != nil {
+			return
+		}
+
+		// Deleted documents only keep their versions
+		if document == nil {
+			continue
+		}
+		index.Documents[id] = document
+
+		if unstoredTerms != nil {
+			if index.unstoredTerms == nil {
+				index.unstoredTerms = make(map[string]map[string][]string)
+			}
+			index.unstoredTerms[id] = unstoredTerms
+		}
+	}
+	return
+}
+
+// documentFromRecord reads a document, its version, and the terms of its values that are not stored
+// if they were saved from a record of a documents file. The document is nil if it was deleted and
+// only its version was kept. Documents files saved before documents were encoded have a column per
+// field whose values were all turned into strings, so the values of number, boolean, and vector
+// fields in the mapping are converted back.
+func (index *Index) documentFromRecord(headers, record []string) (document map[string]interface{}, version uint64, unstoredTerms map[string][]string, err error) {
+	version = 1
+
+	if len(headers) >= 2 && headers[1] == encodedDocumentColumn {
+		if record[1] != "" {
+			document, err = decodeDocument(record[1])
+		}
+		if err != nil || len(headers) < 3 || headers[2] != versionColumn {
+			return
+		}
+
+		version, err = strconv.ParseUint(record[2], 10, 64)
+		if err != nil {
+			err = fmt.Errorf("%w: version %s of document %s", ErrInvalidDocumentRecord, record[2], record[0])
+			return
+		}
+
+		if len(headers) < 4 || headers[3] != unstoredTermsColumn || record[3] == "" {
+			return
+		}
+
+		err = json.Unmarshal([]byte(record[3]), &unstoredTerms)
+		if err != nil {
+			err = fmt.Errorf("%w: unstored terms of document %s", ErrInvalidDocumentRecord, record[0])
+		}
+		return
+	}
+
+	document = make(map[string]interface{})
+	for i, header := range headers[1:] {
+		setField(document, header, record[i+1])
 	}
+	index.restoreLegacyValues(document)
 	return
 }
 
@@ -114,6 +194,11 @@ func (index *Index) loadTermStatsFromReader(r io.Reader) (err error) {
 			termStat.TermFrequencies = make(map[string]int)
 		}
 		for _, v := range tfs {
+			// Terms whose documents were all removed have no frequencies
+			if v == "" {
+				continue
+			}
+
 			vv := strings.Split(v, ":")
 			id := vv[0]
 			frequency := vv[1]
@@ -127,3 +212,248 @@ func (index *Index) loadTermStatsFromReader(r io.Reader) (err error) {
 	}
 	return
 }
+
+// useLegacyAnalysis sets up the analysis settings of an index that was saved before analyzers were
+// configurable.
+func (index *Index) useLegacyAnalysis() {
+	index.Analysis = NewAnalysisSettings()
+	index.Analysis.DefaultAnalyzer = LegacyAnalyzer
+	index.analyzers = newAnalyzerCache()
+}
+
+// loadAnalysisFromReader loads analysis settings saved as CSV records in the following forms:
+//
+//	default,[analyzer name]
+//	analyzer,[analyzer name],[char_filter / tokenizer / token_filter],[type],[params...]
+//	field,[field name],[analyzer name]
+//	search_field,[field name],[analyzer name]
+//	language,[language],[analyzer name]
+//	language_detection,[document / field],[language field name]
+func (index *Index) loadAnalysisFromReader(r io.Reader) (err error) {
+	var record []string
+
+	index.Analysis = NewAnalysisSettings()
+	index.analyzers = newAnalyzerCache()
+
+	csvr := csv.NewReader(r)
+	csvr.FieldsPerRecord = -1
+
+	for {
+		record, err = csvr.Read()
+		if err != nil {
+			if err == io.EOF {
+				err = nil
+				break
+			}
+			return
+		}
+
+		switch {
+		case record[0] == "default" && len(record) == 2:
+			index.Analysis.DefaultAnalyzer = record[1]
+		case record[0] == "field" && len(record) == 3:
+			index.Analysis.FieldAnalyzers[record[1]] = record[2]
+		case record[0] == "search_field" && len(record) == 3:
+			index.Analysis.FieldSearchAnalyzers[record[1]] = record[2]
+		case record[0] == "language" && len(record) == 3:
+			if index.Analysis.LanguageDetection.Analyzers == nil {
+				index.Analysis.LanguageDetection.Analyzers = make(map[string]string)
+			}
+			index.Analysis.LanguageDetection.Analyzers[record[1]] = record[2]
+		case record[0] == "language_detection" && len(record) == 3:
+			index.Analysis.LanguageDetection.PerField = record[1] == "field"
+			index.Analysis.LanguageDetection.Field = record[2]
+		case record[0] == "analyzer" && len(record) >= 4:
+			name := record[1]
+			component := ComponentConfig{Type: record[3]}
+			if len(record) > 4 {
+				component.Params = record[4:]
+			}
+			config := index.Analysis.Analyzers[name]
+			switch record[2] {
+			case "char_filter":
+				config.CharFilters = append(config.CharFilters, component)
+			case "tokenizer":
+				config.Tokenizer = component
+			case "token_filter":
+				config.TokenFilters = append(config.TokenFilters, component)
+			default:
+				return fmt.Errorf("%w: %v", ErrInvalidAnalysisRecord, record)
+			}
+			index.Analysis.Analyzers[name] = config
+		default:
+			return fmt.Errorf("%w: %v", ErrInvalidAnalysisRecord, record)
+		}
+	}
+	return
+}
+
+// saveAnalysisToWriter saves analysis settings in the format read by loadAnalysisFromReader.
+func (index *Index) saveAnalysisToWriter(w io.Writer) (err error) {
+	csvw := csv.NewWriter(w)
+
+	csvw.Write([]string{"default", index.Analysis.fieldAnalyzer("")})
+
+	names := []string{}
+	for name := range index.Analysis.Analyzers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		config := index.Analysis.Analyzers[name]
+		for _, c := range config.CharFilters {
+			csvw.Write(append([]string{"analyzer", name, "char_filter", c.Type}, c.Params...))
+		}
+		csvw.Write(append([]string{"analyzer", name, "tokenizer", config.Tokenizer.Type}, config.Tokenizer.Params...))
+		for _, c := range config.TokenFilters {
+			csvw.Write(append([]string{"analyzer", name, "token_filter", c.Type}, c.Params...))
+		}
+	}
+
+	fields := []string{}
+	for field := range index.Analysis.FieldAnalyzers {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		csvw.Write([]string{"field", field, index.Analysis.FieldAnalyzers[field]})
+	}
+
+	fields = []string{}
+	for field := range index.Analysis.FieldSearchAnalyzers {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		csvw.Write([]string{"search_field", field, index.Analysis.FieldSearchAnalyzers[field]})
+	}
+
+	detection := &index.Analysis.LanguageDetection
+	if detection.enabled() {
+		for _, language := range detection.languages() {
+			csvw.Write([]string{"language", language, detection.Analyzers[language]})
+		}
+
+		scope := "document"
+		if detection.PerField {
+			scope = "field"
+		}
+		csvw.Write([]string{"language_detection", scope, detection.Field})
+	}
+
+	csvw.Flush()
+	err = csvw.Error()
+	return
+}
+
+// loadMappingFromReader loads a mapping saved as CSV records in the following forms:
+//
+//	dynamic,[auto / ignore / strict]
+//	field,[field name],[type],[analyzer=name / boost=number / stored=false / dimensions=number / normalizer=name / inferred=true...]
+func (index *Index) loadMappingFromReader(r io.Reader) (err error) {
+	var record []string
+
+	index.Mapping = NewMapping()
+
+	csvr := csv.NewReader(r)
+	csvr.FieldsPerRecord = -1
+
+	for {
+		record, err = csvr.Read()
+		if err != nil {
+			if err == io.EOF {
+				err = nil
+				break
+			}
+			return
+		}
+
+		switch {
+		case record[0] == "dynamic" && len(record) == 2:
+			index.Mapping.Dynamic = DynamicMapping(record[1])
+		case record[0] == "field" && len(record) >= 3:
+			mapping := FieldMapping{Type: FieldType(record[2])}
+			for _, option := range record[3:] {
+				i := strings.Index(option, "=")
+				if i < 0 {
+					return fmt.Errorf("%w: %v", ErrInvalidMappingRecord, record)
+				}
+
+				key, value := option[:i], option[i+1:]
+				switch key {
+				case "analyzer":
+					mapping.Analyzer = value
+				case "boost":
+					mapping.Boost, err = strconv.ParseFloat(value, 64)
+				case "stored":
+					var stored bool
+					stored, err = strconv.ParseBool(value)
+					mapping.NotStored = !stored
+				case "dimensions":
+					mapping.Dimensions, err = strconv.Atoi(value)
+				case "normalizer":
+					mapping.Normalizers = append(mapping.Normalizers, value)
+				case "inferred":
+					mapping.Inferred, err = strconv.ParseBool(value)
+				default:
+					err = ErrInvalidMappingRecord
+				}
+				if err != nil {
+					return fmt.Errorf("%w: %v", ErrInvalidMappingRecord, record)
+				}
+			}
+			index.Mapping.Fields[record[1]] = mapping
+		default:
+			return fmt.Errorf("%w: %v", ErrInvalidMappingRecord, record)
+		}
+	}
+	return
+}
+
+// saveMappingToWriter saves a mapping in the format read by loadMappingFromReader.
+func (index *Index) saveMappingToWriter(w io.Writer) (err error) {
+	csvw := csv.NewWriter(w)
+
+	dynamic := index.Mapping.Dynamic
+	if dynamic == "" {
+		dynamic = DynamicAuto
+	}
+	csvw.Write([]string{"dynamic", string(dynamic)})
+
+	fields := []string{}
+	for field := range index.Mapping.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		mapping := index.Mapping.Fields[field]
+		record := []string{"field", field, string(mapping.Type)}
+		if mapping.Analyzer != "" {
+			record = append(record, "analyzer="+mapping.Analyzer)
+		}
+		if mapping.Boost != 0 {
+			record = append(record, "boost="+strconv.FormatFloat(mapping.Boost, 'g', -1, 64))
+		}
+		if mapping.NotStored {
+			record = append(record, "stored=false")
+		}
+		if mapping.Dimensions != 0 {
+			record = append(record, "dimensions="+strconv.Itoa(mapping.Dimensions))
+		}
+		for _, normalizer := range mapping.Normalizers {
+			record = append(record, "normalizer="+normalizer)
+		}
+		if mapping.Inferred {
+			record = append(record, "inferred=true")
+		}
+		csvw.Write(record)
+	}
+
+	csvw.Flush()
+	err = csvw.Error()
+	return
+}
@@ -4,6 +4,7 @@
 package folder
 
 import (
+	"context"
 	"time"
 )
 
@@ -11,22 +12,40 @@ const (
 	FieldNamesFileExtension = "fns"
 	DocumentsFileExtension  = "dcs"
 	TermStatsFileExtension  = "tst"
+	AnalysisFileExtension   = "als"
+	MappingFileExtension    = "mps"
 )
 
 type ProgressCallback func(loadedShardsCount, totalShardsCount int)
 
 // LoadDeferred loads an index metadata only the rest of the data is loaded when needed.
 func LoadDeferred(indexName, baseURL string) (index *Index, err error) {
+	return LoadDeferredContext(context.Background(), indexName, baseURL)
+}
+
+// LoadDeferredContext loads an index metadata just like LoadDeferred but cancels the requests once
+// the context is done.
+func LoadDeferredContext(ctx context.Context, indexName, baseURL string) (index *Index, err error) {
 	index = New()
 	index.Name = indexName
 	index.baseURL = baseURL
 
-	err = index.loadShardCount()
+	err = index.loadShardCount(ctx)
+	if err != nil {
+		return
+	}
+
+	err = index.loadFieldNamesDeferred(ctx)
 	if err != nil {
 		return
 	}
 
-	err = index.loadFieldNamesDeferred()
+	err = index.loadAnalysisDeferred(ctx)
+	if err != nil {
+		return
+	}
+
+	err = index.loadMappingDeferred(ctx)
 	if err != nil {
 		return
 	}
@@ -35,38 +54,37 @@ func LoadDeferred(indexName, baseURL string) (index *Index, err error) {
 }
 
 func (index *Index) LoadAllShards(progressCallback ProgressCallback, sleepDuration time.Duration) (err error) {
+	return index.LoadAllShardsContext(context.Background(), progressCallback, sleepDuration)
+}
+
+// LoadAllShardsContext loads every shard just like LoadAllShards but stops once the context is done.
+func (index *Index) LoadAllShardsContext(ctx context.Context, progressCallback ProgressCallback, sleepDuration time.Duration) (err error) {
 	for i := 0; i < index.ShardCount; i++ {
-		err = index.loadShard(uint32(i))
+		err = index.loadShard(ctx, uint32(i))
 		if err != nil {
 			return
 		}
 
 		progressCallback(i+1, index.ShardCount)
-		time.Sleep(sleepDuration)
+
+		err = sleepContext(ctx, sleepDuration)
+		if err != nil {
+			return
+		}
 	}
 	return
 }
 
-func (index *Index) loadShard(shardID uint32) (err error) {
-	err = index.loadDocumentsFromShard(shardID)
+func (index *Index) loadShard(ctx context.Context, shardID uint32) (err error) {
+	err = index.loadDocumentsFromShard(ctx, shardID)
 	if err != nil {
 		return
 	}
 
-	err = index.loadTermStatsFromShard(shardID)
+	err = index.loadTermStatsFromShard(ctx, shardID)
 	if err != nil {
 		return
 	}
 
 	return
 }
-
-func documentFromRecord(headers, record []string) (document map[string]interface{}) {
-	document = make(map[string]interface{})
-
-	for i, header := range headers {
-		setField(document, header, record[i])
-	}
-
-	return
-}
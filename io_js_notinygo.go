@@ -4,15 +4,41 @@
 package folder
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"net/http"
 )
 
-func (index *Index) loadShardCount() (err error) {
+// httpGet sends a GET request that is cancelled once the context is done. A missing file is
+// reported as fs.ErrNotExist.
+func httpGet(ctx context.Context, url string) (resp *http.Response, err error) {
+	var req *http.Request
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return
+	}
+
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		return
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		resp = nil
+		err = fs.ErrNotExist
+	}
+	return
+}
+
+func (index *Index) loadShardCount(ctx context.Context) (err error) {
 	var resp *http.Response
 
 	url := fmt.Sprintf("%s/%s/shard_count", index.baseURL, index.Name)
-	resp, err = http.Get(url)
+	resp, err = httpGet(ctx, url)
 	if err != nil {
 		return
 	}
@@ -22,12 +48,12 @@ func (index *Index) loadShardCount() (err error) {
 	return
 }
 
-func (index *Index) loadFieldNamesDeferred() (err error) {
+func (index *Index) loadFieldNamesDeferred(ctx context.Context) (err error) {
 	var resp *http.Response
 
 	dirPath := fmt.Sprintf("%s/%s", index.baseURL, index.Name)
 	url := fmt.Sprintf("%s/%s", dirPath, FieldNamesFileExtension)
-	resp, err = http.Get(url)
+	resp, err = httpGet(ctx, url)
 	if err != nil {
 		return
 	}
@@ -37,24 +63,64 @@ func (index *Index) loadFieldNamesDeferred() (err error) {
 	return
 }
 
-func (index *Index) fetchDocumentFromShard(shardID uint32, documentID string) (document map[string]interface{}, err error) {
+func (index *Index) loadAnalysisDeferred(ctx context.Context) (err error) {
+	var resp *http.Response
+
+	url := fmt.Sprintf("%s/%s/%s", index.baseURL, index.Name, AnalysisFileExtension)
+	resp, err = httpGet(ctx, url)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			index.useLegacyAnalysis()
+			err = nil
+		}
+		return
+	}
+	defer resp.Body.Close()
+
+	err = index.loadAnalysisFromReader(resp.Body)
+	return
+}
+
+func (index *Index) loadMappingDeferred(ctx context.Context) (err error) {
+	var resp *http.Response
+
+	url := fmt.Sprintf("%s/%s/%s", index.baseURL, index.Name, MappingFileExtension)
+	resp, err = httpGet(ctx, url)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			// Indexes saved before mappings existed add their fields dynamically
+			err = nil
+		}
+		return
+	}
+	defer resp.Body.Close()
+
+	err = index.loadMappingFromReader(resp.Body)
+	return
+}
+
+func (index *Index) fetchDocumentFromShard(ctx context.Context, shardID uint32, documentID string) (document map[string]interface{}, version uint64, err error) {
 	var resp *http.Response
 	var ok bool
 
 	if document, ok = index.Documents[documentID]; ok {
+		version = index.Versions[documentID]
 		return
 	}
 
 	url := fmt.Sprintf("%s/%s/%d/%s", index.baseURL, index.Name, shardID, DocumentsFileExtension)
 	debug("  Fetching document from shard: ", url)
 
-	resp, err = http.Get(url)
+	resp, err = httpGet(ctx, url)
 	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
 		return
 	}
 	defer resp.Body.Close()
 
-	document, err = index.fetchDocumentFromReader(resp.Body, documentID)
+	document, version, err = index.fetchDocumentFromReader(resp.Body, documentID)
 	if err != nil {
 		return
 	}
@@ -62,7 +128,7 @@ func (index *Index) fetchDocumentFromShard(shardID uint32, documentID string) (d
 	return
 }
 
-func (index *Index) loadDocumentsFromShard(shardID uint32) (err error) {
+func (index *Index) loadDocumentsFromShard(ctx context.Context, shardID uint32) (err error) {
 	var resp *http.Response
 
 	if _, ok := index.LoadedDocumentsShards[shardID]; ok {
@@ -72,8 +138,13 @@ func (index *Index) loadDocumentsFromShard(shardID uint32) (err error) {
 	url := fmt.Sprintf("%s/%s/%d/%s", index.baseURL, index.Name, shardID, DocumentsFileExtension)
 	debug("  Loading documents shard:", url)
 
-	resp, err = http.Get(url)
+	resp, err = httpGet(ctx, url)
 	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			// Shards without any data are not saved
+			index.LoadedDocumentsShards[shardID] = struct{}{}
+			err = nil
+		}
 		return
 	}
 	defer resp.Body.Close()
@@ -88,7 +159,7 @@ func (index *Index) loadDocumentsFromShard(shardID uint32) (err error) {
 	return
 }
 
-func (index *Index) loadTermStatsFromShard(shardID uint32) (err error) {
+func (index *Index) loadTermStatsFromShard(ctx context.Context, shardID uint32) (err error) {
 	var resp *http.Response
 
 	if _, ok := index.LoadedTermStatsShards[shardID]; ok {
@@ -98,8 +169,13 @@ func (index *Index) loadTermStatsFromShard(shardID uint32) (err error) {
 	url := fmt.Sprintf("%s/%s/%d/%s", index.baseURL, index.Name, shardID, TermStatsFileExtension)
 	debug("  Loading term stats shard:", url)
 
-	resp, err = http.Get(url)
+	resp, err = httpGet(ctx, url)
 	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			// Shards without any data are not saved
+			index.LoadedTermStatsShards[shardID] = struct{}{}
+			err = nil
+		}
 		return
 	}
 	defer resp.Body.Close()
@@ -4,36 +4,70 @@
 package folder
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"strings"
 	"syscall/js"
 )
 
-func textDataFromURL(url string) (text string, err error) {
+// textDataFromURL fetches the text at a URL. The request is aborted once the context is done and a
+// missing file is reported as fs.ErrNotExist.
+func textDataFromURL(ctx context.Context, url string) (text string, err error) {
+	var jsTextCallback, jsFetchCallback, jsErrorCallback, jsFinallyCallback js.Func
+
 	c := make(chan string, 1)
+	errc := make(chan error, 1)
 	jsURL := js.ValueOf(url)
-	jsTextCallback := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+	jsAbortController := js.Global().Get("AbortController").New()
+	jsTextCallback = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
 		c <- args[0].String()
 		return nil
 	})
-	jsFetchCallback := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+	jsFetchCallback = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
 		jsResponse := args[0]
-		jsTextPromise := jsResponse.Call("text")
-		jsTextPromise.Call("then", jsTextCallback)
+		if jsResponse.Get("status").Int() == 404 {
+			errc <- fs.ErrNotExist
+			return nil
+		}
+		// The text promise is returned so that the callbacks are released after it settles
+		return jsResponse.Call("text").Call("then", jsTextCallback)
+	})
+	jsErrorCallback = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		errc <- ErrFetchFailed
+		return nil
+	})
+	// The callbacks may still be called after the request is aborted, so they are only released
+	// once the fetch has settled
+	jsFinallyCallback = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		jsTextCallback.Release()
+		jsFetchCallback.Release()
+		jsErrorCallback.Release()
+		jsFinallyCallback.Release()
 		return nil
 	})
-	promise := js.Global().Call("fetch", jsURL)
-	promise.Call("then", jsFetchCallback)
-	data := <-c
-	text = string(data)
+	jsOptions := js.ValueOf(map[string]interface{}{})
+	jsOptions.Set("signal", jsAbortController.Get("signal"))
+	promise := js.Global().Call("fetch", jsURL, jsOptions)
+	promise.Call("then", jsFetchCallback).Call("catch", jsErrorCallback).Call("finally", jsFinallyCallback)
+
+	select {
+	case data := <-c:
+		text = string(data)
+	case err = <-errc:
+	case <-ctx.Done():
+		jsAbortController.Call("abort")
+		err = ctx.Err()
+	}
 	return
 }
 
-func textReaderFromURL(url string) (r io.Reader, err error) {
+func textReaderFromURL(ctx context.Context, url string) (r io.Reader, err error) {
 	var text string
 
-	text, err = textDataFromURL(url)
+	text, err = textDataFromURL(ctx, url)
 	if err != nil {
 		return
 	}
@@ -42,11 +76,11 @@ func textReaderFromURL(url string) (r io.Reader, err error) {
 	return
 }
 
-func (index *Index) loadShardCount() (err error) {
+func (index *Index) loadShardCount(ctx context.Context) (err error) {
 	var r io.Reader
 
 	url := fmt.Sprintf("%s/%s/shard_count", index.baseURL, index.Name)
-	r, err = textReaderFromURL(url)
+	r, err = textReaderFromURL(ctx, url)
 	if err != nil {
 		return
 	}
@@ -55,12 +89,12 @@ func (index *Index) loadShardCount() (err error) {
 	return
 }
 
-func (index *Index) loadFieldNamesDeferred() (err error) {
+func (index *Index) loadFieldNamesDeferred(ctx context.Context) (err error) {
 	var r io.Reader
 
 	dirPath := fmt.Sprintf("%s/%s", index.baseURL, index.Name)
 	url := fmt.Sprintf("%s/%s", dirPath, FieldNamesFileExtension)
-	r, err = textReaderFromURL(url)
+	r, err = textReaderFromURL(ctx, url)
 	if err != nil {
 		return
 	}
@@ -69,23 +103,61 @@ func (index *Index) loadFieldNamesDeferred() (err error) {
 	return
 }
 
-func (index *Index) fetchDocumentFromShard(shardID uint32, documentID string) (document map[string]interface{}, err error) {
+func (index *Index) loadAnalysisDeferred(ctx context.Context) (err error) {
+	var r io.Reader
+
+	url := fmt.Sprintf("%s/%s/%s", index.baseURL, index.Name, AnalysisFileExtension)
+	r, err = textReaderFromURL(ctx, url)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			index.useLegacyAnalysis()
+			err = nil
+		}
+		return
+	}
+
+	err = index.loadAnalysisFromReader(r)
+	return
+}
+
+func (index *Index) loadMappingDeferred(ctx context.Context) (err error) {
+	var r io.Reader
+
+	url := fmt.Sprintf("%s/%s/%s", index.baseURL, index.Name, MappingFileExtension)
+	r, err = textReaderFromURL(ctx, url)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			// Indexes saved before mappings existed add their fields dynamically
+			err = nil
+		}
+		return
+	}
+
+	err = index.loadMappingFromReader(r)
+	return
+}
+
+func (index *Index) fetchDocumentFromShard(ctx context.Context, shardID uint32, documentID string) (document map[string]interface{}, version uint64, err error) {
 	var r io.Reader
 	var ok bool
 
 	if document, ok = index.Documents[documentID]; ok {
+		version = index.Versions[documentID]
 		return
 	}
 
 	url := fmt.Sprintf("%s/%s/%d/%s", index.baseURL, index.Name, shardID, DocumentsFileExtension)
 	debug("  Fetching document from shard: ", url)
 
-	r, err = textReaderFromURL(url)
+	r, err = textReaderFromURL(ctx, url)
 	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
 		return
 	}
 
-	document, err = index.fetchDocumentFromReader(r, documentID)
+	document, version, err = index.fetchDocumentFromReader(r, documentID)
 	if err != nil {
 		return
 	}
@@ -93,7 +165,7 @@ func (index *Index) fetchDocumentFromShard(shardID uint32, documentID string) (d
 	return
 }
 
-func (index *Index) loadDocumentsFromShard(shardID uint32) (err error) {
+func (index *Index) loadDocumentsFromShard(ctx context.Context, shardID uint32) (err error) {
 	var r io.Reader
 
 	if _, ok := index.LoadedDocumentsShards[shardID]; ok {
@@ -103,8 +175,13 @@ func (index *Index) loadDocumentsFromShard(shardID uint32) (err error) {
 	url := fmt.Sprintf("%s/%s/%d/%s", index.baseURL, index.Name, shardID, DocumentsFileExtension)
 	debug("  Loading documents shard:", url)
 
-	r, err = textReaderFromURL(url)
+	r, err = textReaderFromURL(ctx, url)
 	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			// Shards without any data are not saved
+			index.LoadedDocumentsShards[shardID] = struct{}{}
+			err = nil
+		}
 		return
 	}
 
@@ -118,7 +195,7 @@ func (index *Index) loadDocumentsFromShard(shardID uint32) (err error) {
 	return
 }
 
-func (index *Index) loadTermStatsFromShard(shardID uint32) (err error) {
+func (index *Index) loadTermStatsFromShard(ctx context.Context, shardID uint32) (err error) {
 	var r io.Reader
 
 	if _, ok := index.LoadedTermStatsShards[shardID]; ok {
@@ -128,8 +205,13 @@ func (index *Index) loadTermStatsFromShard(shardID uint32) (err error) {
 	url := fmt.Sprintf("%s/%s/%d/%s", index.baseURL, index.Name, shardID, TermStatsFileExtension)
 	debug("  Loading term stats shard:", url)
 
-	r, err = textReaderFromURL(url)
+	r, err = textReaderFromURL(ctx, url)
 	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			// Shards without any data are not saved
+			index.LoadedTermStatsShards[shardID] = struct{}{}
+			err = nil
+		}
 		return
 	}
 
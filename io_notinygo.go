@@ -3,112 +3,270 @@
 package folder
 
 import (
-	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
+	"time"
 )
 
 // IndexData indexes an array of bytes and assumes a certain data type such as text, JSON, or JSONL.
+// Every line of JSONL data that can be indexed is indexed even if other lines cannot, in which case
+// a *BulkError with the failed lines is returned.
 func (index *Index) IndexData(data []byte, dataType string) (err error) {
-	var m map[string]interface{}
+	if dataType == "jsonl" {
+		return index.indexLines(data, "").Err()
+	}
+
+	documents, err := documentsFromData(data, dataType)
+	if err != nil {
+		return
+	}
+
+	for _, document := range documents {
+		_, err = index.Index(document)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
 
+// documentsFromData parses an array of bytes of a certain data type such as text, JSON, or JSONL into
+// documents. ErrUnsupportedDocumentDataType is returned for other data types.
+func documentsFromData(data []byte, dataType string) (documents []map[string]interface{}, err error) {
 	if dataType == "text" {
-		m = make(map[string]interface{})
-		m["text"] = string(data)
-		index.Index(m)
+		documents = append(documents, map[string]interface{}{"text": string(data)})
 	} else if dataType == "json" {
-		m = make(map[string]interface{})
+		m := make(map[string]interface{})
 		err = json.Unmarshal(data, &m)
 		if err != nil {
-			return err
+			return
 		}
-
-		index.Index(m)
+		documents = append(documents, m)
 	} else if dataType == "jsonl" {
-		r := bytes.NewReader(data)
-		scanner := bufio.NewScanner(r)
+		for i, line := range bytes.Split(data, []byte("\n")) {
+			var lineDocuments []map[string]interface{}
 
-		i := 0
+			if len(bytes.TrimSpace(line)) == 0 {
+				continue
+			}
 
-		for scanner.Scan() {
-			i += 1
-			m = make(map[string]interface{})
-			err = json.Unmarshal(scanner.Bytes(), &m)
+			lineDocuments, err = documentsFromData(line, "json")
 			if err != nil {
-				return err
+				err = fmt.Errorf("line %d: %w", i+1, err)
+				return
 			}
-			index.Index(m)
-		}
-
-		err = scanner.Err()
-		if err != nil {
-			return err
+			documents = append(documents, lineDocuments...)
 		}
+	} else {
+		err = fmt.Errorf("%w: %s", ErrUnsupportedDocumentDataType, dataType)
 	}
 
-	return nil
+	return
 }
 
 // IndexDataWithIDField performs similar operation as IndexData but user can provide which document
 // field value to be used as the document ID. This is only compatible with "json" and "jsonl" data
 // types.
 func (index *Index) IndexDataWithIDField(data []byte, dataType, idField string) (err error) {
-	var m map[string]interface{}
+	if dataType == "jsonl" {
+		return index.indexLines(data, idField).Err()
+	}
 
-	if dataType == "text" {
-		m = make(map[string]interface{})
-		m["text"] = string(data)
-		index.Index(m)
-	} else if dataType == "json" {
-		m = make(map[string]interface{})
-		err = json.Unmarshal(data, &m)
-		if err != nil {
-			return err
+	documents, err := documentsFromData(data, dataType)
+	if err != nil {
+		return
+	}
+
+	for _, document := range documents {
+		// Text documents don't have fields that the document ID can be taken from
+		if dataType == "text" {
+			_, err = index.Index(document)
+			if err != nil {
+				return
+			}
+			continue
 		}
 
-		values := fieldValuesFromRoot(m, idField)
+		values := fieldValuesFromRoot(document, idField)
 		if len(values) == 0 {
 			err = ErrDocumentMissingIDField
 			return
 		}
 
-		documentID := values[0]
-		_, err = index.IndexWithID(m, documentID)
+		_, err = index.IndexWithID(document, values[0])
 		if err != nil {
 			return
 		}
-	} else if dataType == "jsonl" {
-		r := bytes.NewReader(data)
-		scanner := bufio.NewScanner(r)
+	}
+	return
+}
 
-		i := 0
+// indexLines indexes each line of JSONL data as a document, using the value of the ID field as the
+// document ID if it is given.
+func (index *Index) indexLines(data []byte, idField string) (res BulkResult) {
+	start := time.Now()
 
-		for scanner.Scan() {
-			i += 1
-			m = make(map[string]interface{})
-			err = json.Unmarshal(scanner.Bytes(), &m)
-			if err != nil {
-				return err
-			}
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		var documents []map[string]interface{}
+		var m map[string]interface{}
 
+		item := BulkItem{Type: BulkIndex}
+		documents, item.Err = documentsFromData(line, "json")
+		if item.Err == nil {
+			m = documents[0]
+		}
+		if item.Err == nil && idField != "" {
 			values := fieldValuesFromRoot(m, idField)
 			if len(values) == 0 {
-				err = ErrDocumentMissingIDField
-				return
+				item.Err = ErrDocumentMissingIDField
+			} else {
+				item.DocumentID = values[0]
+			}
+		}
+		if item.Err == nil {
+			item = index.bulk(BulkAction{Type: BulkIndex, DocumentID: item.DocumentID, Document: m})
+		}
+		item.Line = i + 1
+		res.add(item)
+	}
+
+	res.Time = time.Since(start)
+	return
+}
+
+// BulkData runs the actions of NDJSON bulk data. Each action is a line such as
+// {"index": {"_id": "1", "version": 2}} whose ID and version are optional for index and create
+// actions. Index and create actions are followed by a line with the document, and update actions by
+// a line with a merge patch and/or patch operations such as
+// {"doc": {"title": "Folder"}, "operations": [{"type": "increment", "field": "views", "value": 1}]}.
+// Lines that cannot be read fail just like actions that cannot be run without stopping the others.
+func (index *Index) BulkData(data []byte) (res BulkResult, err error) {
+	return index.BulkDataContext(context.Background(), data)
+}
+
+// BulkDataContext runs the actions of NDJSON bulk data just like BulkData but stops when the context
+// is done, in which case the result only has the items of the actions that ran and the error of the
+// context is returned.
+func (index *Index) BulkDataContext(ctx context.Context, data []byte) (res BulkResult, err error) {
+	start := time.Now()
+	defer func() {
+		res.Time = time.Since(start)
+	}()
+
+	lines := bytes.Split(data, []byte("\n"))
+	for i := 0; i < len(lines); i++ {
+		if len(bytes.TrimSpace(lines[i])) == 0 {
+			continue
+		}
+
+		err = ctx.Err()
+		if err != nil {
+			return
+		}
+
+		line := i + 1
+		action, hasSource, actionErr := bulkActionFromLine(lines[i])
+		if hasSource {
+			// Blank lines between an action and its source are skipped like the other blank lines
+			i++
+			for i < len(lines) && len(bytes.TrimSpace(lines[i])) == 0 {
+				i++
 			}
 
-			documentID := values[0]
-			_, err = index.IndexWithID(m, documentID)
-			if err != nil {
-				return
+			sourceErr := fmt.Errorf("%w: %s action without a source line", ErrInvalidBulkAction, action.Type)
+			if i < len(lines) {
+				sourceErr = bulkSourceFromLine(lines[i], &action)
+			}
+			if actionErr == nil {
+				actionErr = sourceErr
 			}
 		}
 
-		err = scanner.Err()
+		item := BulkItem{Type: action.Type, DocumentID: action.DocumentID, Err: actionErr}
+		if actionErr == nil {
+			item = index.bulk(action)
+		}
+		item.Line = line
+		res.add(item)
+	}
+	return
+}
+
+// bulkActionFromLine reads the action of a line of bulk data and tells whether the action is
+// followed by a source line, which is true for index, create, and update actions even if their
+// metadata cannot be read.
+func bulkActionFromLine(line []byte) (action BulkAction, hasSource bool, err error) {
+	var m map[string]json.RawMessage
+	err = json.Unmarshal(line, &m)
+	if err != nil {
+		err = fmt.Errorf("%w: %s", ErrInvalidBulkAction, err)
+		return
+	}
+	if len(m) != 1 {
+		err = fmt.Errorf("%w: line has %d actions instead of 1", ErrInvalidBulkAction, len(m))
+		return
+	}
+
+	var raw json.RawMessage
+	for actionType, metadata := range m {
+		action.Type = BulkActionType(actionType)
+		raw = metadata
+	}
+
+	switch action.Type {
+	case BulkIndex, BulkCreate, BulkUpdate:
+		hasSource = true
+	case BulkDelete:
+	default:
+		err = fmt.Errorf("%w: unknown action %q", ErrInvalidBulkAction, action.Type)
+		return
+	}
+
+	var metadata struct {
+		ID      string `json:"_id"`
+		Version uint64 `json:"version"`
+	}
+	err = json.Unmarshal(raw, &metadata)
+	if err != nil {
+		err = fmt.Errorf("%w: %s", ErrInvalidBulkAction, err)
+		return
+	}
+
+	action.DocumentID = metadata.ID
+	action.Version = metadata.Version
+	return
+}
+
+// bulkSourceFromLine reads the document of an index or create action, or the patch of an update
+// action, from the line following the action.
+func bulkSourceFromLine(line []byte, action *BulkAction) (err error) {
+	if action.Type == BulkUpdate {
+		var source struct {
+			Doc        map[string]interface{} `json:"doc"`
+			Operations []PatchOperation       `json:"operations"`
+		}
+		err = json.Unmarshal(line, &source)
 		if err != nil {
-			return err
+			return fmt.Errorf("%w: %s", ErrInvalidBulkAction, err)
 		}
+
+		action.Document = source.Doc
+		action.Operations = source.Operations
+		return
 	}
 
-	return nil
+	err = json.Unmarshal(line, &action.Document)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidBulkAction, err)
+	}
+	if action.Document == nil {
+		return fmt.Errorf("%w: %s action without a document", ErrInvalidBulkAction, action.Type)
+	}
+	return
 }
@@ -2,23 +2,54 @@
 
 package folder
 
-import "errors"
-
-var (
-	ErrUnsupportedDocumentDataType = errors.New("unsupported document data type")
+import (
+	"context"
+	"fmt"
 )
 
-// IndexData indexes an array of bytes and assumes a certain data type such as text, JSON, or JSONL.
+// IndexData indexes an array of bytes and assumes a certain data type. Only the text data type is
+// supported.
 func (index *Index) IndexData(data []byte, dataType string) (err error) {
-	var m map[string]interface{}
+	documents, err := documentsFromData(data, dataType)
+	if err != nil {
+		return
+	}
 
+	for _, document := range documents {
+		_, err = index.Index(document)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// IndexDataWithIDField performs similar operation as IndexData but user can provide which document
+// field value to be used as the document ID. Text documents don't have such fields so they are
+// indexed just like IndexData does.
+func (index *Index) IndexDataWithIDField(data []byte, dataType, idField string) (err error) {
+	return index.IndexData(data, dataType)
+}
+
+// documentsFromData parses an array of bytes of a certain data type into documents. Only the text
+// data type is supported.
+func documentsFromData(data []byte, dataType string) (documents []map[string]interface{}, err error) {
 	if dataType == "text" {
-		m = make(map[string]interface{})
-		m["text"] = string(data)
-		index.Index(m)
+		documents = append(documents, map[string]interface{}{"text": string(data)})
 	} else {
-		err = ErrUnsupportedDocumentDataType
+		err = fmt.Errorf("%w: %s", ErrUnsupportedDocumentDataType, dataType)
 	}
 
 	return
 }
+
+// BulkData runs the actions of NDJSON bulk data, which is not supported without JSON.
+func (index *Index) BulkData(data []byte) (res BulkResult, err error) {
+	return index.BulkDataContext(context.Background(), data)
+}
+
+// BulkDataContext runs the actions of NDJSON bulk data, which is not supported without JSON.
+func (index *Index) BulkDataContext(ctx context.Context, data []byte) (res BulkResult, err error) {
+	err = ErrUnsupportedDocumentDataType
+	return
+}
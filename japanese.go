@@ -0,0 +1,544 @@
+package folder
+
+import (
+	"bytes"
+	"compress/gzip"
+	_ "embed"
+	"encoding/binary"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The IPADIC dictionary of the MeCab morphological analyzer, whose license is in data/ipadic/NOTICE.
+var (
+	// ipadicWords contains the words of the dictionary sorted by surface form. Each record contains
+	// the surface form, the left and right context IDs, the cost, the part of speech, the conjugation
+	// form, the dictionary form if it differs from the surface form, and the reading.
+	//
+	//go:embed data/ipadic/words.csv.gz
+	ipadicWords []byte
+
+	// ipadicMatrix contains the number of right and left context IDs as two little-endian uint16
+	// followed by the cost of every pair of them as little-endian int16.
+	//
+	//go:embed data/ipadic/matrix.bin.gz
+	ipadicMatrix []byte
+
+	// ipadicUnknownWords contains the character category, the left and right context IDs, the cost,
+	// and the part of speech of the words made for text that isn't in the dictionary.
+	//
+	//go:embed data/ipadic/unknown.csv
+	ipadicUnknownWords string
+
+	// ipadicChars contains the first and the last code point in hexadecimal of ranges of characters
+	// along with their character category.
+	//
+	//go:embed data/ipadic/chars.csv
+	ipadicChars string
+)
+
+// defaultJapaneseStopTags are the parts of speech removed by the Japanese part-of-speech stop filter
+// by default.
+var defaultJapaneseStopTags = []string{"助詞", "助動詞", "記号", "接続詞", "フィラー"}
+
+// morpheme is a word of the Japanese dictionary, or an unknown word found in the analyzed text.
+type morpheme struct {
+	Surface      string
+	BaseForm     string
+	Reading      string
+	PartOfSpeech string
+	Form         string // Conjugation form, empty if the word does not conjugate
+	LeftID       int16  // Context ID used when the morpheme follows another one
+	RightID      int16  // Context ID used when another morpheme follows the morpheme
+	Cost         int16
+}
+
+// maxJapaneseUnknownLength is the maximum length in characters of unknown words.
+const maxJapaneseUnknownLength = 1024
+
+// bosMorpheme marks both the beginning and the end of the analyzed text.
+var bosMorpheme = &morpheme{PartOfSpeech: "BOS"}
+
+// japaneseCharCategory tells how unknown words are made from the characters of a category, like
+// the char.def file of MeCab.
+type japaneseCharCategory struct {
+	Invoke  bool // Whether unknown words are made even if dictionary words start at the character
+	Group   bool // Whether an unknown word is made from the run of characters of the category
+	Length  int  // Unknown words are also made from the first characters of the run up to this length
+	Unknown []morpheme
+}
+
+// japaneseCharCategories are the character categories of IPADIC.
+var japaneseCharCategories = map[string]japaneseCharCategory{
+	"DEFAULT":      {Group: true},
+	"SPACE":        {Group: true},
+	"KANJI":        {Length: 2},
+	"SYMBOL":       {Invoke: true, Group: true},
+	"NUMERIC":      {Invoke: true, Group: true},
+	"ALPHA":        {Invoke: true, Group: true},
+	"HIRAGANA":     {Group: true, Length: 2},
+	"KATAKANA":     {Invoke: true, Group: true, Length: 2},
+	"KANJINUMERIC": {Invoke: true, Group: true},
+	"GREEK":        {Invoke: true, Group: true},
+	"CYRILLIC":     {Invoke: true, Group: true},
+}
+
+// japaneseCharRange is a range of characters of the same category.
+type japaneseCharRange struct {
+	First    rune
+	Last     rune
+	Category *japaneseCharCategory
+}
+
+// japaneseDictionary contains the words, the connection costs, and the character categories of the
+// Japanese tokenizer.
+type japaneseDictionary struct {
+	Words      []morpheme // Sorted by surface form
+	Costs      []int16    // Cost of each right context ID followed by each left context ID
+	LeftIDs    int        // Number of left context IDs
+	Categories map[string]*japaneseCharCategory
+	Chars      []japaneseCharRange
+}
+
+var (
+	japaneseDictionaryOnce  sync.Once
+	japaneseDictionaryCache *japaneseDictionary
+	japaneseDictionaryErr   error
+)
+
+// loadJapaneseDictionary parses the embedded dictionary the first time it is needed.
+func loadJapaneseDictionary() (dictionary *japaneseDictionary, err error) {
+	japaneseDictionaryOnce.Do(func() {
+		japaneseDictionaryCache, japaneseDictionaryErr = parseJapaneseDictionary()
+	})
+	return japaneseDictionaryCache, japaneseDictionaryErr
+}
+
+// parseJapaneseDictionary parses the embedded dictionary.
+func parseJapaneseDictionary() (dictionary *japaneseDictionary, err error) {
+	dictionary = &japaneseDictionary{Categories: make(map[string]*japaneseCharCategory)}
+	for name, category := range japaneseCharCategories {
+		category := category
+		dictionary.Categories[name] = &category
+	}
+
+	err = dictionary.parseWords(ipadicWords)
+	if err != nil {
+		return nil, err
+	}
+	err = dictionary.parseCosts(ipadicMatrix)
+	if err != nil {
+		return nil, err
+	}
+	err = dictionary.parseUnknownWords(ipadicUnknownWords)
+	if err != nil {
+		return nil, err
+	}
+	err = dictionary.parseChars(ipadicChars)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// parseWords parses the gzipped word records.
+func (dictionary *japaneseDictionary) parseWords(data []byte) (err error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return
+	}
+
+	// Parts of speech and conjugation forms are shared between words
+	names := make(map[string]string)
+	intern := func(s string) string {
+		name, ok := names[s]
+		if !ok {
+			name = s
+			names[s] = s
+		}
+		return name
+	}
+
+	r := csv.NewReader(zr)
+	r.FieldsPerRecord = 8
+	r.ReuseRecord = true
+	for {
+		var record []string
+		var m morpheme
+
+		record, err = r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return
+		}
+
+		m, err = parseMorpheme(record[:5])
+		if err != nil {
+			return
+		}
+		m.Surface = record[0]
+		m.PartOfSpeech = intern(m.PartOfSpeech)
+		m.Form = intern(record[5])
+		m.BaseForm = record[6]
+		m.Reading = record[7]
+
+		// Words of the same surface form are next to each other
+		if last := len(dictionary.Words) - 1; last >= 0 && dictionary.Words[last].Surface == m.Surface {
+			m.Surface = dictionary.Words[last].Surface
+		}
+		if m.BaseForm == "" {
+			m.BaseForm = m.Surface
+		}
+		dictionary.Words = append(dictionary.Words, m)
+	}
+}
+
+// parseMorpheme parses a record made of a name, the left and right context IDs, the cost, and the
+// part of speech. The name is left to the caller.
+func parseMorpheme(record []string) (m morpheme, err error) {
+	var values [3]int64
+	for i := range values {
+		values[i], err = strconv.ParseInt(record[i+1], 10, 16)
+		if err != nil {
+			return m, fmt.Errorf("%w: %v", ErrInvalidDictionaryRecord, record)
+		}
+	}
+	m.LeftID, m.RightID, m.Cost = int16(values[0]), int16(values[1]), int16(values[2])
+	m.PartOfSpeech = record[4]
+	return
+}
+
+// parseCosts parses the gzipped connection cost matrix.
+func (dictionary *japaneseDictionary) parseCosts(data []byte) (err error) {
+	var size [2]uint16
+
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return
+	}
+	err = binary.Read(zr, binary.LittleEndian, &size)
+	if err != nil {
+		return
+	}
+	dictionary.LeftIDs = int(size[1])
+	dictionary.Costs = make([]int16, int(size[0])*int(size[1]))
+	return binary.Read(zr, binary.LittleEndian, dictionary.Costs)
+}
+
+// parseUnknownWords parses the records of unknown words into their character categories.
+func (dictionary *japaneseDictionary) parseUnknownWords(data string) (err error) {
+	r := csv.NewReader(strings.NewReader(data))
+	r.FieldsPerRecord = 5
+	records, err := r.ReadAll()
+	if err != nil {
+		return
+	}
+
+	for _, record := range records {
+		category, ok := dictionary.Categories[record[0]]
+		if !ok {
+			return fmt.Errorf("%w: %v", ErrInvalidDictionaryRecord, record)
+		}
+		m, err := parseMorpheme(record)
+		if err != nil {
+			return err
+		}
+		category.Unknown = append(category.Unknown, m)
+	}
+	return
+}
+
+// parseChars parses the character category ranges.
+func (dictionary *japaneseDictionary) parseChars(data string) (err error) {
+	r := csv.NewReader(strings.NewReader(data))
+	r.FieldsPerRecord = 3
+	records, err := r.ReadAll()
+	if err != nil {
+		return
+	}
+
+	for _, record := range records {
+		first, firstErr := strconv.ParseInt(record[0], 16, 32)
+		last, lastErr := strconv.ParseInt(record[1], 16, 32)
+		category, ok := dictionary.Categories[record[2]]
+		if firstErr != nil || lastErr != nil || !ok {
+			return fmt.Errorf("%w: %v", ErrInvalidDictionaryRecord, record)
+		}
+		dictionary.Chars = append(dictionary.Chars, japaneseCharRange{First: rune(first), Last: rune(last), Category: category})
+	}
+	return
+}
+
+// category returns the character category of a character.
+func (dictionary *japaneseDictionary) category(r rune) *japaneseCharCategory {
+	i := sort.Search(len(dictionary.Chars), func(i int) bool {
+		return dictionary.Chars[i].Last >= r
+	})
+	if i < len(dictionary.Chars) && dictionary.Chars[i].First <= r {
+		return dictionary.Chars[i].Category
+	}
+	return dictionary.Categories["DEFAULT"]
+}
+
+// connectionCost returns the cost of a morpheme following another one.
+func (dictionary *japaneseDictionary) connectionCost(left, right *morpheme) int {
+	return int(dictionary.Costs[int(left.RightID)*dictionary.LeftIDs+int(right.LeftID)])
+}
+
+// lookup returns the dictionary words that the text starts with, narrowing down the range of words
+// starting with the first bytes of the text one byte at a time.
+func (dictionary *japaneseDictionary) lookup(s string) (words []*morpheme) {
+	lo, hi := 0, len(dictionary.Words)
+	for length := 1; length <= len(s) && lo < hi; length++ {
+		prefix := s[:length]
+		surfacePrefix := func(i int) string {
+			surface := dictionary.Words[i].Surface
+			if len(surface) > length {
+				return surface[:length]
+			}
+			return surface
+		}
+
+		lo += sort.Search(hi-lo, func(i int) bool { return surfacePrefix(lo+i) >= prefix })
+		hi = lo + sort.Search(hi-lo, func(i int) bool { return surfacePrefix(lo+i) > prefix })
+		for i := lo; i < hi && len(dictionary.Words[i].Surface) == length; i++ {
+			words = append(words, &dictionary.Words[i])
+		}
+	}
+	return
+}
+
+// unknownMorphemes returns the unknown words made from the characters at the start of the text the
+// way MeCab does: from the run of characters of the same category and from its first characters,
+// depending on the category. Unknown words are only made if no dictionary word starts there unless
+// the category says otherwise.
+func (dictionary *japaneseDictionary) unknownMorphemes(s string, known bool) (morphemes []*morpheme) {
+	r, size := utf8.DecodeRuneInString(s)
+	category := dictionary.category(r)
+	if known && !category.Invoke {
+		return
+	}
+
+	// ends contains the byte offset of the end of each character of the run
+	ends := []int{size}
+	for ends[len(ends)-1] < len(s) && len(ends) < maxJapaneseUnknownLength {
+		r, size = utf8.DecodeRuneInString(s[ends[len(ends)-1]:])
+		if dictionary.category(r) != category {
+			break
+		}
+		ends = append(ends, ends[len(ends)-1]+size)
+	}
+
+	add := func(end int) {
+		for _, unknown := range category.Unknown {
+			m := unknown
+			m.Surface = s[:end]
+			switch category {
+			case dictionary.Categories["KATAKANA"]:
+				m.Reading = m.Surface
+			case dictionary.Categories["HIRAGANA"]:
+				m.Reading = hiraganaToKatakana(m.Surface)
+			}
+			morphemes = append(morphemes, &m)
+		}
+	}
+
+	if category.Group {
+		add(ends[len(ends)-1])
+	}
+	for length := 1; length <= category.Length && length <= len(ends); length++ {
+		if category.Group && length == len(ends) {
+			break
+		}
+		add(ends[length-1])
+	}
+	if len(morphemes) == 0 && !known {
+		add(ends[0])
+	}
+	return
+}
+
+// latticeNode is a morpheme found at a position of the analyzed text along with the lowest cost
+// path leading to it.
+type latticeNode struct {
+	Morpheme *morpheme
+	Start    int // Byte offset of the start of the morpheme
+	End      int // Byte offset of the end of the morpheme
+	Cost     int // Total cost of the best path ending with this node
+	Prev     *latticeNode
+}
+
+// JapaneseTokenizer segments Japanese text into words by finding the sequence of dictionary words
+// and unknown words with the lowest cost using the Viterbi algorithm, with the words and the costs
+// of the IPADIC dictionary. Tokens have their part of speech as type along with their base form and
+// reading when they are known. Whitespace and punctuations are dropped.
+type JapaneseTokenizer struct {
+	dictionary *japaneseDictionary
+}
+
+// newJapaneseTokenizer creates a Japanese tokenizer that uses the embedded dictionary.
+func newJapaneseTokenizer(params []string) (Tokenizer, error) {
+	return NewJapaneseTokenizer()
+}
+
+// NewJapaneseTokenizer creates a Japanese tokenizer that uses the embedded dictionary.
+func NewJapaneseTokenizer() (tokenizer *JapaneseTokenizer, err error) {
+	dictionary, err := loadJapaneseDictionary()
+	if err != nil {
+		return
+	}
+	tokenizer = &JapaneseTokenizer{dictionary: dictionary}
+	return
+}
+
+// Tokenize splits text into tokens.
+func (tokenizer *JapaneseTokenizer) Tokenize(s string) (tokens []Token) {
+	for _, node := range tokenizer.segment(s) {
+		term := s[node.Start:node.End]
+		if isJapaneseDiscardable(term) {
+			continue
+		}
+		tokens = append(tokens, Token{
+			Term:     term,
+			Type:     node.Morpheme.PartOfSpeech,
+			Position: len(tokens),
+			Start:    node.Start,
+			End:      node.End,
+			BaseForm: node.Morpheme.BaseForm,
+			Reading:  node.Morpheme.Reading,
+		})
+	}
+	return
+}
+
+// segment returns the nodes of the lowest cost path through the lattice of the text.
+func (tokenizer *JapaneseTokenizer) segment(s string) (nodes []*latticeNode) {
+	dictionary := tokenizer.dictionary
+
+	// endingAt contains the nodes ending at each byte offset
+	endingAt := make([][]*latticeNode, len(s)+1)
+	endingAt[0] = []*latticeNode{{Morpheme: bosMorpheme}}
+
+	best := func(m *morpheme, i int) (prev *latticeNode, cost int) {
+		cost = math.MaxInt32
+		for _, node := range endingAt[i] {
+			c := node.Cost + dictionary.connectionCost(node.Morpheme, m)
+			if c < cost {
+				prev, cost = node, c
+			}
+		}
+		cost += int(m.Cost)
+		return
+	}
+
+	for i := range s {
+		if len(endingAt[i]) == 0 {
+			continue
+		}
+
+		candidates := dictionary.lookup(s[i:])
+		candidates = append(candidates, dictionary.unknownMorphemes(s[i:], len(candidates) > 0)...)
+		for _, m := range candidates {
+			end := i + len(m.Surface)
+			prev, cost := best(m, i)
+			endingAt[end] = append(endingAt[end], &latticeNode{Morpheme: m, Start: i, End: end, Cost: cost, Prev: prev})
+		}
+	}
+
+	eos, _ := best(bosMorpheme, len(s))
+	for node := eos; node != nil && node.Prev != nil; node = node.Prev {
+		nodes = append(nodes, node)
+	}
+	for i, j := 0, len(nodes)-1; i < j; i, j = i+1, j-1 {
+		nodes[i], nodes[j] = nodes[j], nodes[i]
+	}
+	return
+}
+
+// isJapaneseDiscardable returns whether a term only contains whitespace and punctuations.
+func isJapaneseDiscardable(term string) bool {
+	for _, r := range term {
+		if !unicode.IsSpace(r) && !unicode.IsPunct(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// JapaneseBaseFormTokenFilter replaces conjugated words by their dictionary form so that, for
+// example, 食べた and 食べる produce the same term.
+func JapaneseBaseFormTokenFilter(tokens []Token) (filteredTokens []Token) {
+	for _, token := range tokens {
+		if token.BaseForm != "" {
+			token.Term = token.BaseForm
+		}
+		filteredTokens = append(filteredTokens, token)
+	}
+	return
+}
+
+// JapanesePartOfSpeechStopTokenFilter removes tokens whose type is one of its part-of-speech tags or
+// a subcategory of one of them, e.g. the tag 助詞 removes tokens of type 助詞-格助詞.
+type JapanesePartOfSpeechStopTokenFilter struct {
+	Tags []string
+}
+
+// newJapanesePartOfSpeechStopTokenFilter creates a part-of-speech stop filter that removes the
+// tags given as parameters, or particles, auxiliary verbs, symbols, conjunctions, and fillers if
+// there are none.
+func newJapanesePartOfSpeechStopTokenFilter(params []string) (TokenFilter, error) {
+	tags := defaultJapaneseStopTags
+	if len(params) > 0 {
+		tags = params
+	}
+	return &JapanesePartOfSpeechStopTokenFilter{Tags: tags}, nil
+}
+
+// Filter removes the tokens with a stop tag.
+func (filter *JapanesePartOfSpeechStopTokenFilter) Filter(tokens []Token) (filteredTokens []Token) {
+	for _, token := range tokens {
+		if !filter.isStopTag(token.Type) {
+			filteredTokens = append(filteredTokens, token)
+		}
+	}
+	return
+}
+
+func (filter *JapanesePartOfSpeechStopTokenFilter) isStopTag(partOfSpeech string) bool {
+	for _, tag := range filter.Tags {
+		if partOfSpeech == tag || strings.HasPrefix(partOfSpeech, tag+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// hiraganaToKatakana converts the Hiragana characters of a string into Katakana.
+func hiraganaToKatakana(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'ぁ' && r <= 'ゖ' {
+			return r + 'ァ' - 'ぁ'
+		}
+		return r
+	}, s)
+}
+
+// katakanaToHiragana converts the Katakana characters of a string into Hiragana.
+func katakanaToHiragana(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'ァ' && r <= 'ヶ' {
+			return r - 'ァ' + 'ぁ'
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,69 @@
+package folder
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJapaneseTokenizer(t *testing.T) {
+	tokenizer, err := NewJapaneseTokenizer()
+	assert.Nil(t, err)
+
+	tokens := tokenizer.Tokenize("本を読んだ。")
+	assert.Equal(t, []string{"本", "を", "読ん", "だ"}, terms(tokens))
+	assert.Equal(t, Token{Term: "読ん", Type: "動詞-自立", Position: 2, Start: 6, End: 12, BaseForm: "読む", Reading: "ヨン"}, tokens[2])
+
+	tokens = tokenizer.Tokenize("私は寿司を食べました")
+	assert.Equal(t, []string{"私", "は", "寿司", "を", "食べ", "まし", "た"}, terms(tokens))
+
+	tokens = tokenizer.Tokenize("日本語能力試験")
+	assert.Equal(t, []string{"日本語", "能力", "試験"}, terms(tokens))
+
+	tokens = tokenizer.Tokenize("吾輩は猫である。名前はまだ無い。どこで生れたかとんと見当がつかぬ。")
+	assert.Equal(t, []string{
+		"吾輩", "は", "猫", "で", "ある", "名前", "は", "まだ", "無い",
+		"どこ", "で", "生れ", "た", "か", "とんと", "見当", "が", "つか", "ぬ",
+	}, terms(tokens))
+
+	tokens = tokenizer.Tokenize("東京都は新型コロナウイルスの感染者が確認されたと発表しました")
+	assert.Equal(t, []string{
+		"東京", "都", "は", "新型", "コロナ", "ウイルス", "の", "感染", "者", "が",
+		"確認", "さ", "れ", "た", "と", "発表", "し", "まし", "た",
+	}, terms(tokens))
+	assert.Equal(t, Token{Term: "東京", Type: "名詞-固有名詞-地域-一般", Position: 0, Start: 0, End: 6, BaseForm: "東京", Reading: "トウキョウ"}, tokens[0])
+	assert.Equal(t, Token{Term: "さ", Type: "動詞-自立", Position: 11, Start: 60, End: 63, BaseForm: "する", Reading: "サ"}, tokens[11])
+
+	// Words that are not in the dictionary are grouped by character category
+	tokens = tokenizer.Tokenize("彼女はFolderでポケモンGOを使っていません")
+	assert.Equal(t, []string{"彼女", "は", "Folder", "で", "ポケモン", "GO", "を", "使っ", "て", "い", "ませ", "ん"}, terms(tokens))
+	assert.Equal(t, Token{Term: "ポケモン", Type: "名詞-一般", Position: 4, Start: 18, End: 30, Reading: "ポケモン"}, tokens[4])
+}
+
+func TestJapaneseAnalyzer(t *testing.T) {
+	index := New()
+
+	assert.Equal(t, []string{"食べた"}, index.AnalyzeField("", "食べた"))
+
+	index.SetDefaultAnalyzer(JapaneseAnalyzer)
+	assert.Equal(t, []string{"食べる"}, index.AnalyzeField("", "食べた"))
+	assert.Equal(t, []string{"東京", "行く"}, index.AnalyzeField("", "東京に行きたい"))
+	assert.Equal(t, []string{"高い"}, index.AnalyzeField("", "高かった"))
+
+	index.IndexWithID(map[string]interface{}{"Expression": "寿司を食べる"}, "1")
+	res, _ := index.Search("寿司を食べました")
+	assert.Equal(t, 1, res.Count)
+}
+
+func TestJapanesePartOfSpeechStopTokenFilter(t *testing.T) {
+	filter, err := newJapanesePartOfSpeechStopTokenFilter([]string{"名詞"})
+	assert.Nil(t, err)
+
+	tokens := filter.Filter([]Token{
+		{Term: "猫", Type: "名詞-一般"},
+		{Term: "が", Type: "助詞-格助詞"},
+		{Term: "名詞", Type: "名詞"},
+		{Term: "名", Type: "名詞接尾"},
+	})
+	assert.Equal(t, []string{"が", "名"}, terms(tokens))
+}
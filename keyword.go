@@ -0,0 +1,150 @@
+package folder
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// normalize passes the whole value of a keyword field through the normalizers of the field. Only the
+// first token is kept if a normalizer splits the value, and the value is kept as it is if a
+// normalizer removes it.
+func (mapping *FieldMapping) normalize(s string) string {
+	tokens := []Token{{Term: s, Type: TokenTypeWord, End: len(s)}}
+	for _, normalizer := range mapping.Normalizers {
+		constructor, ok := tokenFilterConstructors[normalizer]
+		if !ok {
+			continue
+		}
+
+		tokenFilter, err := constructor(nil)
+		if err != nil {
+			continue
+		}
+		tokens = tokenFilter.Filter(tokens)
+		if len(tokens) == 0 {
+			return s
+		}
+	}
+	return tokens[0].Term
+}
+
+// SearchTerm finds the documents whose exact field, such as a keyword field, contains a value
+// verbatim. It is equivalent to SearchTerms with a single value.
+func (index *Index) SearchTerm(field, value string, opts SearchOptions) (res SearchResult, err error) {
+	return index.SearchTerms(field, []string{value}, opts)
+}
+
+// SearchTerms finds the documents whose exact field contains any of the values. The values go through
+// the normalizers of keyword fields and are parsed for the other exact fields just like the values of
+// indexed documents, e.g. "42" finds the documents whose integer field contains 42. Documents that
+// contain more of the values score higher.
+func (index *Index) SearchTerms(field string, values []string, opts SearchOptions) (res SearchResult, err error) {
+	return index.SearchTermsContext(context.Background(), field, values, opts)
+}
+
+// SearchTermsContext searches values just like SearchTerms but stops loading shards and scoring
+// documents once the context is done, in which case the result is flagged as timed out.
+func (index *Index) SearchTermsContext(ctx context.Context, field string, values []string, opts SearchOptions) (res SearchResult, err error) {
+	if !opts.UseCache {
+		debug("Search terms", field, values, "(not cached)")
+		return index.uncached().searchTerms(ctx, field, values, opts)
+	}
+	debug("Search terms", field, values, "(cached)")
+	return index.searchTerms(ctx, field, values, opts)
+}
+
+func (index *Index) searchTerms(ctx context.Context, field string, values []string, opts SearchOptions) (res SearchResult, err error) {
+	var matchedDocumentIDs []string
+	var sortedDocumentIDs []string
+	var tokens []string
+	var scores []float64
+
+	startTime := time.Now()
+	defer func() {
+		res.Time.Total = time.Since(startTime)
+	}()
+
+	tokens, err = index.exactTerms(field, values)
+	if err != nil {
+		return
+	}
+
+	matchedDocumentIDs, res.Time.Match, err = index.findDocumentsWithAnyToken(ctx, tokens)
+	if err != nil {
+		res.TimedOut, err = timedOut(ctx, err)
+		return
+	}
+
+	sortedDocumentIDs, scores, res.Time.Sort, err = index.sortDocuments(ctx, matchedDocumentIDs, tokens)
+	if err != nil {
+		res.TimedOut, err = timedOut(ctx, err)
+		if err != nil {
+			return
+		}
+	}
+
+	sortedDocumentIDs, scores, res.Facets, err = index.arrangeDocuments(ctx, sortedDocumentIDs, scores, opts)
+	if err != nil {
+		res.TimedOut, err = timedOut(ctx, err)
+		return
+	}
+	res.Count = len(sortedDocumentIDs)
+
+	res.Hits, err = index.fetchHits(ctx, sortedDocumentIDs, scores, opts.Size, opts.From)
+	if err != nil {
+		res.TimedOut, err = timedOut(ctx, err)
+		return
+	}
+
+	return
+}
+
+// exactMapping returns the mapping of an exact field.
+func (index *Index) exactMapping(field string) (mapping FieldMapping, err error) {
+	mapping, ok := index.Mapping.Fields[field]
+	if !ok || !mapping.Type.exact() {
+		err = fmt.Errorf("%w: %s is not an exact field", ErrInvalidQuery, field)
+	}
+	return
+}
+
+// exactTerms returns the terms of values of an exact field.
+func (index *Index) exactTerms(field string, values []string) (terms []string, err error) {
+	mapping, err := index.exactMapping(field)
+	if err != nil {
+		return
+	}
+
+	for _, value := range values {
+		s, ok := mapping.value(value)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidFieldValue, field, value)
+		}
+		terms = append(terms, exactTerm(field, s))
+	}
+	return
+}
+
+// findDocumentsWithAnyToken finds the document IDs which contain at least one of the tokens.
+func (index *Index) findDocumentsWithAnyToken(ctx context.Context, tokens []string) (documentIDs []string, elapsedTime time.Duration, err error) {
+	var termStat TermStat
+
+	startTime := time.Now()
+	documentIDsSet := MakeStringSet([]string{})
+
+	for _, token := range tokens {
+		termStat, _, err = index.fetchTermStat(ctx, token)
+		if err != nil {
+			return
+		}
+
+		for id := range termStat.TermFrequencies {
+			documentIDsSet.Add(id)
+		}
+	}
+
+	documentIDs = documentIDsSet.List()
+	elapsedTime = time.Since(startTime)
+	return
+}
@@ -0,0 +1,75 @@
+package folder
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func keywordIndex(t *testing.T) *Index {
+	index := New()
+	err := index.SetMapping(Mapping{
+		Fields: map[string]FieldMapping{
+			"title":  {Type: FieldTypeText},
+			"sku":    {Type: FieldTypeKeyword},
+			"email":  {Type: FieldTypeKeyword, Normalizers: []string{"lowercase"}},
+			"tags":   {Type: FieldTypeKeyword, Normalizers: []string{"lowercase", "ascii_folding"}},
+			"views":  {Type: FieldTypeInteger},
+			"status": {Type: FieldTypeKeyword},
+		},
+		Dynamic: DynamicStrict,
+	})
+	assert.Nil(t, err)
+
+	_, err = index.IndexWithID(map[string]interface{}{"title": "Red shoes", "sku": "SKU-123/A", "email": "Lilis@Example.com", "tags": []interface{}{"Shoes", "Café"}, "views": 10.0, "status": "published"}, "1")
+	assert.Nil(t, err)
+	_, err = index.IndexWithID(map[string]interface{}{"title": "Blue shoes", "sku": "SKU-124/A", "email": "song@example.com", "tags": []interface{}{"shoes"}, "views": 25.0, "status": "draft"}, "2")
+	assert.Nil(t, err)
+	_, err = index.IndexWithID(map[string]interface{}{"title": "Red hat", "sku": "sku-123/a", "tags": []interface{}{"hats", "cafe"}, "views": 5.0, "status": "published"}, "3")
+	assert.Nil(t, err)
+	return index
+}
+
+func TestSearchTerm(t *testing.T) {
+	index := keywordIndex(t)
+
+	// Keywords are matched verbatim
+	res, err := index.SearchTerm("sku", "SKU-123/A", DefaultSearchOptions)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, res.Count)
+	assert.Equal(t, "1", res.Hits[0].ID)
+
+	res, _ = index.SearchTerm("sku", "SKU-123", DefaultSearchOptions)
+	assert.Equal(t, 0, res.Count)
+
+	// Keywords are not found by full-text searches
+	res, _ = index.Search("sku")
+	assert.Equal(t, 0, res.Count)
+
+	// Values go through the normalizers of the field
+	res, _ = index.SearchTerm("email", "LILIS@example.COM", DefaultSearchOptions)
+	assert.Equal(t, 1, res.Count)
+	res, _ = index.SearchTerm("tags", "CAFE", DefaultSearchOptions)
+	assert.Equal(t, 2, res.Count)
+
+	// Exact fields other than keywords can be searched too
+	res, _ = index.SearchTerm("views", "25", DefaultSearchOptions)
+	assert.Equal(t, 1, res.Count)
+	assert.Equal(t, "2", res.Hits[0].ID)
+
+	// Documents having more of the values come first
+	res, _ = index.SearchTerms("tags", []string{"shoes", "café"}, DefaultSearchOptions)
+	assert.Equal(t, 3, res.Count)
+	assert.Equal(t, "1", res.Hits[0].ID)
+
+	_, err = index.SearchTerm("title", "red", DefaultSearchOptions)
+	assert.True(t, errors.Is(err, ErrInvalidQuery))
+	_, err = index.SearchTerm("views", "many", DefaultSearchOptions)
+	assert.True(t, errors.Is(err, ErrInvalidFieldValue))
+
+	err = index.SetFieldMapping("code", FieldMapping{Type: FieldTypeKeyword, Normalizers: []string{"uppercase"}})
+	assert.True(t, errors.Is(err, ErrUnknownTokenFilter))
+	err = index.SetFieldMapping("code", FieldMapping{Type: FieldTypeText, Normalizers: []string{"lowercase"}})
+	assert.True(t, errors.Is(err, ErrInvalidParameter))
+}
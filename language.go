@@ -0,0 +1,320 @@
+package folder
+
+import (
+	_ "embed"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// The bundled texts that the trigram profiles of languages written in Latin script are made from.
+var (
+	//go:embed data/languages/english.txt
+	englishProfileText string
+	//go:embed data/languages/german.txt
+	germanProfileText string
+	//go:embed data/languages/indonesian.txt
+	indonesianProfileText string
+	//go:embed data/languages/spanish.txt
+	spanishProfileText string
+
+	languageProfileTexts = map[string]string{
+		"english":    englishProfileText,
+		"german":     germanProfileText,
+		"indonesian": indonesianProfileText,
+		"spanish":    spanishProfileText,
+	}
+)
+
+// scriptLanguages are the languages that are recognized by their script rather than by a profile.
+var scriptLanguages = []string{"japanese", "korean"}
+
+// languageProfile contains how often each trigram appears in a language.
+type languageProfile struct {
+	Counts map[string]int
+	Total  int
+}
+
+var (
+	languageProfilesOnce  sync.Once
+	languageProfilesCache map[string]*languageProfile
+	languageTrigramCount  int // Number of distinct trigrams in every profile
+)
+
+// loadLanguageProfiles builds the trigram profiles from the bundled texts the first time they are
+// needed.
+func loadLanguageProfiles() map[string]*languageProfile {
+	languageProfilesOnce.Do(func() {
+		languageProfilesCache = make(map[string]*languageProfile)
+		distinct := MakeStringSet([]string{})
+
+		for language, text := range languageProfileTexts {
+			profile := &languageProfile{Counts: make(map[string]int)}
+			for _, trigram := range trigrams(text) {
+				profile.Counts[trigram]++
+				profile.Total++
+				distinct.Add(trigram)
+			}
+			languageProfilesCache[language] = profile
+		}
+		languageTrigramCount = len(distinct.List())
+	})
+	return languageProfilesCache
+}
+
+// trigrams returns the trigrams of the lowercased words of a text. Words are surrounded by spaces
+// so that the trigrams also tell how words start and end.
+func trigrams(s string) (grams []string) {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	for _, word := range words {
+		runes := []rune(" " + word + " ")
+		for i := 0; i+3 <= len(runes); i++ {
+			grams = append(grams, string(runes[i:i+3]))
+		}
+	}
+	return
+}
+
+// DetectableLanguages returns the sorted languages that DetectLanguage can recognize.
+func DetectableLanguages() (languages []string) {
+	languages = append(languages, scriptLanguages...)
+	for language := range languageProfileTexts {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return
+}
+
+// DetectLanguage returns the language of a text among the given languages, or among every
+// detectable language if there is none. Korean and Japanese are recognized by their script while
+// the other languages are recognized by comparing the trigrams of the text with their bundled
+// profiles. It returns an empty string if the language cannot be told, e.g. if the text has no
+// letters or is written in the script of a language that is not given.
+func DetectLanguage(s string, languages ...string) (language string) {
+	if len(languages) == 0 {
+		languages = DetectableLanguages()
+	}
+
+	var hangul, kana, han, others int
+	for _, r := range s {
+		switch {
+		case unicode.Is(unicode.Hangul, r):
+			hangul++
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			kana++
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.IsLetter(r):
+			others++
+		}
+	}
+
+	// A Hangul or Han character says as much as a few Latin letters
+	if cjk := hangul + kana + han; cjk > 0 && cjk*3 >= others {
+		switch {
+		case hangul >= kana+han && contains(languages, "korean"):
+			return "korean"
+		case kana+han > hangul && contains(languages, "japanese"):
+			// Han characters without Kana are also considered Japanese as Chinese isn't detectable
+			return "japanese"
+		}
+		return ""
+	}
+
+	profiles := loadLanguageProfiles()
+	grams := trigrams(s)
+	if len(grams) == 0 {
+		return ""
+	}
+
+	best := math.Inf(-1)
+	for _, candidate := range languages {
+		profile, ok := profiles[candidate]
+		if !ok {
+			continue
+		}
+
+		// Naive Bayes with add-one smoothing so that unknown trigrams don't rule out a language
+		score := 0.0
+		for _, gram := range grams {
+			score += math.Log(float64(profile.Counts[gram]+1) / float64(profile.Total+languageTrigramCount))
+		}
+		if score > best || (score == best && candidate < language) {
+			best = score
+			language = candidate
+		}
+	}
+	return
+}
+
+// LanguageDetection describes how the language of documents is detected when they are indexed.
+type LanguageDetection struct {
+	Analyzers map[string]string // Analyzer names of the candidate languages
+	Field     string            // Field in which the detected language is stored, if any
+	PerField  bool              // Whether the language of each field is detected rather than the language of the whole document
+}
+
+// enabled returns whether the language of documents is detected.
+func (detection *LanguageDetection) enabled() bool {
+	return len(detection.Analyzers) > 0
+}
+
+// languages returns the sorted candidate languages.
+func (detection *LanguageDetection) languages() (languages []string) {
+	for language := range detection.Analyzers {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return
+}
+
+// SetLanguageDetection makes the index detect the language of documents, or of each of their
+// fields, among the languages of the detection when they are indexed, and analyze the fields
+// without their own analyzer with the analyzer of the detected language. Fields whose language
+// cannot be detected use the default analyzer. Queries are analyzed with the analyzers of every
+// candidate language. Just like SetFieldAnalyzer, it should be set before indexing any document.
+func (index *Index) SetLanguageDetection(detection LanguageDetection) (err error) {
+	detectable := DetectableLanguages()
+	for language, analyzerName := range detection.Analyzers {
+		if !contains(detectable, language) {
+			return fmt.Errorf("%w: %s", ErrUnknownLanguage, language)
+		}
+
+		_, err = index.Analyzer(analyzerName)
+		if err != nil {
+			return
+		}
+	}
+
+	index.Analysis.LanguageDetection = detection
+	return
+}
+
+// collectText collects the strings of an arbitrary value by field.
+func collectText(parentField string, v interface{}, texts map[string][]string) {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for field, value := range value {
+			if parentField != "" {
+				field = parentField + "." + field
+			}
+			collectText(field, value, texts)
+		}
+	case []map[string]interface{}:
+		for _, v := range value {
+			collectText(parentField, v, texts)
+		}
+	case []interface{}:
+		for _, v := range value {
+			collectText(parentField, v, texts)
+		}
+	case []string:
+		texts[parentField] = append(texts[parentField], value...)
+	case *string:
+		if value != nil {
+			texts[parentField] = append(texts[parentField], *value)
+		}
+	case string:
+		texts[parentField] = append(texts[parentField], value)
+	}
+}
+
+// detectsLanguage returns whether the language of a field is detected, which is the case for the
+// fields without their own analyzer other than the field storing the detected language.
+func (settings *AnalysisSettings) detectsLanguage(field string) bool {
+	detection := &settings.LanguageDetection
+	if !detection.enabled() {
+		return false
+	}
+	if detection.Field != "" && (field == detection.Field || strings.HasPrefix(field, detection.Field+".")) {
+		return false
+	}
+	_, ok := settings.FieldAnalyzers[field]
+	return !ok
+}
+
+// detectLanguages detects the language of a document, stored with an empty field name, or the
+// language of each of its fields if the language is detected per field.
+func (index *Index) detectLanguages(document map[string]interface{}) (languages map[string]string) {
+	detection := &index.Analysis.LanguageDetection
+	if !detection.enabled() {
+		return
+	}
+
+	texts := make(map[string][]string)
+	collectText("", document, texts)
+
+	fields := []string{}
+	for field := range texts {
+		if index.Analysis.detectsLanguage(field) && index.Mapping.analyzesAsText(field) {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	languages = make(map[string]string)
+	if detection.PerField {
+		for _, field := range fields {
+			if language := DetectLanguage(strings.Join(texts[field], "\n"), detection.languages()...); language != "" {
+				languages[field] = language
+			}
+		}
+		return
+	}
+
+	var all []string
+	for _, field := range fields {
+		all = append(all, texts[field]...)
+	}
+	if language := DetectLanguage(strings.Join(all, "\n"), detection.languages()...); language != "" {
+		languages[""] = language
+	}
+	return
+}
+
+// withLanguages returns a shallow copy of a document that also contains its detected languages in
+// the language field if there is one.
+func (index *Index) withLanguages(document map[string]interface{}, languages map[string]string) map[string]interface{} {
+	detection := &index.Analysis.LanguageDetection
+	if !detection.enabled() || detection.Field == "" {
+		return document
+	}
+
+	copied := make(map[string]interface{}, len(document)+1)
+	for field, value := range document {
+		copied[field] = value
+	}
+
+	if !detection.PerField {
+		if language, ok := languages[""]; ok {
+			copied[detection.Field] = language
+		}
+		return copied
+	}
+
+	fieldLanguages := make(map[string]interface{})
+	for field, language := range languages {
+		fieldLanguages[field] = language
+	}
+	copied[detection.Field] = fieldLanguages
+	return copied
+}
+
+// fieldAnalyzerIn returns the analyzer name of a field given the detected languages of its document.
+func (index *Index) fieldAnalyzerIn(field string, languages map[string]string) string {
+	if index.Analysis.detectsLanguage(field) {
+		key := ""
+		if index.Analysis.LanguageDetection.PerField {
+			key = field
+		}
+		if language, ok := languages[key]; ok {
+			return index.Analysis.LanguageDetection.Analyzers[language]
+		}
+	}
+	return index.Analysis.fieldAnalyzer(field)
+}
@@ -0,0 +1,109 @@
+package folder
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	assert.Equal(t, "english", DetectLanguage("Where is the nearest train station?"))
+	assert.Equal(t, "indonesian", DetectLanguage("Di mana stasiun kereta terdekat?"))
+	assert.Equal(t, "german", DetectLanguage("Ich habe heute keine Zeit"))
+	assert.Equal(t, "spanish", DetectLanguage("Me gusta mucho la comida de mi madre"))
+	assert.Equal(t, "korean", DetectLanguage("Folder는 작은 검색 엔진입니다"))
+	assert.Equal(t, "japanese", DetectLanguage("日本語の文書"))
+
+	// Only the given languages are considered
+	assert.NotEqual(t, "german", DetectLanguage("Ich habe heute keine Zeit", "english", "indonesian"))
+	assert.Equal(t, "", DetectLanguage("한국어 문서", "english", "indonesian"))
+	assert.Equal(t, "", DetectLanguage("1234"))
+}
+
+func languageDetectionIndex(t *testing.T, perField bool) *Index {
+	index := New()
+	for _, language := range []string{"english", "indonesian"} {
+		err := index.AddAnalyzer(language, AnalyzerConfig{
+			Tokenizer: ComponentConfig{Type: "unicode"},
+			TokenFilters: []ComponentConfig{
+				{Type: "lowercase"},
+				{Type: "stop", Params: []string{"_" + language + "_"}},
+				{Type: "stemmer", Params: []string{language}},
+			},
+		})
+		assert.Nil(t, err)
+	}
+
+	err := index.SetLanguageDetection(LanguageDetection{
+		Analyzers: map[string]string{"english": "english", "indonesian": "indonesian", "korean": CJKAnalyzer, "japanese": JapaneseAnalyzer},
+		Field:     "language",
+		PerField:  perField,
+	})
+	assert.Nil(t, err)
+	return index
+}
+
+func TestLanguageDetection(t *testing.T) {
+	dir, err := os.MkdirTemp("", "folder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	index := languageDetectionIndex(t, false)
+	_, err = index.IndexWithID(map[string]interface{}{"title": "Searching documents in the library"}, "1")
+	assert.Nil(t, err)
+	_, err = index.IndexWithID(map[string]interface{}{"title": "Mencari dokumen di perpustakaan"}, "2")
+	assert.Nil(t, err)
+	_, err = index.IndexWithID(map[string]interface{}{"title": "도서관에서 문서 검색"}, "3")
+	assert.Nil(t, err)
+	_, err = index.IndexWithID(map[string]interface{}{"title": "図書館で文書を検索した"}, "4")
+	assert.Nil(t, err)
+
+	for id, language := range map[string]string{"1": "english", "2": "indonesian", "3": "korean", "4": "japanese"} {
+		document, _ := index.Fetch(id)
+		assert.Equal(t, language, document["language"], id)
+	}
+
+	// Queries are analyzed for every candidate language
+	for query, id := range map[string]string{"searched": "1", "pencarian": "2", "문서": "3", "検索する": "4"} {
+		res, _ := index.Search(query)
+		assert.Equal(t, 1, res.Count, query)
+		assert.Equal(t, id, res.Hits[0].ID, query)
+	}
+
+	// Documents are analyzed in their previous language when they are replaced
+	_, err = index.IndexWithID(map[string]interface{}{"title": "Perpustakaan kota"}, "1")
+	assert.Nil(t, err)
+	res, _ := index.Search("searched")
+	assert.Equal(t, 0, res.Count)
+
+	err = index.SaveToShards(dir+"/index", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loadedIndex, err := LoadDeferred(dir + "/index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, index.Analysis, loadedIndex.Analysis)
+
+	err = index.SetLanguageDetection(LanguageDetection{Analyzers: map[string]string{"klingon": "standard"}})
+	assert.True(t, errors.Is(err, ErrUnknownLanguage))
+}
+
+func TestLanguageDetectionPerField(t *testing.T) {
+	index := languageDetectionIndex(t, true)
+	_, err := index.IndexWithID(map[string]interface{}{"title": "Mencari dokumen", "body": "Searching documents"}, "1")
+	assert.Nil(t, err)
+
+	document, _ := index.Fetch("1")
+	assert.Equal(t, map[string]interface{}{"title": "indonesian", "body": "english"}, document["language"])
+
+	res, _ := index.Search("searched")
+	assert.Equal(t, 1, res.Count)
+	res, _ = index.Search("pencarian")
+	assert.Equal(t, 1, res.Count)
+}
@@ -0,0 +1,694 @@
+package folder
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FieldType is the type of the values of a field which decides how they are validated and indexed.
+type FieldType string
+
+const (
+	// FieldTypeText is the type of fields whose values are analyzed into terms for full-text search.
+	FieldTypeText FieldType = "text"
+	// FieldTypeKeyword is the type of fields whose values are indexed verbatim as a single term.
+	FieldTypeKeyword FieldType = "keyword"
+	// FieldTypeInteger is the type of fields whose values are whole numbers.
+	FieldTypeInteger FieldType = "integer"
+	// FieldTypeFloat is the type of fields whose values are numbers.
+	FieldTypeFloat FieldType = "float"
+	// FieldTypeBoolean is the type of fields whose values are either true or false.
+	FieldTypeBoolean FieldType = "boolean"
+	// FieldTypeDate is the type of fields whose values are time.Time values or strings in the RFC 3339
+	// format, with or without the time zone, or in the 2006-01-02 format.
+	FieldTypeDate FieldType = "date"
+	// FieldTypeGeoPoint is the type of fields whose values are locations given as objects with lat
+	// and lon fields, as [lon, lat] arrays like in GeoJSON, or as "lat,lon" strings.
+	FieldTypeGeoPoint FieldType = "geo_point"
+	// FieldTypeVector is the type of fields whose values are arrays of numbers such as embeddings.
+	FieldTypeVector FieldType = "vector"
+	// FieldTypeStored is the type of fields whose values are only stored and not indexed.
+	FieldTypeStored FieldType = "stored"
+	// FieldTypeNested is the type of fields whose values are objects or arrays of objects that are
+	// also indexed on their own so that nested queries can match the fields of each object together.
+	FieldTypeNested FieldType = "nested"
+)
+
+// fieldTypes contains every field type.
+var fieldTypes = []FieldType{
+	FieldTypeText, FieldTypeKeyword, FieldTypeInteger, FieldTypeFloat, FieldTypeBoolean, FieldTypeDate,
+	FieldTypeGeoPoint, FieldTypeVector, FieldTypeStored, FieldTypeNested,
+}
+
+// exact returns whether the values of a field type are indexed as a single term each.
+func (fieldType FieldType) exact() bool {
+	switch fieldType {
+	case FieldTypeKeyword, FieldTypeInteger, FieldTypeFloat, FieldTypeBoolean, FieldTypeDate:
+		return true
+	}
+	return false
+}
+
+// DynamicMapping tells what happens to the fields of a document that are not in the mapping.
+type DynamicMapping string
+
+const (
+	// DynamicAuto adds unknown fields to the mapping with a type guessed from their first value.
+	// Later values of another type are still accepted and indexed as if the field weren't mapped.
+	DynamicAuto DynamicMapping = "auto"
+	// DynamicIgnore stores unknown fields without indexing them.
+	DynamicIgnore DynamicMapping = "ignore"
+	// DynamicStrict rejects documents that contain unknown fields.
+	DynamicStrict DynamicMapping = "strict"
+)
+
+// FieldMapping describes the values of a field and how they are indexed.
+type FieldMapping struct {
+	Type       FieldType
+	Analyzer   string  // Analyzer of a text field, the field or default analyzer is used if it is empty
+	Boost      float64 // Number of times each term of the field is counted when scoring, 1 if it is 0
+	NotStored  bool    // Whether the values are left out of the stored documents and can only be searched
+	Dimensions int     // Number of dimensions of the vectors of a vector field, any if it is 0
+	Inferred   bool    // Whether the mapping was guessed from the first value of the field
+
+	// Normalizers are the token filters, such as lowercase, that the values of a keyword field go
+	// through as a whole before they are indexed or queried.
+	Normalizers []string
+}
+
+// frequency returns how many times each term of the field is counted.
+func (mapping *FieldMapping) frequency() int {
+	if mapping.Boost <= 1 {
+		return 1
+	}
+	return int(math.Round(mapping.Boost))
+}
+
+// Mapping is the schema of the documents of an index. It declares the type of each field, by field
+// path such as "author.name", and what happens to the fields that it doesn't declare.
+type Mapping struct {
+	Fields  map[string]FieldMapping
+	Dynamic DynamicMapping
+}
+
+// NewMapping creates a mapping without fields that adds the fields of documents as they are
+// indexed.
+func NewMapping() (mapping Mapping) {
+	mapping.Fields = make(map[string]FieldMapping)
+	mapping.Dynamic = DynamicAuto
+	return
+}
+
+// indexesUnstoredFields returns whether there are fields whose values are indexed but not stored.
+func (mapping *Mapping) indexesUnstoredFields() bool {
+	return len(mapping.unstoredFields()) > 0
+}
+
+// unstoredFields returns the fields whose values are indexed but not stored.
+func (mapping *Mapping) unstoredFields() (fields []string) {
+	for field, fieldMapping := range mapping.Fields {
+		if fieldMapping.NotStored && fieldMapping.Type != FieldTypeStored {
+			fields = append(fields, field)
+		}
+	}
+	return
+}
+
+// analyzesAsText returns whether a field is analyzed as text, which is also the case for fields that
+// are not in the mapping.
+func (mapping *Mapping) analyzesAsText(field string) bool {
+	fieldMapping, ok := mapping.Fields[field]
+	return !ok || fieldMapping.Type == FieldTypeText
+}
+
+// SetMapping replaces the mapping of the index. The analyzers of text fields become their field
+// analyzers. Just like SetFieldAnalyzer, it should be set before indexing any document.
+func (index *Index) SetMapping(mapping Mapping) (err error) {
+	switch mapping.Dynamic {
+	case "":
+		mapping.Dynamic = DynamicAuto
+	case DynamicAuto, DynamicIgnore, DynamicStrict:
+	default:
+		return fmt.Errorf("%w: dynamic mapping %s", ErrInvalidParameter, mapping.Dynamic)
+	}
+
+	for field, fieldMapping := range mapping.Fields {
+		err = index.validateFieldMapping(field, fieldMapping)
+		if err != nil {
+			return
+		}
+	}
+
+	index.Mapping = Mapping{Fields: make(map[string]FieldMapping), Dynamic: mapping.Dynamic}
+	for field, fieldMapping := range mapping.Fields {
+		index.setFieldMapping(field, fieldMapping)
+	}
+	return
+}
+
+// SetFieldMapping adds or replaces the mapping of a field. Just like SetFieldAnalyzer, it should be
+// set before indexing any document.
+func (index *Index) SetFieldMapping(field string, mapping FieldMapping) (err error) {
+	err = index.validateFieldMapping(field, mapping)
+	if err != nil {
+		return
+	}
+
+	index.setFieldMapping(field, mapping)
+	return
+}
+
+func (index *Index) validateFieldMapping(field string, mapping FieldMapping) (err error) {
+	if !containsFieldType(fieldTypes, mapping.Type) {
+		return fmt.Errorf("%w: %s", ErrUnknownFieldType, mapping.Type)
+	}
+
+	if mapping.Analyzer != "" {
+		if mapping.Type != FieldTypeText {
+			return fmt.Errorf("%w: analyzer of %s field %s", ErrInvalidParameter, mapping.Type, field)
+		}
+		_, err = index.Analyzer(mapping.Analyzer)
+		if err != nil {
+			return
+		}
+	}
+
+	for _, normalizer := range mapping.Normalizers {
+		if mapping.Type != FieldTypeKeyword {
+			return fmt.Errorf("%w: normalizer of %s field %s", ErrInvalidParameter, mapping.Type, field)
+		}
+		constructor, ok := tokenFilterConstructors[normalizer]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownTokenFilter, normalizer)
+		}
+		_, err = constructor(nil)
+		if err != nil {
+			return
+		}
+	}
+
+	// Boosts repeat terms so they can't make a field weigh less
+	if mapping.Boost != 0 && mapping.Boost < 1 {
+		return fmt.Errorf("%w: boost %g of field %s", ErrInvalidParameter, mapping.Boost, field)
+	}
+	if mapping.Dimensions < 0 || (mapping.Dimensions > 0 && mapping.Type != FieldTypeVector) {
+		return fmt.Errorf("%w: dimensions of field %s", ErrInvalidParameter, field)
+	}
+	return
+}
+
+func (index *Index) setFieldMapping(field string, mapping FieldMapping) {
+	if index.Mapping.Fields == nil {
+		index.Mapping.Fields = make(map[string]FieldMapping)
+	}
+	index.Mapping.Fields[field] = mapping
+
+	if mapping.Analyzer != "" {
+		index.Analysis.FieldAnalyzers[field] = mapping.Analyzer
+	}
+}
+
+func containsFieldType(list []FieldType, fieldType FieldType) bool {
+	for _, v := range list {
+		if v == fieldType {
+			return true
+		}
+	}
+	return false
+}
+
+// mapDocument validates the fields of a document against the mapping and returns the mappings of the
+// unknown fields when they are added dynamically.
+func (index *Index) mapDocument(document map[string]interface{}) (inferred map[string]FieldMapping, err error) {
+	inferred = make(map[string]FieldMapping)
+	err = index.mapValue("", document, inferred)
+	return
+}
+
+func (index *Index) mapValue(field string, v interface{}, inferred map[string]FieldMapping) (err error) {
+	var mapped bool
+
+	if field != "" {
+		var mapping FieldMapping
+		mapping, mapped = index.Mapping.Fields[field]
+		if !mapped {
+			mapping, mapped = inferred[field]
+		}
+		if mapped {
+			_, err = mapping.values(field, v)
+			switch {
+			case err != nil && mapping.Inferred:
+				// Values that don't match a guessed type are indexed as if the field weren't mapped
+				err = nil
+			case err != nil || mapping.Type != FieldTypeNested:
+				return
+			}
+		}
+	}
+
+	switch value := v.(type) {
+	case nil:
+	case map[string]interface{}:
+		for name, v := range value {
+			if field != "" {
+				name = field + "." + name
+			}
+			err = index.mapValue(name, v, inferred)
+			if err != nil {
+				return
+			}
+		}
+	case []map[string]interface{}:
+		for _, v := range value {
+			err = index.mapValue(field, v, inferred)
+			if err != nil {
+				return
+			}
+		}
+	case []interface{}:
+		for _, v := range value {
+			err = index.mapValue(field, v, inferred)
+			if err != nil {
+				return
+			}
+		}
+	default:
+		if mapped {
+			return
+		}
+
+		switch index.Mapping.Dynamic {
+		case DynamicStrict:
+			return fmt.Errorf("%w: %s", ErrUnknownField, field)
+		case DynamicIgnore:
+			return
+		}
+
+		// Values of other types are left alone like they used to be
+		if mapping, ok := inferFieldMapping(v); ok {
+			debug("  Add field mapping", field, mapping.Type)
+			inferred[field] = mapping
+		}
+	}
+	return
+}
+
+// inferFieldMapping guesses the mapping of a new field from its value. Arrays of numbers are mapped
+// as numbers rather than vectors since they may just as well be lists of numbers.
+func inferFieldMapping(v interface{}) (mapping FieldMapping, ok bool) {
+	switch v.(type) {
+	case string, *string, []string:
+		mapping.Type = FieldTypeText
+	case bool:
+		mapping.Type = FieldTypeBoolean
+	case float32, float64, json.Number, []float64:
+		mapping.Type = FieldTypeFloat
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		mapping.Type = FieldTypeInteger
+	case time.Time, *time.Time:
+		mapping.Type = FieldTypeDate
+	default:
+		return
+	}
+	mapping.Inferred = true
+	ok = true
+	return
+}
+
+// invalidFieldValue returns the error of a field value that doesn't match the mapping of the field.
+// It is only built once a value is invalid since formatting the value is costly.
+func invalidFieldValue(field string, v interface{}) error {
+	return fmt.Errorf("%w: %s: %v", ErrInvalidFieldValue, field, v)
+}
+
+// values validates a field value, which may be an array of values, and returns the texts of a text
+// field or the terms of an exact field.
+func (mapping *FieldMapping) values(field string, v interface{}) (values []string, err error) {
+	switch mapping.Type {
+	case FieldTypeStored:
+		return
+	case FieldTypeNested:
+		if !isObjects(v) {
+			err = invalidFieldValue(field, v)
+		}
+		return
+	case FieldTypeVector:
+		if v == nil {
+			return
+		}
+		vector, ok := vectorValue(v)
+		if !ok || (mapping.Dimensions > 0 && len(vector) != mapping.Dimensions) {
+			err = invalidFieldValue(field, v)
+		}
+		return
+	case FieldTypeGeoPoint:
+		// A single point may be an array itself
+		if _, _, ok := geoPointValue(v); ok {
+			return
+		}
+	}
+
+	switch value := v.(type) {
+	case nil:
+	case []interface{}:
+		for _, v := range value {
+			var vs []string
+			vs, err = mapping.values(field, v)
+			if err != nil {
+				return
+			}
+			values = append(values, vs...)
+		}
+	case []map[string]interface{}:
+		for _, v := range value {
+			var vs []string
+			vs, err = mapping.values(field, v)
+			if err != nil {
+				return
+			}
+			values = append(values, vs...)
+		}
+	case []string:
+		for _, v := range value {
+			var vs []string
+			vs, err = mapping.values(field, v)
+			if err != nil {
+				return
+			}
+			values = append(values, vs...)
+		}
+	case []float64:
+		for _, v := range value {
+			var vs []string
+			vs, err = mapping.values(field, v)
+			if err != nil {
+				return
+			}
+			values = append(values, vs...)
+		}
+	default:
+		s, ok := mapping.value(v)
+		if !ok {
+			err = invalidFieldValue(field, v)
+			return
+		}
+		if mapping.Type == FieldTypeText || mapping.Type.exact() {
+			values = append(values, s)
+		}
+	}
+	return
+}
+
+// matches returns whether a field value is valid for the mapping.
+func (mapping *FieldMapping) matches(field string, v interface{}) bool {
+	_, err := mapping.values(field, v)
+	return err == nil
+}
+
+// isObjects returns whether a value is nothing, an object, or an array of objects.
+func isObjects(v interface{}) bool {
+	switch value := v.(type) {
+	case nil, map[string]interface{}, []map[string]interface{}:
+		return true
+	case []interface{}:
+		for _, v := range value {
+			if _, ok := v.(map[string]interface{}); !ok {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// value validates a single value and returns its text or its term.
+func (mapping *FieldMapping) value(v interface{}) (s string, ok bool) {
+	switch mapping.Type {
+	case FieldTypeText:
+		switch value := v.(type) {
+		case string:
+			s, ok = value, true
+		case *string:
+			if value != nil {
+				s, ok = *value, true
+			}
+		}
+	case FieldTypeKeyword:
+		switch value := v.(type) {
+		case string:
+			s, ok = value, true
+		case *string:
+			if value != nil {
+				s, ok = *value, true
+			}
+		case bool:
+			s, ok = strconv.FormatBool(value), true
+		default:
+			var f float64
+			if f, ok = numberValue(v); ok {
+				s = strconv.FormatFloat(f, 'g', -1, 64)
+			}
+		}
+		if ok {
+			s = mapping.normalize(s)
+		}
+	case FieldTypeInteger:
+		var i int64
+		if i, ok = integerValue(v); ok {
+			s = strconv.FormatInt(i, 10)
+		}
+	case FieldTypeFloat:
+		var f float64
+		if f, ok = numberValue(v); ok {
+			s = strconv.FormatFloat(f, 'g', -1, 64)
+		}
+	case FieldTypeBoolean:
+		var b bool
+		if b, ok = booleanValue(v); ok {
+			s = strconv.FormatBool(b)
+		}
+	case FieldTypeDate:
+		var t time.Time
+		if t, ok = dateValue(v); ok {
+			s = t.UTC().Format(time.RFC3339Nano)
+		}
+	case FieldTypeGeoPoint:
+		_, _, ok = geoPointValue(v)
+	}
+	return
+}
+
+// numberValue converts a number or a string of a number into a float.
+func numberValue(v interface{}) (f float64, ok bool) {
+	var err error
+
+	switch value := v.(type) {
+	case json.Number:
+		f, err = value.Float64()
+		ok = err == nil
+	case string:
+		f, err = strconv.ParseFloat(strings.TrimSpace(value), 64)
+		ok = err == nil
+	default:
+		rv := reflect.ValueOf(v)
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			f, ok = float64(rv.Int()), true
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			f, ok = float64(rv.Uint()), true
+		case reflect.Float32, reflect.Float64:
+			f, ok = rv.Float(), true
+		}
+	}
+
+	if ok && (math.IsNaN(f) || math.IsInf(f, 0)) {
+		ok = false
+	}
+	return
+}
+
+// integerValue converts a whole number or a string of a whole number into an integer.
+func integerValue(v interface{}) (i int64, ok bool) {
+	var err error
+
+	switch value := v.(type) {
+	case json.Number:
+		i, err = value.Int64()
+		ok = err == nil
+	case string:
+		i, err = strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		ok = err == nil
+	default:
+		rv := reflect.ValueOf(v)
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			i, ok = rv.Int(), true
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if rv.Uint() <= math.MaxInt64 {
+				i, ok = int64(rv.Uint()), true
+			}
+		case reflect.Float32, reflect.Float64:
+			f := rv.Float()
+			if f == math.Trunc(f) && math.Abs(f) <= 1<<53 {
+				i, ok = int64(f), true
+			}
+		}
+	}
+	return
+}
+
+// booleanValue converts a boolean or a "true" or "false" string into a boolean.
+func booleanValue(v interface{}) (b bool, ok bool) {
+	switch value := v.(type) {
+	case bool:
+		b, ok = value, true
+	case string:
+		switch value {
+		case "true":
+			b, ok = true, true
+		case "false":
+			b, ok = false, true
+		}
+	}
+	return
+}
+
+// dateFormats are the formats that date strings are parsed with.
+var dateFormats = []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999", "2006-01-02"}
+
+// dateValue converts a time or a date string into a time.
+func dateValue(v interface{}) (t time.Time, ok bool) {
+	switch value := v.(type) {
+	case time.Time:
+		t, ok = value, true
+	case *time.Time:
+		if value != nil {
+			t, ok = *value, true
+		}
+	case string:
+		for _, format := range dateFormats {
+			var err error
+			t, err = time.Parse(format, strings.TrimSpace(value))
+			if err == nil {
+				ok = true
+				break
+			}
+		}
+	}
+	return
+}
+
+// geoPointValue converts an object with lat and lon fields, a [lon, lat] array, or a "lat,lon"
+// string into a latitude and a longitude.
+func geoPointValue(v interface{}) (lat, lon float64, ok bool) {
+	var latOK, lonOK bool
+
+	switch value := v.(type) {
+	case map[string]interface{}:
+		if len(value) != 2 {
+			return
+		}
+		lat, latOK = numberValue(value["lat"])
+		lon, lonOK = numberValue(value["lon"])
+	case string:
+		parts := strings.Split(value, ",")
+		if len(parts) != 2 {
+			return
+		}
+		lat, latOK = numberValue(parts[0])
+		lon, lonOK = numberValue(parts[1])
+	default:
+		vector, vectorOK := vectorValue(v)
+		if !vectorOK || len(vector) != 2 {
+			return
+		}
+		lon, lat, latOK, lonOK = vector[0], vector[1], true, true
+	}
+
+	ok = latOK && lonOK && lat >= -90 && lat <= 90 && lon >= -180 && lon <= 180
+	return
+}
+
+// vectorValue converts an array of numbers into a vector.
+func vectorValue(v interface{}) (vector []float64, ok bool) {
+	switch value := v.(type) {
+	case []float64:
+		return value, true
+	case []float32:
+		for _, f := range value {
+			vector = append(vector, float64(f))
+		}
+		return vector, true
+	case []interface{}:
+		for _, v := range value {
+			if _, isString := v.(string); isString {
+				return nil, false
+			}
+			f, numberOK := numberValue(v)
+			if !numberOK {
+				return nil, false
+			}
+			vector = append(vector, f)
+		}
+		return vector, true
+	}
+	return
+}
+
+// exactTerm returns the term of a value of an exact field. Exact terms are prefixed with their field
+// and a NUL character, which analyzers never produce, so that full-text queries cannot match them.
+func exactTerm(field, value string) string {
+	return field + "\x00" + value
+}
+
+// storedDocument returns a copy of a document without the fields that are not stored, or the
+// document itself if every field is stored.
+func (index *Index) storedDocument(document map[string]interface{}) map[string]interface{} {
+	unstored := []string{}
+	for field, mapping := range index.Mapping.Fields {
+		if mapping.NotStored {
+			unstored = append(unstored, field)
+		}
+	}
+	if len(unstored) == 0 {
+		return document
+	}
+	return withoutFields("", document, unstored).(map[string]interface{})
+}
+
+// withoutFields copies a value without the given field paths.
+func withoutFields(parentField string, v interface{}, fields []string) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(value))
+		for name, v := range value {
+			field := name
+			if parentField != "" {
+				field = parentField + "." + name
+			}
+			if contains(fields, field) {
+				continue
+			}
+			copied[name] = withoutFields(field, v, fields)
+		}
+		return copied
+	case []map[string]interface{}:
+		copied := make([]map[string]interface{}, len(value))
+		for i, v := range value {
+			copied[i] = withoutFields(parentField, v, fields).(map[string]interface{})
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(value))
+		for i, v := range value {
+			copied[i] = withoutFields(parentField, v, fields)
+		}
+		return copied
+	}
+	return v
+}
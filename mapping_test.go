@@ -0,0 +1,240 @@
+package folder
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mappingIndex(t *testing.T, dynamic DynamicMapping) *Index {
+	index := New()
+	err := index.SetMapping(Mapping{
+		Fields: map[string]FieldMapping{
+			"title":       {Type: FieldTypeText, Boost: 2},
+			"body":        {Type: FieldTypeText, Analyzer: BasicAnalyzer},
+			"status":      {Type: FieldTypeKeyword, Normalizers: []string{"lowercase"}},
+			"views":       {Type: FieldTypeInteger},
+			"rating":      {Type: FieldTypeFloat},
+			"published":   {Type: FieldTypeBoolean},
+			"created_at":  {Type: FieldTypeDate},
+			"location":    {Type: FieldTypeGeoPoint},
+			"embedding":   {Type: FieldTypeVector, Dimensions: 3},
+			"raw":         {Type: FieldTypeStored},
+			"secret":      {Type: FieldTypeText, NotStored: true},
+			"author.name": {Type: FieldTypeText},
+		},
+		Dynamic: dynamic,
+	})
+	assert.Nil(t, err)
+	return index
+}
+
+func TestMappingValidation(t *testing.T) {
+	index := mappingIndex(t, DynamicStrict)
+
+	var document map[string]interface{}
+	document = map[string]interface{}{
+		"title":      "Folder",
+		"status":     "PUBLISHED-2",
+		"views":      42.0,
+		"rating":     "4.5",
+		"published":  true,
+		"created_at": "2021-02-12",
+		"location":   map[string]interface{}{"lat": -6.2, "lon": 106.8},
+		"embedding":  []interface{}{0.1, 0.2, 0.3},
+		"raw":        map[string]interface{}{"anything": []interface{}{1.0, "goes"}},
+		"author":     []interface{}{map[string]interface{}{"name": "Lilis Iskandar"}},
+	}
+	_, err := index.IndexWithID(document, "1")
+	assert.Nil(t, err)
+
+	for field, value := range map[string]interface{}{
+		"views":      4.5,
+		"rating":     "many",
+		"published":  "yes",
+		"created_at": "yesterday",
+		"location":   []interface{}{200.0, 0.0},
+		"embedding":  []interface{}{0.1, 0.2},
+		"title":      map[string]interface{}{"en": "Folder"},
+	} {
+		_, err = index.IndexWithID(map[string]interface{}{field: value}, "2")
+		assert.True(t, errors.Is(err, ErrInvalidFieldValue), field)
+	}
+
+	_, err = index.IndexWithID(map[string]interface{}{"title": "Folder", "tags": "search"}, "2")
+	assert.True(t, errors.Is(err, ErrUnknownField))
+
+	// Invalid documents are not indexed at all
+	document, _ = index.Fetch("2")
+	assert.Nil(t, document)
+
+	err = index.SetFieldMapping("size", FieldMapping{Type: "huge"})
+	assert.True(t, errors.Is(err, ErrUnknownFieldType))
+	err = index.SetFieldMapping("size", FieldMapping{Type: FieldTypeInteger, Analyzer: StandardAnalyzer})
+	assert.True(t, errors.Is(err, ErrInvalidParameter))
+	err = index.SetFieldMapping("size", FieldMapping{Type: FieldTypeText, Analyzer: "klingon"})
+	assert.True(t, errors.Is(err, ErrUnknownAnalyzer))
+}
+
+func TestMappingIndexing(t *testing.T) {
+	index := mappingIndex(t, DynamicStrict)
+	assert.Equal(t, BasicAnalyzer, index.Analysis.FieldAnalyzers["body"])
+
+	_, err := index.IndexWithID(map[string]interface{}{"title": "Search engine", "body": "A library", "status": "search"}, "1")
+	assert.Nil(t, err)
+	_, err = index.IndexWithID(map[string]interface{}{"title": "A library", "body": "Search engine", "secret": "hidden treasure"}, "2")
+	assert.Nil(t, err)
+
+	// Exact values cannot be found by full-text queries
+	res, _ := index.Search("search")
+	assert.Equal(t, 2, res.Count)
+	assert.Equal(t, 1, index.documentFrequency(exactTerm("status", "search")))
+
+	// The title is boosted
+	assert.Equal(t, "1", res.Hits[0].ID)
+
+	// Fields that are not stored can still be searched
+	res, _ = index.Search("treasure")
+	assert.Equal(t, 1, res.Count)
+	assert.Equal(t, map[string]interface{}{"title": "A library", "body": "Search engine"}, res.Hits[0].Source)
+
+	_, err = index.IndexWithID(map[string]interface{}{"title": "A library"}, "2")
+	assert.Nil(t, err)
+	res, _ = index.Search("treasure")
+	assert.Equal(t, 0, res.Count)
+}
+
+func TestDynamicMapping(t *testing.T) {
+	index := New()
+	now := time.Now()
+	_, err := index.IndexWithID(map[string]interface{}{
+		"title":     "Folder",
+		"views":     42,
+		"rating":    4.5,
+		"published": true,
+		"createdAt": now,
+		"embedding": []interface{}{0.1, 0.2, 0.3},
+		"author":    map[string]interface{}{"hobbies": []interface{}{"drawing", "gaming"}},
+	}, "1")
+	assert.Nil(t, err)
+
+	types := map[string]FieldType{}
+	for field, mapping := range index.Mapping.Fields {
+		types[field] = mapping.Type
+	}
+	assert.Equal(t, map[string]FieldType{
+		"title":          FieldTypeText,
+		"views":          FieldTypeInteger,
+		"rating":         FieldTypeFloat,
+		"published":      FieldTypeBoolean,
+		"createdAt":      FieldTypeDate,
+		"embedding":      FieldTypeFloat,
+		"author.hobbies": FieldTypeText,
+	}, types)
+
+	// Later values of other types are indexed as if their field weren't mapped
+	_, err = index.IndexWithID(map[string]interface{}{"views": "many", "embedding": []interface{}{"a", "b"}}, "2")
+	assert.Nil(t, err)
+	assert.Equal(t, FieldTypeInteger, index.Mapping.Fields["views"].Type)
+	res, _ := index.Search("many")
+	assert.Equal(t, 1, res.Count)
+	res, _ = index.SearchTerm("views", "42", DefaultSearchOptions)
+	assert.Equal(t, 1, res.Count)
+
+	// Mappings that are set rather than guessed still reject other types
+	err = index.SetFieldMapping("views", FieldMapping{Type: FieldTypeInteger})
+	assert.Nil(t, err)
+	_, err = index.IndexWithID(map[string]interface{}{"views": "many"}, "3")
+	assert.True(t, errors.Is(err, ErrInvalidFieldValue))
+
+	index = mappingIndex(t, DynamicIgnore)
+	_, err = index.IndexWithID(map[string]interface{}{"title": "Folder", "tags": "search"}, "1")
+	assert.Nil(t, err)
+	_, ok := index.Mapping.Fields["tags"]
+	assert.False(t, ok)
+
+	res, _ = index.Search("search")
+	assert.Equal(t, 0, res.Count)
+	document, _ := index.Fetch("1")
+	assert.Equal(t, "search", document["tags"])
+
+	err = index.SetMapping(Mapping{Dynamic: "sometimes"})
+	assert.True(t, errors.Is(err, ErrInvalidParameter))
+}
+
+func TestSaveAndLoadMapping(t *testing.T) {
+	dir, err := os.MkdirTemp("", "folder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	index := mappingIndex(t, DynamicIgnore)
+	_, err = index.IndexWithID(map[string]interface{}{"title": "Search engine", "status": "draft"}, "1")
+	assert.Nil(t, err)
+
+	err = index.Save(dir + "/index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	loadedIndex, err := Load(dir + "/index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, index.Mapping, loadedIndex.Mapping)
+
+	err = index.SaveToShards(dir+"/index", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loadedIndex, err = LoadDeferred(dir + "/index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, index.Mapping, loadedIndex.Mapping)
+
+	res, _ := loadedIndex.Search("search")
+	assert.Equal(t, 1, res.Count)
+	assert.Equal(t, 1, loadedIndex.documentFrequency(exactTerm("status", "draft")))
+}
+
+func TestDeleteUnstoredFields(t *testing.T) {
+	dir, err := os.MkdirTemp("", "folder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	index := mappingIndex(t, DynamicStrict)
+	_, err = index.IndexWithID(map[string]interface{}{"title": "Search engine", "secret": "hidden treasure"}, "1")
+	assert.Nil(t, err)
+	_, err = index.IndexWithID(map[string]interface{}{"title": "A library", "secret": "buried treasure"}, "2")
+	assert.Nil(t, err)
+
+	err = index.SaveToShards(dir+"/index", 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loadedIndex, err := LoadDeferred(dir + "/index")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The terms of the values that are not stored are removed without loading every term
+	err = loadedIndex.Delete("2")
+	assert.Nil(t, err)
+	assert.Less(t, len(loadedIndex.LoadedTermStatsShards), 64)
+
+	res, _ := loadedIndex.Search("buried")
+	assert.Equal(t, 0, res.Count)
+	res, _ = loadedIndex.Search("treasure")
+	assert.Equal(t, 1, res.Count)
+
+	_, err = loadedIndex.IndexWithID(map[string]interface{}{"title": "Search engine"}, "1")
+	assert.Nil(t, err)
+	res, _ = loadedIndex.Search("hidden")
+	assert.Equal(t, 0, res.Count)
+}
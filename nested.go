@@ -0,0 +1,334 @@
+package folder
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NestedQuery finds the documents that have at least one object in a nested field whose fields match
+// every condition, unlike regular queries that may match the fields of different objects.
+type NestedQuery struct {
+	Path string // Path of the nested field such as "author"
+
+	// Match contains the conditions by field path within the nested field, such as "author.name".
+	// Conditions on text fields are full-text queries whose terms must all be found, and conditions
+	// on exact fields are values that must be found verbatim like in term queries.
+	Match map[string]string
+}
+
+// InnerHit is an object of a nested field that matched a nested query.
+type InnerHit struct {
+	Path   string
+	Offset int // Position of the object among the objects of the nested field
+	Score  float64
+	Source map[string]interface{}
+}
+
+// SearchNested searches the objects of a nested field and returns the documents that contain
+// matching objects, scored by their best matching object. Each hit contains the matching objects as
+// its inner hits.
+func (index *Index) SearchNested(q NestedQuery, opts SearchOptions) (res SearchResult, err error) {
+	return index.SearchNestedContext(context.Background(), q, opts)
+}
+
+// SearchNestedContext searches a nested field just like SearchNested but stops loading shards and
+// scoring documents once the context is done, in which case the result is flagged as timed out.
+func (index *Index) SearchNestedContext(ctx context.Context, q NestedQuery, opts SearchOptions) (res SearchResult, err error) {
+	if !opts.UseCache {
+		debug("Search nested", q.Path, q.Match, "(not cached)")
+		return index.uncached().searchNested(ctx, q, opts)
+	}
+	debug("Search nested", q.Path, q.Match, "(cached)")
+	return index.searchNested(ctx, q, opts)
+}
+
+func (index *Index) searchNested(ctx context.Context, q NestedQuery, opts SearchOptions) (res SearchResult, err error) {
+	var groups [][]string
+	var objectIDs []string
+
+	startTime := time.Now()
+	defer func() {
+		res.Time.Total = time.Since(startTime)
+	}()
+
+	groups, err = index.nestedQueryGroups(q)
+	if err != nil {
+		return
+	}
+
+	objectIDs, err = index.findNestedObjects(ctx, groups)
+	if err != nil {
+		res.TimedOut, err = timedOut(ctx, err)
+		return
+	}
+	res.Time.Match = time.Since(startTime)
+
+	// Documents are scored by their best matching object
+	sortStartTime := time.Now()
+	innerHits := make(map[string][]InnerHit)
+	for _, objectID := range objectIDs {
+		var score float64
+
+		score, err = index.calculateNestedScore(ctx, objectID, groups)
+		if err != nil {
+			res.TimedOut, err = timedOut(ctx, err)
+			if err != nil {
+				return
+			}
+			break
+		}
+
+		documentID, offset := parseNestedObjectID(objectID)
+		innerHits[documentID] = append(innerHits[documentID], InnerHit{Path: q.Path, Offset: offset, Score: score})
+	}
+
+	documentIDs := []string{}
+	for documentID := range innerHits {
+		documentIDs = append(documentIDs, documentID)
+	}
+	sort.Strings(documentIDs)
+
+	idScores := IDScores{}
+	for _, documentID := range documentIDs {
+		hits := innerHits[documentID]
+		sort.Slice(hits, func(i, j int) bool {
+			if hits[i].Score != hits[j].Score {
+				return hits[i].Score > hits[j].Score
+			}
+			return hits[i].Offset < hits[j].Offset
+		})
+		idScores.IDs = append(idScores.IDs, documentID)
+		idScores.Scores = append(idScores.Scores, hits[0].Score)
+	}
+	sort.Sort(sort.Reverse(idScores))
+	res.Time.Sort = time.Since(sortStartTime)
+
+	sortedDocumentIDs, scores, facets, err := index.arrangeDocuments(ctx, idScores.IDs, idScores.Scores, opts)
+	if err != nil {
+		res.TimedOut, err = timedOut(ctx, err)
+		return
+	}
+	res.Count = len(sortedDocumentIDs)
+	res.Facets = facets
+
+	res.Hits, err = index.fetchHits(ctx, sortedDocumentIDs, scores, opts.Size, opts.From)
+	if err != nil {
+		res.TimedOut, err = timedOut(ctx, err)
+		return
+	}
+
+	fields := strings.Split(q.Path, ".")
+	for i, hit := range res.Hits {
+		objects := nestedObjects(hit.Source, fields)
+		for _, innerHit := range innerHits[hit.ID] {
+			if innerHit.Offset < len(objects) {
+				innerHit.Source = objects[innerHit.Offset]
+			}
+			res.Hits[i].InnerHits = append(res.Hits[i].InnerHits, innerHit)
+		}
+	}
+	return
+}
+
+// nestedQueryGroups returns the groups of nested terms that the objects matching a nested query must
+// contain at least one term of.
+func (index *Index) nestedQueryGroups(q NestedQuery) (groups [][]string, err error) {
+	mapping, ok := index.Mapping.Fields[q.Path]
+	if !ok || mapping.Type != FieldTypeNested {
+		err = fmt.Errorf("%w: %s is not a nested field", ErrInvalidQuery, q.Path)
+		return
+	}
+
+	fields := []string{}
+	for field := range q.Match {
+		if !strings.HasPrefix(field, q.Path+".") {
+			err = fmt.Errorf("%w: %s is not a field of %s", ErrInvalidQuery, field, q.Path)
+			return
+		}
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		if mapping, ok := index.Mapping.Fields[field]; ok && mapping.Type.exact() {
+			var terms []string
+
+			terms, err = index.exactTerms(field, []string{q.Match[field]})
+			if err != nil {
+				return
+			}
+			groups = append(groups, []string{nestedTerm(terms[0])})
+			continue
+		}
+
+		for _, group := range index.analyzeGroupsWith(index.Analysis.fieldSearchAnalyzer(field), q.Match[field]) {
+			terms := make([]string, len(group))
+			for i, term := range group {
+				terms[i] = nestedTerm(exactTerm(field, term))
+			}
+			groups = append(groups, terms)
+		}
+	}
+	return
+}
+
+// findNestedObjects finds the IDs of the nested objects which contain at least one term of every
+// group of terms.
+func (index *Index) findNestedObjects(ctx context.Context, groups [][]string) (objectIDs []string, err error) {
+	var objectIDsSet StringSet
+
+	debug("  Find nested objects with term groups", groups)
+
+	for i, group := range groups {
+		ids := MakeStringSet([]string{})
+		for _, term := range group {
+			var termStat TermStat
+
+			err = ctx.Err()
+			if err != nil {
+				return
+			}
+
+			termStat, _, err = index.fetchTermStat(ctx, term)
+			if err != nil {
+				return
+			}
+			for id := range termStat.TermFrequencies {
+				ids.Add(id)
+			}
+		}
+
+		if i == 0 {
+			objectIDsSet = ids
+		} else {
+			objectIDsSet.Intersects(ids)
+		}
+		if objectIDsSet.Len() == 0 {
+			break
+		}
+	}
+
+	objectIDs = objectIDsSet.List()
+	return
+}
+
+// calculateNestedScore scores a nested object by the frequencies of the terms of a nested query in
+// the object and by how rare the terms are among the documents.
+func (index *Index) calculateNestedScore(ctx context.Context, objectID string, groups [][]string) (score float64, err error) {
+	for _, group := range groups {
+		for _, term := range group {
+			var termStat TermStat
+
+			termStat, _, err = index.fetchTermStat(ctx, term)
+			if err != nil {
+				return
+			}
+
+			frequency := termStat.TermFrequencies[objectID]
+			if frequency == 0 {
+				continue
+			}
+
+			documentIDs := MakeStringSet([]string{})
+			for id := range termStat.TermFrequencies {
+				documentID, _ := parseNestedObjectID(id)
+				documentIDs.Add(documentID)
+			}
+			score += float64(frequency) * math.Log10(float64(len(index.Documents))/float64(documentIDs.Len()))
+		}
+	}
+	return
+}
+
+// analyzeNested analyzes each object of the nested fields of a document into the nested terms of
+// the object, by the ID of the object.
+func (index *Index) analyzeNested(documentID string, document map[string]interface{}, languages map[string]string) (objectTerms map[string][]string) {
+	return index.analyzeNestedFields(documentID, document, languages, nil)
+}
+
+// analyzeNestedFields analyzes the nested objects of a document like analyzeNested but only keeps
+// the terms of the fields that keep returns true for, or every term if keep is nil.
+func (index *Index) analyzeNestedFields(documentID string, document map[string]interface{}, languages map[string]string, keep func(field string) bool) (objectTerms map[string][]string) {
+	paths := []string{}
+	for field, mapping := range index.Mapping.Fields {
+		if mapping.Type == FieldTypeNested {
+			paths = append(paths, field)
+		}
+	}
+	if len(paths) == 0 {
+		return
+	}
+	sort.Strings(paths)
+
+	objectTerms = make(map[string][]string)
+	for _, path := range paths {
+		for offset, object := range nestedObjects(document, strings.Split(path, ".")) {
+			m := make(map[string][]string)
+			index.analyze(path, object, languages, m)
+
+			objectID := nestedObjectID(documentID, offset)
+			for field, tokens := range m {
+				if keep != nil && !keep(field) {
+					continue
+				}
+				exact := index.Mapping.Fields[field].Type.exact()
+				for _, token := range tokens {
+					if !exact {
+						token = exactTerm(field, token)
+					}
+					objectTerms[objectID] = append(objectTerms[objectID], nestedTerm(token))
+				}
+			}
+		}
+	}
+	return
+}
+
+// nestedObjects returns the objects of a nested field, given as its path split into fields, in the
+// order that their offsets refer to.
+func nestedObjects(v interface{}, fields []string) (objects []map[string]interface{}) {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		if len(fields) == 0 {
+			return []map[string]interface{}{value}
+		}
+		return nestedObjects(value[fields[0]], fields[1:])
+	case []map[string]interface{}:
+		for _, v := range value {
+			objects = append(objects, nestedObjects(v, fields)...)
+		}
+	case []interface{}:
+		for _, v := range value {
+			objects = append(objects, nestedObjects(v, fields)...)
+		}
+	}
+	return
+}
+
+// nestedTerm returns the term of a field-scoped term within a nested object. Nested terms start with
+// a NUL character so that they are neither matched by regular queries nor mistaken for exact terms.
+func nestedTerm(term string) string {
+	return "\x00" + term
+}
+
+// nestedObjectID returns the ID under which a nested object is indexed as a hidden sub-document.
+// Objects of different nested fields may share IDs since their terms are scoped by field.
+func nestedObjectID(documentID string, offset int) string {
+	return documentID + "\x00" + strconv.Itoa(offset)
+}
+
+// parseNestedObjectID returns the ID of the document of a nested object and the offset of the object.
+func parseNestedObjectID(objectID string) (documentID string, offset int) {
+	i := strings.LastIndex(objectID, "\x00")
+	if i < 0 {
+		return objectID, 0
+	}
+	offset, _ = strconv.Atoi(objectID[i+1:])
+	documentID = objectID[:i]
+	return
+}
@@ -0,0 +1,128 @@
+package folder
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func nestedIndex(t *testing.T) *Index {
+	index := New()
+	err := index.SetMapping(Mapping{
+		Fields: map[string]FieldMapping{
+			"title":        {Type: FieldTypeText},
+			"author":       {Type: FieldTypeNested},
+			"author.name":  {Type: FieldTypeText},
+			"author.hobby": {Type: FieldTypeKeyword},
+		},
+	})
+	assert.Nil(t, err)
+
+	_, err = index.IndexWithID(map[string]interface{}{
+		"title": "Folder",
+		"author": []interface{}{
+			map[string]interface{}{"name": "Lilis Iskandar", "hobby": "drawing"},
+			map[string]interface{}{"name": "Chae-Young Song", "hobby": "gaming"},
+		},
+	}, "1")
+	assert.Nil(t, err)
+	_, err = index.IndexWithID(map[string]interface{}{
+		"title":  "Search engines",
+		"author": map[string]interface{}{"name": "Lilis Song", "hobby": "gaming"},
+	}, "2")
+	assert.Nil(t, err)
+	return index
+}
+
+func TestSearchNested(t *testing.T) {
+	index := nestedIndex(t)
+
+	// Regular queries match the fields of different objects
+	res, _ := index.SearchTerm("author.hobby", "gaming", DefaultSearchOptions)
+	assert.Equal(t, 2, res.Count)
+	res, _ = index.Search("lilis")
+	assert.Equal(t, 2, res.Count)
+
+	res, err := index.SearchNested(NestedQuery{
+		Path:  "author",
+		Match: map[string]string{"author.name": "lilis", "author.hobby": "gaming"},
+	}, DefaultSearchOptions)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, res.Count)
+	assert.Equal(t, "2", res.Hits[0].ID)
+	assert.Equal(t, 1, len(res.Hits[0].InnerHits))
+	assert.Equal(t, 0, res.Hits[0].InnerHits[0].Offset)
+
+	res, _ = index.SearchNested(NestedQuery{
+		Path:  "author",
+		Match: map[string]string{"author.name": "Chae-Young Song", "author.hobby": "gaming"},
+	}, DefaultSearchOptions)
+	assert.Equal(t, 1, res.Count)
+	assert.Equal(t, "1", res.Hits[0].ID)
+	assert.Equal(t, "author", res.Hits[0].InnerHits[0].Path)
+	assert.Equal(t, 1, res.Hits[0].InnerHits[0].Offset)
+	assert.Equal(t, map[string]interface{}{"name": "Chae-Young Song", "hobby": "gaming"}, res.Hits[0].InnerHits[0].Source)
+
+	res, _ = index.SearchNested(NestedQuery{
+		Path:  "author",
+		Match: map[string]string{"author.name": "lilis", "author.hobby": "drawing"},
+	}, DefaultSearchOptions)
+	assert.Equal(t, 1, res.Count)
+	assert.Equal(t, "1", res.Hits[0].ID)
+
+	res, _ = index.SearchNested(NestedQuery{
+		Path:  "author",
+		Match: map[string]string{"author.name": "song"},
+	}, DefaultSearchOptions)
+	assert.Equal(t, 2, res.Count)
+
+	_, err = index.SearchNested(NestedQuery{Path: "title", Match: map[string]string{"title.name": "a"}}, DefaultSearchOptions)
+	assert.True(t, errors.Is(err, ErrInvalidQuery))
+	_, err = index.SearchNested(NestedQuery{Path: "author", Match: map[string]string{"title": "folder"}}, DefaultSearchOptions)
+	assert.True(t, errors.Is(err, ErrInvalidQuery))
+
+	_, err = index.IndexWithID(map[string]interface{}{"author": "Lilis"}, "3")
+	assert.True(t, errors.Is(err, ErrInvalidFieldValue))
+}
+
+func TestUpdateNested(t *testing.T) {
+	dir, err := os.MkdirTemp("", "folder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	index := nestedIndex(t)
+	q := NestedQuery{Path: "author", Match: map[string]string{"author.name": "lilis", "author.hobby": "drawing"}}
+
+	_, err = index.IndexWithID(map[string]interface{}{
+		"title":  "Folder",
+		"author": []interface{}{map[string]interface{}{"name": "Lilis Iskandar", "hobby": "cooking"}},
+	}, "1")
+	assert.Nil(t, err)
+	res, _ := index.SearchNested(q, DefaultSearchOptions)
+	assert.Equal(t, 0, res.Count)
+
+	q.Match["author.hobby"] = "cooking"
+	res, _ = index.SearchNested(q, DefaultSearchOptions)
+	assert.Equal(t, 1, res.Count)
+
+	err = index.SaveToShards(dir+"/index", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loadedIndex, err := LoadDeferred(dir + "/index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, _ = loadedIndex.SearchNested(q, DefaultSearchOptions)
+	assert.Equal(t, 1, res.Count)
+	assert.Equal(t, "Lilis Iskandar", res.Hits[0].InnerHits[0].Source["name"])
+
+	err = index.Delete("1")
+	assert.Nil(t, err)
+	res, _ = index.SearchNested(q, DefaultSearchOptions)
+	assert.Equal(t, 0, res.Count)
+}
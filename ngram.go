@@ -0,0 +1,148 @@
+package folder
+
+import (
+	"fmt"
+	"strconv"
+)
+
+const (
+	// DefaultMinGram is the minimum gram size of n-gram tokenizers and filters without parameters.
+	DefaultMinGram = 1
+	// DefaultMaxGram is the maximum gram size of n-gram tokenizers and filters without parameters.
+	DefaultMaxGram = 2
+
+	// PreserveOriginal is the n-gram parameter that keeps the original tokens along with their grams
+	// so that words longer than the maximum gram size can still be found as a whole.
+	PreserveOriginal = "preserve_original"
+)
+
+// NGramTokenFilter replaces tokens by their grams of MinGram to MaxGram characters, or only by the
+// grams at the start of the tokens if Edge is set. The grams keep the position of their token.
+//
+// It is meant to be used at index time only, on fields whose search analyzer is an ordinary one, so
+// that the words being typed by a user match documents before they are complete.
+type NGramTokenFilter struct {
+	MinGram          int
+	MaxGram          int
+	Edge             bool
+	PreserveOriginal bool
+}
+
+// parseNGramParams parses n-gram parameters in the form [min gram],[max gram],[preserve_original].
+func parseNGramParams(params []string, edge bool) (filter *NGramTokenFilter, err error) {
+	filter = &NGramTokenFilter{MinGram: DefaultMinGram, MaxGram: DefaultMaxGram, Edge: edge}
+
+	for i, param := range params {
+		var parseErr error
+		switch {
+		case i == 0:
+			filter.MinGram, parseErr = strconv.Atoi(param)
+		case i == 1:
+			filter.MaxGram, parseErr = strconv.Atoi(param)
+		case i == 2 && param == PreserveOriginal:
+			filter.PreserveOriginal = true
+		default:
+			parseErr = ErrInvalidParameter
+		}
+		if parseErr != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidParameter, param)
+		}
+	}
+
+	if filter.MinGram < 1 || filter.MaxGram < filter.MinGram {
+		return nil, fmt.Errorf("%w: gram sizes %d and %d", ErrInvalidParameter, filter.MinGram, filter.MaxGram)
+	}
+	return
+}
+
+// newNGramTokenFilter creates an n-gram filter from its parameters.
+func newNGramTokenFilter(params []string) (TokenFilter, error) {
+	filter, err := parseNGramParams(params, false)
+	if err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+// newEdgeNGramTokenFilter creates an edge n-gram filter from its parameters.
+func newEdgeNGramTokenFilter(params []string) (TokenFilter, error) {
+	filter, err := parseNGramParams(params, true)
+	if err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+// NewNGramTokenFilter creates a filter that produces every gram of minGram to maxGram characters.
+func NewNGramTokenFilter(minGram, maxGram int) *NGramTokenFilter {
+	return &NGramTokenFilter{MinGram: minGram, MaxGram: maxGram}
+}
+
+// NewEdgeNGramTokenFilter creates a filter that produces the prefixes of minGram to maxGram
+// characters.
+func NewEdgeNGramTokenFilter(minGram, maxGram int) *NGramTokenFilter {
+	return &NGramTokenFilter{MinGram: minGram, MaxGram: maxGram, Edge: true}
+}
+
+// Filter replaces the tokens by their grams.
+func (filter *NGramTokenFilter) Filter(tokens []Token) (filteredTokens []Token) {
+	for _, token := range tokens {
+		// Byte offsets of the characters of the term along with the end of the term
+		var offsets []int
+		for i := range token.Term {
+			offsets = append(offsets, i)
+		}
+		offsets = append(offsets, len(token.Term))
+		length := len(offsets) - 1
+
+		if filter.PreserveOriginal && (length < filter.MinGram || length > filter.MaxGram) {
+			filteredTokens = append(filteredTokens, token)
+		}
+
+		starts := length
+		if filter.Edge && starts > 0 {
+			starts = 1
+		}
+		for start := 0; start < starts; start++ {
+			for size := filter.MinGram; size <= filter.MaxGram && start+size <= length; size++ {
+				gram := token
+				gram.Term = token.Term[offsets[start]:offsets[start+size]]
+				gram.Start = token.Start + offsets[start]
+				gram.End = token.Start + offsets[start+size]
+				filteredTokens = append(filteredTokens, gram)
+			}
+		}
+	}
+	return
+}
+
+// NGramTokenizer splits text into words like UnicodeTokenizer and then replaces the words by their
+// grams like NGramTokenFilter.
+type NGramTokenizer struct {
+	Filter *NGramTokenFilter
+}
+
+// newNGramTokenizer creates an n-gram tokenizer from its parameters, which are the same as those of
+// the n-gram filter.
+func newNGramTokenizer(params []string) (Tokenizer, error) {
+	filter, err := parseNGramParams(params, false)
+	if err != nil {
+		return nil, err
+	}
+	return &NGramTokenizer{Filter: filter}, nil
+}
+
+// newEdgeNGramTokenizer creates an edge n-gram tokenizer from its parameters, which are the same as
+// those of the edge n-gram filter.
+func newEdgeNGramTokenizer(params []string) (Tokenizer, error) {
+	filter, err := parseNGramParams(params, true)
+	if err != nil {
+		return nil, err
+	}
+	return &NGramTokenizer{Filter: filter}, nil
+}
+
+// Tokenize splits text into grams.
+func (tokenizer *NGramTokenizer) Tokenize(s string) []Token {
+	return tokenizer.Filter.Filter((&UnicodeTokenizer{}).Tokenize(s))
+}
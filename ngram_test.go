@@ -0,0 +1,78 @@
+package folder
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNGramTokenFilter(t *testing.T) {
+	tokens := NewNGramTokenFilter(2, 3).Filter([]Token{{Term: "café", Start: 4, End: 9}})
+	assert.Equal(t, []string{"ca", "caf", "af", "afé", "fé"}, terms(tokens))
+	assert.Equal(t, 6, tokens[4].Start)
+	assert.Equal(t, 9, tokens[4].End)
+
+	tokens = NewEdgeNGramTokenFilter(1, 3).Filter([]Token{{Term: "folder"}, {Term: "go", Position: 1}})
+	assert.Equal(t, []string{"f", "fo", "fol", "g", "go"}, terms(tokens))
+	assert.Equal(t, 1, tokens[4].Position)
+
+	filter, err := newEdgeNGramTokenFilter([]string{"2", "3", PreserveOriginal})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"folder", "fo", "fol", "a"}, terms(filter.Filter([]Token{{Term: "folder"}, {Term: "a"}})))
+
+	tokenizer, err := newEdgeNGramTokenizer([]string{"1", "2"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"S", "Se", "f", "fi"}, terms(tokenizer.Tokenize("Search, fi")))
+
+	for _, params := range [][]string{{"0"}, {"3", "2"}, {"two"}, {"1", "2", "3"}} {
+		_, err = newNGramTokenFilter(params)
+		assert.True(t, errors.Is(err, ErrInvalidParameter), params)
+	}
+}
+
+func TestSearchAsYouType(t *testing.T) {
+	dir, err := os.MkdirTemp("", "folder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	index := New()
+	err = index.AddAnalyzer("autocomplete", AnalyzerConfig{
+		Tokenizer: ComponentConfig{Type: "unicode"},
+		TokenFilters: []ComponentConfig{
+			{Type: "lowercase"},
+			{Type: "edge_ngram", Params: []string{"1", "10"}},
+		},
+	})
+	assert.Nil(t, err)
+	index.SetFieldAnalyzer("title", "autocomplete")
+	index.SetFieldSearchAnalyzer("title", StandardAnalyzer)
+	index.IndexWithID(map[string]interface{}{"title": "Elephant Seal"}, "1")
+	index.IndexWithID(map[string]interface{}{"title": "Eel"}, "2")
+
+	res, _ := index.Search("eleph")
+	assert.Equal(t, 1, res.Count)
+	assert.Equal(t, "1", res.Hits[0].ID)
+
+	// The query is not broken down into grams so "elk" doesn't match anything starting with "e"
+	res, _ = index.Search("elk")
+	assert.Equal(t, 0, res.Count)
+
+	err = index.SaveToShards(dir+"/index", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loadedIndex, err := LoadDeferred(dir + "/index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, index.Analysis, loadedIndex.Analysis)
+
+	res, _ = loadedIndex.Search("ee")
+	assert.Equal(t, 1, res.Count)
+	assert.Equal(t, "2", res.Hits[0].ID)
+}
@@ -0,0 +1,139 @@
+package folder
+
+import (
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// halfwidthKatakana and fullwidthKatakana are the half-width forms from U+FF61 to U+FF9F and their
+	// full-width counterparts. The voiced sound marks become combining marks that are then composed
+	// with the preceding Kana.
+	halfwidthKatakana = "｡｢｣､･ｦｧｨｩｪｫｬｭｮｯｰｱｲｳｴｵｶｷｸｹｺｻｼｽｾｿﾀﾁﾂﾃﾄﾅﾆﾇﾈﾉﾊﾋﾌﾍﾎﾏﾐﾑﾒﾓﾔﾕﾖﾗﾘﾙﾚﾛﾜﾝﾞﾟ"
+	fullwidthKatakana = "。「」、・ヲァィゥェォャュョッーアイウエオカキクケコサシスセソタチツテトナニヌネノハヒフヘホマミムメモヤユヨラリルレロワン゙゚"
+)
+
+var (
+	halfwidthToFullwidth = make(map[rune]rune)
+
+	// asciiFoldings contains the ASCII equivalents of letters that have no compatibility
+	// decomposition.
+	asciiFoldings = map[rune]string{
+		'Æ': "AE", 'æ': "ae", 'ß': "ss", 'Ø': "O", 'ø': "o", 'Đ': "D", 'đ': "d", 'Ð': "D", 'ð': "d",
+		'Ł': "L", 'ł': "l", 'Œ': "OE", 'œ': "oe", 'Þ': "TH", 'þ': "th", 'ı': "i", 'Ħ': "H", 'ħ': "h",
+		'Ŋ': "N", 'ŋ': "n", 'Ŧ': "T", 'ŧ': "t", 'ƒ': "f", 'ʼ': "'",
+	}
+)
+
+func init() {
+	fullwidth := []rune(fullwidthKatakana)
+	for i, r := range []rune(halfwidthKatakana) {
+		halfwidthToFullwidth[r] = fullwidth[i]
+	}
+}
+
+// isCombiningDiacritic returns whether a rune is in the Combining Diacritical Marks block.
+func isCombiningDiacritic(r rune) bool {
+	return r >= 0x0300 && r <= 0x036f
+}
+
+// isASCII returns whether a string only contains ASCII characters.
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// widthMapping returns the normal width form of full-width ASCII and half-width Katakana.
+func widthMapping(r rune) rune {
+	switch {
+	case r >= 0xff01 && r <= 0xff5e:
+		return r - 0xfee0
+	case r >= 0xff61 && r <= 0xff9f:
+		return halfwidthToFullwidth[r]
+	}
+	return r
+}
+
+// NormalizeNFKC applies the Unicode NFKC normalization, which replaces compatibility characters
+// such as full-width and half-width forms, ligatures, and circled numbers by their canonical
+// equivalents and composes characters with the combining marks that follow them.
+func NormalizeNFKC(s string) string {
+	return norm.NFKC.String(s)
+}
+
+// FoldWidth converts full-width ASCII into ASCII and half-width Katakana into full-width Katakana.
+func FoldWidth(s string) string {
+	var runes []rune
+	for _, r := range s {
+		runes = append(runes, widthMapping(r))
+	}
+	return norm.NFC.String(string(runes))
+}
+
+// FoldASCII removes the diacritics of Latin letters and replaces letters such as æ, ß, and ǆ by
+// their ASCII equivalents so that, for example, "Café" and "Cafe" become the same. Characters that
+// don't decompose into ASCII letters and combining marks are kept as is.
+func FoldASCII(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if folded, ok := asciiFoldings[r]; ok {
+			b.WriteString(folded)
+			continue
+		}
+		if r < 0x80 {
+			b.WriteRune(r)
+			continue
+		}
+
+		folded := strings.Map(func(r rune) rune {
+			if isCombiningDiacritic(r) {
+				return -1
+			}
+			if folded, ok := asciiFoldings[r]; ok && len(folded) == 1 {
+				return rune(folded[0])
+			}
+			return r
+		}, norm.NFKD.String(string(r)))
+		switch {
+		case folded == "":
+			// Combining diacritics on their own are removed
+		case isASCII(folded):
+			b.WriteString(folded)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// FoldKana converts Katakana into Hiragana so that words written in either script become the same.
+func FoldKana(s string) string {
+	return katakanaToHiragana(s)
+}
+
+// termTokenFilter returns a token filter that transforms the term of every token.
+func termTokenFilter(f func(s string) string) func(tokens []Token) []Token {
+	return func(tokens []Token) (filteredTokens []Token) {
+		for _, token := range tokens {
+			token.Term = f(token.Term)
+			filteredTokens = append(filteredTokens, token)
+		}
+		return
+	}
+}
+
+var (
+	// NFKCTokenFilter is the token filter counterpart of NormalizeNFKC.
+	NFKCTokenFilter = termTokenFilter(NormalizeNFKC)
+	// WidthFoldingTokenFilter is the token filter counterpart of FoldWidth.
+	WidthFoldingTokenFilter = termTokenFilter(FoldWidth)
+	// ASCIIFoldingTokenFilter is the token filter counterpart of FoldASCII.
+	ASCIIFoldingTokenFilter = termTokenFilter(FoldASCII)
+	// KanaFoldingTokenFilter is the token filter counterpart of FoldKana.
+	KanaFoldingTokenFilter = termTokenFilter(FoldKana)
+)
@@ -0,0 +1,45 @@
+package folder
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeNFKC(t *testing.T) {
+	assert.Equal(t, "ABC def123", NormalizeNFKC("ＡＢＣ　ｄｅｆ１２３"))
+	assert.Equal(t, "ガギグ パン", NormalizeNFKC("ｶﾞｷﾞｸﾞ ﾊﾟﾝ"))
+	assert.Equal(t, "fifl 120 XIIiv x23", NormalizeNFKC("ﬁﬂ ①⑳ Ⅻⅳ x²₃"))
+	assert.Equal(t, "Café ấ が", NormalizeNFKC("Cafe\u0301 a\u0302\u0301 か\u3099"))
+	assert.Equal(t, "TM ... μ kg 1⁄2 \u00c5 가 (株) a A dž", NormalizeNFKC("™ … µ ㎏ ½ \u212b \u1100\u1161 ㈱ ⓐ 𝐀 ǆ"))
+	assert.Equal(t, "ệ", NormalizeNFKC("e\u0323\u0302"))
+}
+
+func TestFolding(t *testing.T) {
+	assert.Equal(t, "Cafe AEro Strasse Tieng Viet が", FoldASCII("Café Ærø Straße Tiếng Việt が"))
+	assert.Equal(t, "DZ 'n IJ o ½ ｶﾞ", FoldASCII("Ǆ ŉ Ĳ ǿ ½ ｶﾞ"))
+	assert.Equal(t, "ABC123ガ", FoldWidth("ＡＢＣ１２３ｶﾞ"))
+	assert.Equal(t, "かたかな ーゔ", FoldKana("カタカナ ーヴ"))
+}
+
+func TestFoldingAnalyzer(t *testing.T) {
+	index := New()
+	err := index.AddAnalyzer("folding", AnalyzerConfig{
+		Tokenizer: ComponentConfig{Type: "unicode"},
+		TokenFilters: []ComponentConfig{
+			{Type: "nfkc"},
+			{Type: "lowercase"},
+			{Type: "ascii_folding"},
+			{Type: "kana_folding"},
+		},
+	})
+	assert.Nil(t, err)
+	index.SetDefaultAnalyzer("folding")
+
+	index.IndexWithID(map[string]interface{}{"title": "Café ＡＢＣ ﾊﾟﾝ"}, "1")
+
+	for _, query := range []string{"cafe", "CAFÉ", "abc", "パン", "ぱん"} {
+		res, _ := index.Search(query)
+		assert.Equal(t, 1, res.Count, query)
+	}
+}
@@ -0,0 +1,471 @@
+package folder
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// PatchOperationType is the type of a patch operation.
+type PatchOperationType string
+
+const (
+	// PatchSet sets a field to the value of the operation, adding the objects on its path if needed.
+	PatchSet PatchOperationType = "set"
+	// PatchRemove removes a field.
+	PatchRemove PatchOperationType = "remove"
+	// PatchAppend appends the value of the operation to an array field, which is created if needed.
+	PatchAppend PatchOperationType = "append"
+	// PatchIncrement adds the value of the operation, which may be negative, to a number field. A
+	// missing field is set to the value.
+	PatchIncrement PatchOperationType = "increment"
+)
+
+// PatchOperation is an operation on a field of a document, given by its path such as "author.name".
+type PatchOperation struct {
+	Type  PatchOperationType
+	Field string
+	Value interface{}
+}
+
+// Patch changes the fields of a document according to a JSON merge patch (RFC 7396), in which null
+// values remove fields, objects are merged into objects, and other values replace the fields. Only
+// the changed fields are analyzed again and the version of the document increases just like with
+// Update. ErrDocumentNotFound is returned if there is no such document.
+func (index *Index) Patch(documentID string, patch map[string]interface{}) (err error) {
+	return index.patch(documentID, func(document map[string]interface{}) (patched map[string]interface{}, fields []string, err error) {
+		patched = mergePatch(document, patch).(map[string]interface{})
+		fields = mergePatchFields("", document, patch)
+		return
+	})
+}
+
+// PatchOperations changes the fields of a document with a list of operations that are applied in
+// order, just like Patch does with a merge patch. The document is left as it is if any operation
+// fails.
+func (index *Index) PatchOperations(documentID string, operations ...PatchOperation) (err error) {
+	return index.patch(documentID, func(document map[string]interface{}) (patched map[string]interface{}, fields []string, err error) {
+		patched = document
+		for _, operation := range operations {
+			patched, err = applyPatchOperation(patched, operation)
+			if err != nil {
+				return
+			}
+			fields = append(fields, operation.Field)
+		}
+		return
+	})
+}
+
+// patch changes a document with a patch function that returns the patched document and the paths
+// of the fields that it changed. The terms of the changed fields in the document before and after
+// the patch are compared so that the term stats of the other fields are left alone.
+func (index *Index) patch(documentID string, apply func(document map[string]interface{}) (map[string]interface{}, []string, error)) (err error) {
+	// The shard of the document is loaded first if the index is loaded deferred
+	document, ok, err := index.fetchDocument(context.Background(), documentID)
+	if err != nil {
+		return
+	}
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrDocumentNotFound, documentID)
+	}
+
+	debug("Patch", documentID)
+
+	patched, changedFields, err := apply(document)
+	if err != nil {
+		return
+	}
+
+	// Languages detected from the whole document may change the analysis of every field
+	if index.Analysis.LanguageDetection.enabled() {
+		return index.Update(documentID, patched)
+	}
+
+	inferred, err := index.mapDocument(patched)
+	if err != nil {
+		return
+	}
+
+	fields := index.analyzedFields(changedFields)
+	for _, field := range fields {
+		for unstoredField, mapping := range index.Mapping.Fields {
+			if mapping.NotStored && fieldsOverlap(field, unstoredField) {
+				return fmt.Errorf("%w: %s is not stored", ErrInvalidPatch, unstoredField)
+			}
+		}
+	}
+
+	for field, mapping := range inferred {
+		index.setFieldMapping(field, mapping)
+	}
+
+	before := make(map[string][]string)
+	after := make(map[string][]string)
+	for _, field := range fields {
+		path := strings.Split(field, ".")
+		index.analyze(field, objectField(document, path), nil, before)
+		index.analyze(field, objectField(patched, path), nil, after)
+	}
+
+	err = index.updateTermFrequencies(documentID, joinTokens(before), joinTokens(after))
+	if err != nil {
+		return
+	}
+	for field := range after {
+		if !contains(index.FieldNames, field) {
+			debug("  Add new field name", field)
+			index.FieldNames = append(index.FieldNames, field)
+		}
+	}
+
+	// Nested objects are indexed again as a whole since their offsets may change
+	for field, mapping := range index.Mapping.Fields {
+		if mapping.Type != FieldTypeNested || !overlapsAny(field, fields) {
+			continue
+		}
+
+		beforeObjects := index.analyzeNested(documentID, document, nil)
+		afterObjects := index.analyzeNested(documentID, patched, nil)
+		for objectID := range beforeObjects {
+			if _, ok := afterObjects[objectID]; !ok {
+				afterObjects[objectID] = nil
+			}
+		}
+		for objectID, terms := range afterObjects {
+			err = index.updateTermFrequencies(objectID, beforeObjects[objectID], terms)
+			if err != nil {
+				return
+			}
+		}
+		break
+	}
+
+	index.Documents[documentID] = index.storedDocument(patched)
+	index.Versions[documentID]++
+	return
+}
+
+// analyzedFields returns the fields that are analyzed again when fields are changed. Changes within
+// the values of mapped fields, such as the latitude of a geo point, change the whole field, and
+// fields within other changed fields are left out.
+func (index *Index) analyzedFields(changedFields []string) (fields []string) {
+	set := MakeStringSet([]string{})
+	for _, field := range changedFields {
+		path := strings.Split(field, ".")
+		for i := 1; i < len(path); i++ {
+			prefix := strings.Join(path[:i], ".")
+			if mapping, ok := index.Mapping.Fields[prefix]; ok && mapping.Type != FieldTypeNested {
+				field = prefix
+				break
+			}
+		}
+		set.Add(field)
+	}
+
+	candidates := set.List()
+	sort.Strings(candidates)
+	for _, field := range candidates {
+		if len(fields) > 0 && strings.HasPrefix(field, fields[len(fields)-1]+".") {
+			continue
+		}
+		fields = append(fields, field)
+	}
+	return
+}
+
+// updateTermFrequencies changes the term frequencies of a document from the frequencies of the
+// tokens before a change to the frequencies of the tokens after it.
+func (index *Index) updateTermFrequencies(documentID string, before, after []string) (err error) {
+	deltas := make(map[string]int)
+	for _, token := range before {
+		deltas[token]--
+	}
+	for _, token := range after {
+		deltas[token]++
+	}
+
+	debug("  Update term frequencies in", documentID, "by", deltas)
+
+	for token, delta := range deltas {
+		var termStat TermStat
+
+		if delta == 0 {
+			continue
+		}
+
+		termStat, _, err = index.fetchTermStat(context.Background(), token)
+		if err != nil {
+			return
+		}
+		if termStat.TermFrequencies == nil {
+			termStat.TermFrequencies = make(map[string]int)
+		}
+
+		frequency := termStat.TermFrequencies[documentID] + delta
+		if frequency > 0 {
+			termStat.TermFrequencies[documentID] = frequency
+		} else {
+			delete(termStat.TermFrequencies, documentID)
+		}
+		index.TermStats[token] = termStat
+	}
+	return
+}
+
+// joinTokens returns the tokens of every field.
+func joinTokens(m map[string][]string) (tokens []string) {
+	for _, fieldTokens := range m {
+		tokens = append(tokens, fieldTokens...)
+	}
+	return
+}
+
+// fieldsOverlap returns whether two fields are the same field or one contains the other.
+func fieldsOverlap(a, b string) bool {
+	return a == b || strings.HasPrefix(a, b+".") || strings.HasPrefix(b, a+".")
+}
+
+func overlapsAny(field string, fields []string) bool {
+	for _, f := range fields {
+		if fieldsOverlap(field, f) {
+			return true
+		}
+	}
+	return false
+}
+
+// objectField returns the value of a field of an object, or nil if there is no such field. Unlike
+// fieldValuesFromRoot, it only goes through objects and returns the value as it is.
+func objectField(object map[string]interface{}, path []string) interface{} {
+	var v interface{} = object
+	for _, field := range path {
+		object, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = object[field]
+	}
+	return v
+}
+
+// mergePatch returns a copy of a value with a JSON merge patch applied to it. Objects that are not
+// changed by the patch are shared with the original value.
+func mergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, _ := target.(map[string]interface{})
+	patched := make(map[string]interface{}, len(targetObject)+len(patchObject))
+	for field, v := range targetObject {
+		patched[field] = v
+	}
+
+	for field, v := range patchObject {
+		if v == nil {
+			delete(patched, field)
+			continue
+		}
+		patched[field] = mergePatch(patched[field], v)
+	}
+	return patched
+}
+
+// mergePatchFields returns the paths of the fields that a JSON merge patch changes.
+func mergePatchFields(parentField string, target interface{}, patch map[string]interface{}) (fields []string) {
+	targetObject, _ := target.(map[string]interface{})
+	for name, v := range patch {
+		field := name
+		if parentField != "" {
+			field = parentField + "." + name
+		}
+
+		patchObject, isPatchObject := v.(map[string]interface{})
+		if _, isTargetObject := targetObject[name].(map[string]interface{}); isPatchObject && isTargetObject {
+			fields = append(fields, mergePatchFields(field, targetObject[name], patchObject)...)
+			continue
+		}
+		fields = append(fields, field)
+	}
+	return
+}
+
+// applyPatchOperation returns a copy of a document with an operation applied to it.
+func applyPatchOperation(document map[string]interface{}, operation PatchOperation) (patched map[string]interface{}, err error) {
+	if operation.Field == "" {
+		return nil, fmt.Errorf("%w: %s without a field", ErrInvalidPatch, operation.Type)
+	}
+
+	var update func(v interface{}, ok bool) (interface{}, bool, error)
+	switch operation.Type {
+	case PatchSet:
+		update = func(v interface{}, ok bool) (interface{}, bool, error) {
+			return operation.Value, true, nil
+		}
+	case PatchRemove:
+		if objectField(document, strings.Split(operation.Field, ".")) == nil {
+			return document, nil
+		}
+		update = func(v interface{}, ok bool) (interface{}, bool, error) {
+			return nil, false, nil
+		}
+	case PatchAppend:
+		update = func(v interface{}, ok bool) (interface{}, bool, error) {
+			appended, appendOK := appendValue(v, operation.Value)
+			if !appendOK {
+				return nil, false, fmt.Errorf("%w: cannot append to %s", ErrInvalidPatch, operation.Field)
+			}
+			return appended, true, nil
+		}
+	case PatchIncrement:
+		update = func(v interface{}, ok bool) (interface{}, bool, error) {
+			incremented, incrementOK := incrementValue(v, operation.Value)
+			if !incrementOK {
+				return nil, false, fmt.Errorf("%w: cannot increment %s by %v", ErrInvalidPatch, operation.Field, operation.Value)
+			}
+			return incremented, true, nil
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown operation %s", ErrInvalidPatch, operation.Type)
+	}
+
+	return updateObjectField(document, strings.Split(operation.Field, "."), update)
+}
+
+// updateObjectField returns a copy of an object whose field is replaced by the result of an update
+// function, or removed if the function doesn't keep it. Missing objects on the path of the field are
+// added.
+func updateObjectField(object map[string]interface{}, path []string, update func(v interface{}, ok bool) (interface{}, bool, error)) (updated map[string]interface{}, err error) {
+	updated = make(map[string]interface{}, len(object)+1)
+	for field, v := range object {
+		updated[field] = v
+	}
+
+	field := path[0]
+	if len(path) == 1 {
+		v, ok := updated[field]
+		v, keep, err := update(v, ok)
+		if err != nil {
+			return nil, err
+		}
+		if keep {
+			updated[field] = v
+		} else {
+			delete(updated, field)
+		}
+		return updated, nil
+	}
+
+	var child map[string]interface{}
+	switch value := updated[field].(type) {
+	case nil:
+		child = make(map[string]interface{})
+	case map[string]interface{}:
+		child = value
+	default:
+		return nil, fmt.Errorf("%w: %s is not an object", ErrInvalidPatch, field)
+	}
+
+	updated[field], err = updateObjectField(child, path[1:], update)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// appendValue returns a copy of an array with a value appended to it, or an array of the value if
+// there is no array yet.
+func appendValue(array, v interface{}) (appended interface{}, ok bool) {
+	switch value := array.(type) {
+	case nil:
+		return []interface{}{v}, true
+	case []interface{}:
+		return append(append([]interface{}{}, value...), v), true
+	case []string:
+		if s, isString := v.(string); isString {
+			return append(append([]string{}, value...), s), true
+		}
+	case []float64:
+		if f, isNumber := numberValue(v); isNumber {
+			if _, isString := v.(string); !isString {
+				return append(append([]float64{}, value...), f), true
+			}
+		}
+	case []map[string]interface{}:
+		if object, isObject := v.(map[string]interface{}); isObject {
+			return append(append([]map[string]interface{}{}, value...), object), true
+		}
+	}
+	return
+}
+
+// incrementValue adds a number to another number, keeping the type of the number that is added to
+// if the sum fits in it.
+func incrementValue(number, increment interface{}) (sum interface{}, ok bool) {
+	if _, isString := increment.(string); isString {
+		return
+	}
+	if number == nil {
+		_, ok = numberValue(increment)
+		return increment, ok
+	}
+
+	rv := reflect.ValueOf(number)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, isInteger := integerValue(increment)
+		if !isInteger {
+			return
+		}
+		// The sum would wrap around before it could be checked against the kind of the number
+		if i > 0 && rv.Int() > math.MaxInt64-i || i < 0 && rv.Int() < math.MinInt64-i || rv.OverflowInt(rv.Int()+i) {
+			return
+		}
+		result := reflect.New(rv.Type()).Elem()
+		result.SetInt(rv.Int() + i)
+		return result.Interface(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, isInteger := integerValue(increment)
+		if !isInteger {
+			return
+		}
+
+		u := rv.Uint() + uint64(i)
+		if i < 0 {
+			if uint64(-i) > rv.Uint() {
+				return
+			}
+			u = rv.Uint() - uint64(-i)
+		}
+		if u < rv.Uint() && i > 0 || rv.OverflowUint(u) {
+			return
+		}
+
+		result := reflect.New(rv.Type()).Elem()
+		result.SetUint(u)
+		return result.Interface(), true
+	case reflect.Float32, reflect.Float64:
+		f, isNumber := numberValue(increment)
+		if !isNumber {
+			return
+		}
+		result := reflect.New(rv.Type()).Elem()
+		result.SetFloat(rv.Float() + f)
+		return result.Interface(), true
+	}
+
+	if _, isString := number.(string); isString {
+		return
+	}
+	f, isNumber := numberValue(number)
+	if !isNumber {
+		return
+	}
+	g, isNumber := numberValue(increment)
+	return f + g, isNumber
+}
@@ -0,0 +1,150 @@
+package folder
+
+import (
+	"errors"
+	"math"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPatch(t *testing.T) {
+	index := New()
+	err := index.SetFieldMapping("views", FieldMapping{Type: FieldTypeInteger})
+	assert.Nil(t, err)
+	index.IndexWithID(map[string]interface{}{
+		"title":  "Folder search engine",
+		"views":  41,
+		"author": map[string]interface{}{"name": "Lilis Iskandar", "hobbies": []string{"drawing"}},
+	}, "1")
+	index.IndexWithID(map[string]interface{}{"title": "Another search engine"}, "2")
+
+	err = index.Patch("1", map[string]interface{}{
+		"title":  "Folder search library",
+		"author": map[string]interface{}{"name": nil, "country": "Indonesia"},
+	})
+	assert.Nil(t, err)
+
+	document, version, _ := index.FetchWithVersion("1")
+	assert.Equal(t, uint64(2), version)
+	assert.Equal(t, map[string]interface{}{
+		"title":  "Folder search library",
+		"views":  41,
+		"author": map[string]interface{}{"hobbies": []string{"drawing"}, "country": "Indonesia"},
+	}, document)
+
+	// The term stats end up the same as if the document were indexed again
+	expected := New()
+	expected.IndexWithID(document, "1")
+	expected.IndexWithID(map[string]interface{}{"title": "Another search engine"}, "2")
+	for term, termStat := range expected.TermStats {
+		assert.Equal(t, termStat.TermFrequencies, index.TermStats[term].TermFrequencies, term)
+	}
+	assert.Equal(t, 0, index.documentFrequency("lilis"))
+	assert.Equal(t, 1, index.documentFrequency("engine"))
+
+	err = index.PatchOperations("1",
+		PatchOperation{Type: PatchAppend, Field: "author.hobbies", Value: "gaming"},
+		PatchOperation{Type: PatchIncrement, Field: "views", Value: 1},
+		PatchOperation{Type: PatchSet, Field: "publisher.name", Value: "Veand"},
+		PatchOperation{Type: PatchRemove, Field: "title"},
+	)
+	assert.Nil(t, err)
+
+	document, _ = index.Fetch("1")
+	assert.Equal(t, map[string]interface{}{
+		"views":     42,
+		"author":    map[string]interface{}{"hobbies": []string{"drawing", "gaming"}, "country": "Indonesia"},
+		"publisher": map[string]interface{}{"name": "Veand"},
+	}, document)
+	assert.Equal(t, FieldTypeInteger, index.Mapping.Fields["views"].Type)
+	assert.Equal(t, 1, index.documentFrequency(exactTerm("views", "42")))
+	assert.Equal(t, 0, index.documentFrequency(exactTerm("views", "41")))
+
+	res, _ := index.Search("gaming veand")
+	assert.Equal(t, 1, res.Count)
+	res, _ = index.Search("library")
+	assert.Equal(t, 0, res.Count)
+
+	// Failed patches leave the document alone
+	err = index.PatchOperations("1",
+		PatchOperation{Type: PatchAppend, Field: "author.hobbies", Value: "hiking"},
+		PatchOperation{Type: PatchIncrement, Field: "author.country", Value: 1},
+	)
+	assert.True(t, errors.Is(err, ErrInvalidPatch))
+	err = index.Patch("1", map[string]interface{}{"views": "many"})
+	assert.True(t, errors.Is(err, ErrInvalidFieldValue))
+	document, version, _ = index.FetchWithVersion("1")
+	assert.Equal(t, []string{"drawing", "gaming"}, document["author"].(map[string]interface{})["hobbies"])
+	assert.Equal(t, 42, document["views"])
+	assert.Equal(t, uint64(3), version)
+
+	err = index.Patch("3", map[string]interface{}{"title": "Folder"})
+	assert.True(t, errors.Is(err, ErrDocumentNotFound))
+}
+
+func TestPatchIncrementOverflow(t *testing.T) {
+	index := New()
+	_, err := index.IndexWithID(map[string]interface{}{"views": int64(math.MaxInt64), "likes": int64(math.MinInt64)}, "1")
+	assert.Nil(t, err)
+
+	// Sums that do not fit in the number are rejected instead of wrapping around
+	err = index.PatchOperations("1", PatchOperation{Type: PatchIncrement, Field: "views", Value: 1})
+	assert.True(t, errors.Is(err, ErrInvalidPatch))
+	err = index.PatchOperations("1", PatchOperation{Type: PatchIncrement, Field: "likes", Value: -1})
+	assert.True(t, errors.Is(err, ErrInvalidPatch))
+
+	document, _ := index.Fetch("1")
+	assert.Equal(t, int64(math.MaxInt64), document["views"])
+	assert.Equal(t, int64(math.MinInt64), document["likes"])
+
+	err = index.PatchOperations("1", PatchOperation{Type: PatchIncrement, Field: "views", Value: -1})
+	assert.Nil(t, err)
+	document, _ = index.Fetch("1")
+	assert.Equal(t, int64(math.MaxInt64-1), document["views"])
+
+	_, ok := incrementValue(int8(127), 1)
+	assert.False(t, ok)
+}
+
+func TestPatchDeferred(t *testing.T) {
+	dir, err := os.MkdirTemp("", "folder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	index := New()
+	index.IndexWithID(map[string]interface{}{"title": "Folder", "tags": []string{"search"}}, "1")
+	index.IndexWithID(map[string]interface{}{"title": "Another search engine"}, "2")
+	err = index.SaveToShards(dir+"/index", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loadedIndex, err := LoadDeferred(dir + "/index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = loadedIndex.PatchOperations("1", PatchOperation{Type: PatchAppend, Field: "tags", Value: "engine"})
+	assert.Nil(t, err)
+
+	res, _ := loadedIndex.Search("engine")
+	assert.Equal(t, 2, res.Count)
+	res, _ = loadedIndex.Search("folder")
+	assert.Equal(t, 1, res.Count)
+	assert.Equal(t, []string{"search", "engine"}, res.Hits[0].Source["tags"])
+	assert.Equal(t, uint64(2), res.Hits[0].Version)
+
+	err = loadedIndex.SaveToShards(dir+"/index", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loadedIndex, err = LoadDeferred(dir + "/index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, _ = loadedIndex.Search("engine")
+	assert.Equal(t, 2, res.Count)
+}
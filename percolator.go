@@ -0,0 +1,108 @@
+package folder
+
+import (
+	"sort"
+)
+
+// percolatedDocumentID is the ID given to a document while it is being matched against the queries.
+const percolatedDocumentID = "_percolated"
+
+// Percolator stores queries and finds which of them match a document. Unlike an index, documents
+// are only matched against the stored queries and are never kept. Percolators are only kept in
+// memory and are not saved along with indexes, so the queries need to be registered again when the
+// program starts, e.g. from the Queries of a percolator that the program saved by itself.
+type Percolator struct {
+	Queries  map[string]string
+	Analysis AnalysisSettings // Analysis settings used for both the documents and the queries
+}
+
+// NewPercolator creates a percolator without any queries which analyzes text like a new index.
+func NewPercolator() (percolator *Percolator) {
+	percolator = &Percolator{}
+	percolator.Queries = make(map[string]string)
+	percolator.Analysis = NewAnalysisSettings()
+	return
+}
+
+// Register stores a query with a user-specified ID, replacing any query with the same ID.
+func (percolator *Percolator) Register(queryID, query string) {
+	if percolator.Queries == nil {
+		percolator.Queries = make(map[string]string)
+	}
+	percolator.Queries[queryID] = query
+}
+
+// Unregister removes a stored query.
+func (percolator *Percolator) Unregister(queryID string) {
+	delete(percolator.Queries, queryID)
+}
+
+// Percolate returns the sorted IDs of the stored queries that match the document, i.e. the queries
+// having at least one term of every analyzed token group in the document.
+func (percolator *Percolator) Percolate(document map[string]interface{}) (queryIDs []string, err error) {
+	debug("Percolate", len(percolator.Queries), "queries")
+
+	index := New()
+	index.Analysis = percolator.Analysis
+	_, err = index.IndexWithID(document, percolatedDocumentID)
+	if err != nil {
+		return
+	}
+
+	for queryID, query := range percolator.Queries {
+		// The query matches if any of its analyses does
+		for _, groups := range index.analyzeQuery(query) {
+			if index.containsGroups(groups) {
+				queryIDs = append(queryIDs, queryID)
+				break
+			}
+		}
+	}
+
+	sort.Strings(queryIDs)
+	return
+}
+
+// containsGroups returns whether the percolated document contains at least one token of every group
+// of tokens. Unlike searches, groups that are not found are not ignored since there are no other
+// documents that could contain them.
+func (index *Index) containsGroups(groups [][]string) bool {
+	if len(groups) == 0 {
+		return false
+	}
+
+	for _, group := range groups {
+		found := false
+		for _, token := range group {
+			if _, ok := index.TermStats[token].TermFrequencies[percolatedDocumentID]; ok {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// PercolateData percolates every document in an array of bytes of a certain data type such as text,
+// JSON, or JSONL, and returns the matching query IDs of each document in the same order.
+func (percolator *Percolator) PercolateData(data []byte, dataType string) (matches [][]string, err error) {
+	var documents []map[string]interface{}
+	var queryIDs []string
+
+	documents, err = documentsFromData(data, dataType)
+	if err != nil {
+		return
+	}
+
+	for _, document := range documents {
+		queryIDs, err = percolator.Percolate(document)
+		if err != nil {
+			return
+		}
+		matches = append(matches, queryIDs)
+	}
+	return
+}
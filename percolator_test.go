@@ -0,0 +1,41 @@
+package folder
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPercolate(t *testing.T) {
+	percolator := NewPercolator()
+	percolator.Register("cooking", "cooking")
+	percolator.Register("malaysia-hiking", "malaysia hiking")
+	percolator.Register("korea", "south korea")
+
+	queryIDs, err := percolator.Percolate(map[string]interface{}{
+		"first_name": "Lilis",
+		"details": map[string]interface{}{
+			"country": "Malaysia",
+			"hobbies": []interface{}{"cooking", "gardening", "hiking"},
+		},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"cooking", "malaysia-hiking"}, queryIDs)
+
+	percolator.Unregister("cooking")
+	matches, err := percolator.PercolateData([]byte(`{"country": "Malaysia", "hobbies": ["cooking", "hiking"]}
+{"country": "South Korea", "hobbies": ["drawing"]}`), "jsonl")
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{"malaysia-hiking"}, {"korea"}}, matches)
+
+	// Queries only match when every one of their terms is found
+	percolator.Register("malaysia-drawing", "malaysia drawing")
+	queryIDs, err = percolator.Percolate(map[string]interface{}{"country": "South Korea", "hobbies": []interface{}{"drawing"}})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"korea"}, queryIDs)
+
+	_, err = percolator.PercolateData([]byte("country: Malaysia"), "yaml")
+	assert.ErrorIs(t, err, ErrUnsupportedDocumentDataType)
+	_, err = percolator.PercolateData([]byte("{}\n{"), "jsonl")
+	assert.EqualError(t, err, "line 2: unexpected end of JSON input")
+}
@@ -0,0 +1,624 @@
+package folder
+
+import (
+	"strings"
+)
+
+const (
+	// TokenTypePhonetic is the type of tokens made of a phonetic code.
+	TokenTypePhonetic = "phonetic"
+
+	// doubleMetaphoneLength is the maximum length of Double Metaphone codes.
+	doubleMetaphoneLength = 4
+)
+
+// soundexCodes contains the Soundex digits of the consonants that are encoded.
+var soundexCodes = map[rune]byte{
+	'B': '1', 'F': '1', 'P': '1', 'V': '1',
+	'C': '2', 'G': '2', 'J': '2', 'K': '2', 'Q': '2', 'S': '2', 'X': '2', 'Z': '2',
+	'D': '3', 'T': '3',
+	'L': '4',
+	'M': '5', 'N': '5',
+	'R': '6',
+}
+
+// Soundex returns the American Soundex code of a word, e.g. "R163" for both "Robert" and "Rupert",
+// or an empty string if the word doesn't start with a Latin letter.
+func Soundex(s string) string {
+	var code []byte
+	var previous byte
+
+	for _, r := range strings.ToUpper(FoldASCII(s)) {
+		if r < 'A' || r > 'Z' {
+			continue
+		}
+
+		digit := soundexCodes[r]
+		if len(code) == 0 {
+			code = append(code, byte(r))
+			previous = digit
+			continue
+		}
+
+		switch {
+		case r == 'H' || r == 'W':
+			// H and W don't separate consonants with the same code
+		case digit == 0:
+			previous = 0
+		case digit != previous:
+			code = append(code, digit)
+			previous = digit
+		}
+		if len(code) == 4 {
+			break
+		}
+	}
+
+	if len(code) == 0 {
+		return ""
+	}
+	for len(code) < 4 {
+		code = append(code, '0')
+	}
+	return string(code)
+}
+
+// doubleMetaphone holds the state of the encoding of a word by DoubleMetaphone.
+type doubleMetaphone struct {
+	word          []rune
+	primary       strings.Builder
+	alternate     strings.Builder
+	slavoGermanic bool
+}
+
+// DoubleMetaphone returns the primary and alternate Double Metaphone codes of a word as described by
+// Lawrence Philips, e.g. "XMT" and "SMT" for "Schmidt". Both codes are the same for most words.
+func DoubleMetaphone(s string) (primary, alternate string) {
+	dm := &doubleMetaphone{}
+
+	// Letters are folded into ASCII except for the few that have rules of their own
+	for _, r := range strings.ToUpper(strings.TrimSpace(s)) {
+		if r == 'Ç' || r == 'Ñ' {
+			dm.word = append(dm.word, r)
+			continue
+		}
+		dm.word = append(dm.word, []rune(FoldASCII(string(r)))...)
+	}
+	if len(dm.word) == 0 {
+		return
+	}
+
+	w := string(dm.word)
+	dm.slavoGermanic = strings.ContainsAny(w, "WK") || strings.Contains(w, "CZ") || strings.Contains(w, "WITZ")
+
+	i := 0
+	if dm.at(0, "GN", "KN", "PN", "WR", "PS") {
+		// The first letter is silent
+		i = 1
+	}
+
+	for i < len(dm.word) && (dm.primary.Len() < doubleMetaphoneLength || dm.alternate.Len() < doubleMetaphoneLength) {
+		switch dm.char(i) {
+		case 'A', 'E', 'I', 'O', 'U', 'Y':
+			if i == 0 {
+				dm.add("A")
+			}
+			i++
+		case 'B':
+			dm.add("P")
+			i = dm.skipDouble(i, "B")
+		case 'Ç':
+			dm.add("S")
+			i++
+		case 'C':
+			i = dm.encodeC(i)
+		case 'D':
+			i = dm.encodeD(i)
+		case 'F':
+			dm.add("F")
+			i = dm.skipDouble(i, "F")
+		case 'G':
+			i = dm.encodeG(i)
+		case 'H':
+			// H is only kept at the start or between vowels
+			if (i == 0 || dm.isVowel(i-1)) && dm.isVowel(i+1) {
+				dm.add("H")
+				i += 2
+			} else {
+				i++
+			}
+		case 'J':
+			i = dm.encodeJ(i)
+		case 'K':
+			dm.add("K")
+			i = dm.skipDouble(i, "K")
+		case 'L':
+			i = dm.encodeL(i)
+		case 'M':
+			dm.add("M")
+			if dm.char(i+1) == 'M' || (dm.at(i-1, "UMB") && (i+1 == len(dm.word)-1 || dm.at(i+2, "ER"))) {
+				i += 2
+			} else {
+				i++
+			}
+		case 'N':
+			dm.add("N")
+			i = dm.skipDouble(i, "N")
+		case 'Ñ':
+			dm.add("N")
+			i++
+		case 'P':
+			if dm.char(i+1) == 'H' {
+				dm.add("F")
+				i += 2
+			} else {
+				dm.add("P")
+				i = dm.skipDouble(i, "P", "B")
+			}
+		case 'Q':
+			dm.add("K")
+			i = dm.skipDouble(i, "Q")
+		case 'R':
+			if i == len(dm.word)-1 && !dm.slavoGermanic && dm.at(i-2, "IE") && !dm.at(i-4, "ME", "MA") {
+				// French e.g. "Rogier"
+				dm.addAlternate("R")
+			} else {
+				dm.add("R")
+			}
+			i = dm.skipDouble(i, "R")
+		case 'S':
+			i = dm.encodeS(i)
+		case 'T':
+			i = dm.encodeT(i)
+		case 'V':
+			dm.add("F")
+			i = dm.skipDouble(i, "V")
+		case 'W':
+			i = dm.encodeW(i)
+		case 'X':
+			if i == 0 {
+				dm.add("S")
+				i++
+				break
+			}
+			if !(i == len(dm.word)-1 && (dm.at(i-3, "IAU", "EAU") || dm.at(i-2, "AU", "OU"))) {
+				// Not French e.g. "Breaux"
+				dm.add("KS")
+			}
+			i = dm.skipDouble(i, "C", "X")
+		case 'Z':
+			if dm.char(i+1) == 'H' {
+				// Chinese Pinyin e.g. "Zhao"
+				dm.add("J")
+				i += 2
+				break
+			}
+			if dm.at(i+1, "ZO", "ZI", "ZA") || (dm.slavoGermanic && i > 0 && dm.char(i-1) != 'T') {
+				dm.addBoth("S", "TS")
+			} else {
+				dm.add("S")
+			}
+			i = dm.skipDouble(i, "Z")
+		default:
+			i++
+		}
+	}
+
+	primary, alternate = dm.primary.String(), dm.alternate.String()
+	if len(primary) > doubleMetaphoneLength {
+		primary = primary[:doubleMetaphoneLength]
+	}
+	if len(alternate) > doubleMetaphoneLength {
+		alternate = alternate[:doubleMetaphoneLength]
+	}
+	return
+}
+
+// char returns the letter at an offset, or 0 if the offset is outside of the word.
+func (dm *doubleMetaphone) char(i int) rune {
+	if i < 0 || i >= len(dm.word) {
+		return 0
+	}
+	return dm.word[i]
+}
+
+// at returns whether any of the substrings, which must have the same length, is at an offset.
+func (dm *doubleMetaphone) at(i int, substrings ...string) bool {
+	n := len(substrings[0])
+	if i < 0 || i+n > len(dm.word) {
+		return false
+	}
+	return contains(substrings, string(dm.word[i:i+n]))
+}
+
+func (dm *doubleMetaphone) isVowel(i int) bool {
+	return strings.ContainsRune("AEIOUY", dm.char(i))
+}
+
+// skipDouble returns the offset after the letter at an offset, also skipping the next letter if it
+// is one of the given letters.
+func (dm *doubleMetaphone) skipDouble(i int, letters ...string) int {
+	if dm.at(i+1, letters...) {
+		return i + 2
+	}
+	return i + 1
+}
+
+func (dm *doubleMetaphone) add(code string) {
+	dm.addBoth(code, code)
+}
+
+func (dm *doubleMetaphone) addBoth(primary, alternate string) {
+	dm.primary.WriteString(primary)
+	dm.alternate.WriteString(alternate)
+}
+
+func (dm *doubleMetaphone) addPrimary(code string) {
+	dm.primary.WriteString(code)
+}
+
+func (dm *doubleMetaphone) addAlternate(code string) {
+	dm.alternate.WriteString(code)
+}
+
+func (dm *doubleMetaphone) encodeC(i int) int {
+	switch {
+	case dm.isGermanicCH(i):
+		dm.add("K")
+		return i + 2
+	case i == 0 && dm.at(i, "CAESAR"):
+		dm.add("S")
+		return i + 2
+	case dm.at(i, "CH"):
+		return dm.encodeCH(i)
+	case dm.at(i, "CZ") && !dm.at(i-2, "WICZ"):
+		// "Czerny"
+		dm.addBoth("S", "X")
+		return i + 2
+	case dm.at(i+1, "CIA"):
+		// "Focaccia"
+		dm.add("X")
+		return i + 3
+	case dm.at(i, "CC") && !(i == 1 && dm.char(0) == 'M'):
+		// Double C but not "McClelland"
+		if dm.at(i+2, "I", "E", "H") && !dm.at(i+2, "HU") {
+			if (i == 1 && dm.char(i-1) == 'A') || dm.at(i-1, "UCCEE", "UCCES") {
+				// "Accident", "accede", "succeed"
+				dm.add("KS")
+			} else {
+				// "Bacci", "Bertucci"
+				dm.add("X")
+			}
+			return i + 3
+		}
+		dm.add("K")
+		return i + 2
+	case dm.at(i, "CK", "CG", "CQ"):
+		dm.add("K")
+		return i + 2
+	case dm.at(i, "CI", "CE", "CY"):
+		if dm.at(i, "CIO", "CIE", "CIA") {
+			// Italian
+			dm.addBoth("S", "X")
+		} else {
+			dm.add("S")
+		}
+		return i + 2
+	}
+
+	dm.add("K")
+	switch {
+	case dm.at(i+1, " C", " Q", " G"):
+		// "Mac Caffrey", "Mac Gregor"
+		return i + 3
+	case dm.at(i+1, "C", "K", "Q") && !dm.at(i+1, "CE", "CI"):
+		return i + 2
+	}
+	return i + 1
+}
+
+// isGermanicCH returns whether the C at an offset is part of a Germanic "ach" such as in "Bacher".
+func (dm *doubleMetaphone) isGermanicCH(i int) bool {
+	switch {
+	case dm.at(i, "CHIA"):
+		return true
+	case i <= 1 || dm.isVowel(i-2) || !dm.at(i-1, "ACH"):
+		return false
+	}
+	c := dm.char(i + 2)
+	return (c != 'I' && c != 'E') || dm.at(i-2, "BACHER", "MACHER")
+}
+
+func (dm *doubleMetaphone) encodeCH(i int) int {
+	switch {
+	case i > 0 && dm.at(i, "CHAE"):
+		// "Michael"
+		dm.addBoth("K", "X")
+	case i == 0 && (dm.at(i+1, "HARAC", "HARIS") || dm.at(i+1, "HOR", "HYM", "HIA", "HEM")) && !dm.at(0, "CHORE"):
+		// Greek roots such as "chemistry" and "chorus"
+		dm.add("K")
+	case dm.at(0, "VAN ", "VON ") || dm.at(0, "SCH") ||
+		dm.at(i-2, "ORCHES", "ARCHIT", "ORCHID") || dm.at(i+2, "T", "S") ||
+		((dm.at(i-1, "A", "O", "U", "E") || i == 0) &&
+			(dm.at(i+2, "L", "R", "N", "M", "B", "H", "F", "V", "W", " ") || i+1 == len(dm.word)-1)):
+		// Germanic, Greek, or otherwise pronounced "kh"
+		dm.add("K")
+	case i > 0 && dm.at(0, "MC"):
+		dm.add("K")
+	case i > 0:
+		dm.addBoth("X", "K")
+	default:
+		dm.add("X")
+	}
+	return i + 2
+}
+
+func (dm *doubleMetaphone) encodeD(i int) int {
+	switch {
+	case dm.at(i, "DG"):
+		if dm.at(i+2, "I", "E", "Y") {
+			// "Edge"
+			dm.add("J")
+			return i + 3
+		}
+		// "Edgar"
+		dm.add("TK")
+		return i + 2
+	case dm.at(i, "DT", "DD"):
+		dm.add("T")
+		return i + 2
+	}
+	dm.add("T")
+	return i + 1
+}
+
+func (dm *doubleMetaphone) encodeG(i int) int {
+	switch {
+	case dm.char(i+1) == 'H':
+		return dm.encodeGH(i)
+	case dm.char(i+1) == 'N':
+		switch {
+		case i == 1 && dm.isVowel(0) && !dm.slavoGermanic:
+			dm.addBoth("KN", "N")
+		case !dm.at(i+2, "EY") && dm.char(i+1) != 'Y' && !dm.slavoGermanic:
+			dm.addBoth("N", "KN")
+		default:
+			dm.add("KN")
+		}
+		return i + 2
+	case dm.at(i+1, "LI") && !dm.slavoGermanic:
+		// "Tagliaro"
+		dm.addBoth("KL", "L")
+		return i + 2
+	case i == 0 && (dm.char(i+1) == 'Y' || dm.at(i+1, "ES", "EP", "EB", "EL", "EY", "IB", "IL", "IN", "IE", "EI", "ER")):
+		dm.addBoth("K", "J")
+		return i + 2
+	case (dm.at(i+1, "ER") || dm.char(i+1) == 'Y') && !dm.at(0, "DANGER", "RANGER", "MANGER") &&
+		!dm.at(i-1, "E", "I") && !dm.at(i-1, "RGY", "OGY"):
+		dm.addBoth("K", "J")
+		return i + 2
+	case dm.at(i+1, "E", "I", "Y") || dm.at(i-1, "AGGI", "OGGI"):
+		switch {
+		case dm.at(0, "VAN ", "VON ") || dm.at(0, "SCH") || dm.at(i+1, "ET"):
+			// Germanic
+			dm.add("K")
+		case dm.at(i+1, "IER"):
+			dm.add("J")
+		default:
+			dm.addBoth("J", "K")
+		}
+		return i + 2
+	}
+
+	dm.add("K")
+	return dm.skipDouble(i, "G")
+}
+
+func (dm *doubleMetaphone) encodeGH(i int) int {
+	switch {
+	case i > 0 && !dm.isVowel(i-1):
+		dm.add("K")
+	case i == 0:
+		if dm.char(i+2) == 'I' {
+			dm.add("J")
+		} else {
+			dm.add("K")
+		}
+	case (i > 1 && dm.at(i-2, "B", "H", "D")) || (i > 2 && dm.at(i-3, "B", "H", "D")) || (i > 3 && dm.at(i-4, "B", "H")):
+		// Silent as in "hugh"
+	case i > 2 && dm.char(i-1) == 'U' && dm.at(i-3, "C", "G", "L", "R", "T"):
+		// "Laugh", "McLaughlin", "cough", "rough"
+		dm.add("F")
+	case dm.char(i-1) != 'I':
+		dm.add("K")
+	}
+	return i + 2
+}
+
+func (dm *doubleMetaphone) encodeJ(i int) int {
+	if dm.at(i, "JOSE") || dm.at(0, "SAN ") {
+		// Spanish e.g. "Jose", "San Jacinto"
+		if (i == 0 && dm.char(i+4) == ' ') || len(dm.word) == 4 || dm.at(0, "SAN ") {
+			dm.add("H")
+		} else {
+			dm.addBoth("J", "H")
+		}
+		return i + 1
+	}
+
+	switch {
+	case i == 0:
+		dm.addBoth("J", "A")
+	case dm.isVowel(i-1) && !dm.slavoGermanic && (dm.char(i+1) == 'A' || dm.char(i+1) == 'O'):
+		dm.addBoth("J", "H")
+	case i == len(dm.word)-1:
+		dm.addBoth("J", "")
+	case !dm.at(i+1, "L", "T", "K", "S", "N", "M", "B", "Z") && !dm.at(i-1, "S", "K", "L"):
+		dm.add("J")
+	}
+	return dm.skipDouble(i, "J")
+}
+
+func (dm *doubleMetaphone) encodeL(i int) int {
+	if dm.char(i+1) != 'L' {
+		dm.add("L")
+		return i + 1
+	}
+
+	n := len(dm.word)
+	if (i == n-3 && dm.at(i-1, "ILLO", "ILLA", "ALLE")) ||
+		((dm.at(n-2, "AS", "OS") || dm.at(n-1, "A", "O")) && dm.at(i-1, "ALLE")) {
+		// Spanish e.g. "Cabrillo", "Gallegos"
+		dm.addPrimary("L")
+	} else {
+		dm.add("L")
+	}
+	return i + 2
+}
+
+func (dm *doubleMetaphone) encodeS(i int) int {
+	switch {
+	case dm.at(i-1, "ISL", "YSL"):
+		// "Island", "isle", "Carlisle"
+		return i + 1
+	case i == 0 && dm.at(i, "SUGAR"):
+		dm.addBoth("X", "S")
+		return i + 1
+	case dm.at(i, "SH"):
+		if dm.at(i+1, "HEIM", "HOEK", "HOLM", "HOLZ") {
+			// Germanic
+			dm.add("S")
+		} else {
+			dm.add("X")
+		}
+		return i + 2
+	case dm.at(i, "SIO", "SIA") || dm.at(i, "SIAN"):
+		// Italian and Armenian
+		if dm.slavoGermanic {
+			dm.add("S")
+		} else {
+			dm.addBoth("S", "X")
+		}
+		return i + 3
+	case (i == 0 && dm.at(i+1, "M", "N", "L", "W")) || dm.at(i+1, "Z"):
+		// Germanic and anglicized e.g. "Smith" matches "Schmidt", and Slavic "sz"
+		dm.addBoth("S", "X")
+		return dm.skipDouble(i, "Z")
+	case dm.at(i, "SC"):
+		return dm.encodeSC(i)
+	}
+
+	if i == len(dm.word)-1 && dm.at(i-2, "AI", "OI") {
+		// French e.g. "Resnais", "Artois"
+		dm.addAlternate("S")
+	} else {
+		dm.add("S")
+	}
+	return dm.skipDouble(i, "S", "Z")
+}
+
+func (dm *doubleMetaphone) encodeSC(i int) int {
+	switch {
+	case dm.char(i+2) == 'H':
+		switch {
+		case dm.at(i+3, "ER", "EN"):
+			// "Schermerhorn", "Schenker"
+			dm.addBoth("X", "SK")
+		case dm.at(i+3, "OO", "UY", "ED", "EM"):
+			// Dutch e.g. "school", "schooner"
+			dm.add("SK")
+		case i == 0 && !dm.isVowel(3) && dm.char(3) != 'W':
+			dm.addBoth("X", "S")
+		default:
+			dm.add("X")
+		}
+	case dm.at(i+2, "I", "E", "Y"):
+		dm.add("S")
+	default:
+		dm.add("SK")
+	}
+	return i + 3
+}
+
+func (dm *doubleMetaphone) encodeT(i int) int {
+	switch {
+	case dm.at(i, "TION") || dm.at(i, "TIA", "TCH"):
+		dm.add("X")
+		return i + 3
+	case dm.at(i, "TH") || dm.at(i, "TTH"):
+		if dm.at(i+2, "OM", "AM") || dm.at(0, "VAN ", "VON ") || dm.at(0, "SCH") {
+			// "Thomas", "Thames", or Germanic
+			dm.add("T")
+		} else {
+			dm.addBoth("0", "T")
+		}
+		return i + 2
+	}
+	dm.add("T")
+	return dm.skipDouble(i, "T", "D")
+}
+
+func (dm *doubleMetaphone) encodeW(i int) int {
+	switch {
+	case dm.at(i, "WR"):
+		dm.add("R")
+		return i + 2
+	case i == 0 && dm.isVowel(i+1):
+		// "Wasserman" matches "Vasserman"
+		dm.addBoth("A", "F")
+		return i + 1
+	case i == 0 && dm.at(i, "WH"):
+		// "Uomo" matches "Womo"
+		dm.add("A")
+		return i + 1
+	case (i == len(dm.word)-1 && dm.isVowel(i-1)) || dm.at(i-1, "EWSKI", "EWSKY", "OWSKI", "OWSKY") || dm.at(0, "SCH"):
+		// "Arnow" matches "Arnoff"
+		dm.addAlternate("F")
+		return i + 1
+	case dm.at(i, "WICZ", "WITZ"):
+		// Polish e.g. "Filipowicz"
+		dm.addBoth("TS", "FX")
+		return i + 4
+	}
+	return i + 1
+}
+
+// phoneticTokenFilter returns a token filter that adds the phonetic codes of every token at the
+// same position as the token.
+func phoneticTokenFilter(encode func(s string) []string) func(tokens []Token) []Token {
+	return func(tokens []Token) (filteredTokens []Token) {
+		for _, token := range tokens {
+			filteredTokens = append(filteredTokens, token)
+
+			var codes []string
+			for _, code := range encode(token.Term) {
+				if code == "" || contains(codes, code) {
+					continue
+				}
+				codes = append(codes, code)
+
+				phonetic := token
+				phonetic.Term = code
+				phonetic.Type = TokenTypePhonetic
+				filteredTokens = append(filteredTokens, phonetic)
+			}
+		}
+		return
+	}
+}
+
+var (
+	// SoundexTokenFilter adds the Soundex codes of tokens so that words that sound alike match, e.g.
+	// "Iskandar" and "Iskander". The codes are in uppercase so it should come after LowercaseTokenFilter
+	// to keep them apart from words.
+	SoundexTokenFilter = phoneticTokenFilter(func(s string) []string {
+		return []string{Soundex(s)}
+	})
+	// DoubleMetaphoneTokenFilter adds the primary and alternate Double Metaphone codes of tokens so
+	// that words that sound alike match, e.g. "Lilis" and "Lillis". Just like SoundexTokenFilter, it
+	// should come after LowercaseTokenFilter.
+	DoubleMetaphoneTokenFilter = phoneticTokenFilter(func(s string) []string {
+		primary, alternate := DoubleMetaphone(s)
+		return []string{primary, alternate}
+	})
+)
@@ -0,0 +1,63 @@
+package folder
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSoundex(t *testing.T) {
+	assert.Equal(t, "R163", Soundex("Robert"))
+	assert.Equal(t, "R163", Soundex("Rupert"))
+	assert.Equal(t, "A261", Soundex("Ashcraft"))
+	assert.Equal(t, "T522", Soundex("Tymczak"))
+	assert.Equal(t, "L420", Soundex("Lilis"))
+	assert.Equal(t, "I253", Soundex("Iskander"))
+	assert.Equal(t, "", Soundex("송"))
+}
+
+func TestDoubleMetaphone(t *testing.T) {
+	for word, codes := range map[string][2]string{
+		"Lillis":     {"LLS", "LLS"},
+		"Iskandar":   {"ASKN", "ASKN"},
+		"Schmidt":    {"XMT", "SMT"},
+		"Smith":      {"SM0", "XMT"},
+		"Michael":    {"MKL", "MXL"},
+		"Knight":     {"NT", "NT"},
+		"Wasserman":  {"ASRM", "FSRM"},
+		"Filipowicz": {"FLPT", "FLPF"},
+		"Jose":       {"HS", "HS"},
+		"jalapeño":   {"JLPN", "ALPN"},
+	} {
+		primary, alternate := DoubleMetaphone(word)
+		assert.Equal(t, codes, [2]string{primary, alternate}, word)
+	}
+}
+
+func TestPhoneticSearch(t *testing.T) {
+	index := New()
+	for name, filter := range map[string]string{"metaphone_names": "double_metaphone", "soundex_names": "soundex"} {
+		err := index.AddAnalyzer(name, AnalyzerConfig{
+			Tokenizer:    ComponentConfig{Type: "unicode"},
+			TokenFilters: []ComponentConfig{{Type: "lowercase"}, {Type: filter}},
+		})
+		assert.Nil(t, err)
+	}
+	index.SetFieldAnalyzer("first_name", "metaphone_names")
+	index.SetFieldAnalyzer("last_name", "soundex_names")
+
+	err := index.IndexFilePath("assets/users_test.jsonl", "jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, query := range []string{"Lillis", "Iskander", "Lillis Iskander"} {
+		res, _ := index.Search(query)
+		assert.Equal(t, 1, res.Count, query)
+		assert.Equal(t, "Lilis", res.Hits[0].Source["first_name"], query)
+	}
+
+	// Codes are kept apart from words
+	res, _ := index.Search("lls")
+	assert.Equal(t, 0, res.Count)
+}
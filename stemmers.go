@@ -0,0 +1,669 @@
+package folder
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// stemmers contains the stemming algorithms available to the stemmer token filter by language.
+var stemmers = map[string]func(word string) string{
+	"english":    stemEnglish,
+	"german":     stemGerman,
+	"indonesian": stemIndonesian,
+	"spanish":    stemSpanish,
+}
+
+// StemmerTokenFilter reduces words to their stem using one of the Snowball stemming algorithms so
+// that, for example, "drawing" and "draws" produce the same term. Tokens are expected to be
+// lowercase.
+type StemmerTokenFilter struct {
+	Language string
+	stem     func(word string) string
+}
+
+// newStemmerTokenFilter creates a stemmer token filter for the language given as the first
+// parameter, or English if there is none.
+func newStemmerTokenFilter(params []string) (TokenFilter, error) {
+	language := "english"
+	if len(params) > 0 {
+		language = params[0]
+	}
+	return NewStemmerTokenFilter(language)
+}
+
+// NewStemmerTokenFilter creates a stemmer token filter for one of the languages returned by
+// StemmerLanguages.
+func NewStemmerTokenFilter(language string) (filter *StemmerTokenFilter, err error) {
+	stem, ok := stemmers[language]
+	if !ok {
+		err = fmt.Errorf("%w: %s", ErrUnknownLanguage, language)
+		return
+	}
+	filter = &StemmerTokenFilter{Language: language, stem: stem}
+	return
+}
+
+// StemmerLanguages returns the sorted languages supported by the stemmer token filter.
+func StemmerLanguages() (languages []string) {
+	for language := range stemmers {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return
+}
+
+// Filter replaces the term of every token by its stem.
+func (filter *StemmerTokenFilter) Filter(tokens []Token) (filteredTokens []Token) {
+	for _, token := range tokens {
+		token.Term = filter.stem(token.Term)
+		filteredTokens = append(filteredTokens, token)
+	}
+	return
+}
+
+// hasSuffixRunes returns whether a word ends with a suffix.
+func hasSuffixRunes(w []rune, suffix string) bool {
+	return strings.HasSuffix(string(w), suffix)
+}
+
+// longestSuffix returns the longest of the suffixes that the word ends with, or an empty string if
+// there is none.
+func longestSuffix(w []rune, suffixes []string) (suffix string) {
+	s := string(w)
+	for _, candidate := range suffixes {
+		if len(candidate) > len(suffix) && strings.HasSuffix(s, candidate) {
+			suffix = candidate
+		}
+	}
+	return
+}
+
+// suffixStart returns the rune offset where a suffix of the word starts.
+func suffixStart(w []rune, suffix string) int {
+	return len(w) - utf8.RuneCountInString(suffix)
+}
+
+// replaceSuffix replaces a suffix that the word is known to end with.
+func replaceSuffix(w []rune, suffix, replacement string) []rune {
+	return append(w[:suffixStart(w, suffix):suffixStart(w, suffix)], []rune(replacement)...)
+}
+
+// regionAfter returns the offset of the region after the first non-vowel following a vowel at or
+// after an offset, which is how the R1 and R2 regions of the Snowball algorithms are defined.
+func regionAfter(w []rune, start int, isVowel func(r rune) bool) int {
+	for i := start + 1; i < len(w); i++ {
+		if !isVowel(w[i]) && isVowel(w[i-1]) {
+			return i + 1
+		}
+	}
+	return len(w)
+}
+
+// containsVowel returns whether any rune of the word is a vowel.
+func containsVowel(w []rune, isVowel func(r rune) bool) bool {
+	for _, r := range w {
+		if isVowel(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// English (Porter2)
+
+var (
+	englishExceptions = map[string]string{
+		"skis": "ski", "skies": "sky", "dying": "die", "lying": "lie", "tying": "tie",
+		"idly": "idl", "gently": "gentl", "ugly": "ugli", "early": "earli", "only": "onli",
+		"singly": "singl", "sky": "sky", "news": "news", "howe": "howe", "atlas": "atlas",
+		"cosmos": "cosmos", "bias": "bias", "andes": "andes",
+	}
+	englishExceptionsAfterStep1a = MakeStringSet([]string{
+		"inning", "outing", "canning", "herring", "earring", "proceed", "exceed", "succeed",
+	})
+	englishStep2Suffixes = map[string]string{
+		"tional": "tion", "enci": "ence", "anci": "ance", "abli": "able", "entli": "ent",
+		"izer": "ize", "ization": "ize", "ational": "ate", "ation": "ate", "ator": "ate",
+		"alism": "al", "aliti": "al", "alli": "al", "fulness": "ful", "ousli": "ous",
+		"ousness": "ous", "iveness": "ive", "iviti": "ive", "biliti": "ble", "bli": "ble",
+		"ogi": "og", "fulli": "ful", "lessli": "less", "li": "",
+	}
+	englishStep3Suffixes = map[string]string{
+		"tional": "tion", "ational": "ate", "alize": "al", "icate": "ic", "iciti": "ic",
+		"ical": "ic", "ful": "", "ness": "", "ative": "",
+	}
+	englishStep4Suffixes = []string{
+		"al", "ance", "ence", "er", "ic", "able", "ible", "ant", "ement", "ment", "ent", "ism",
+		"ate", "iti", "ous", "ive", "ize", "ion",
+	}
+)
+
+func isEnglishVowel(r rune) bool {
+	return strings.ContainsRune("aeiouy", r)
+}
+
+// endsWithShortSyllable returns whether a word ends with a vowel followed by a non-vowel other than
+// w, x, or Y that is itself preceded by a non-vowel, or is a vowel followed by a non-vowel.
+func endsWithShortSyllable(w []rune) bool {
+	n := len(w)
+	switch {
+	case n == 2:
+		return isEnglishVowel(w[0]) && !isEnglishVowel(w[1])
+	case n > 2:
+		return !isEnglishVowel(w[n-3]) && isEnglishVowel(w[n-2]) && !isEnglishVowel(w[n-1]) &&
+			!strings.ContainsRune("wxY", w[n-1])
+	}
+	return false
+}
+
+func mapKeys(m map[string]string) (keys []string) {
+	for key := range m {
+		keys = append(keys, key)
+	}
+	return
+}
+
+// stemEnglish implements the Porter2 stemming algorithm.
+func stemEnglish(word string) string {
+	w := []rune(word)
+	if len(w) <= 2 {
+		return word
+	}
+	if w[0] == '\'' {
+		w = w[1:]
+	}
+	if exception, ok := englishExceptions[string(w)]; ok {
+		return exception
+	}
+
+	for i, r := range w {
+		if r == 'y' && (i == 0 || isEnglishVowel(w[i-1])) {
+			w[i] = 'Y'
+		}
+	}
+
+	r1 := regionAfter(w, 0, isEnglishVowel)
+	for _, prefix := range []string{"gener", "commun", "arsen"} {
+		if strings.HasPrefix(string(w), prefix) {
+			r1 = len(prefix)
+		}
+	}
+	r2 := regionAfter(w, r1, isEnglishVowel)
+
+	// Step 0
+	if suffix := longestSuffix(w, []string{"'s'", "'s", "'"}); suffix != "" {
+		w = replaceSuffix(w, suffix, "")
+	}
+
+	// Step 1a
+	switch suffix := longestSuffix(w, []string{"sses", "ied", "ies", "us", "ss", "s"}); suffix {
+	case "sses":
+		w = replaceSuffix(w, suffix, "ss")
+	case "ied", "ies":
+		if len(w) > 4 {
+			w = replaceSuffix(w, suffix, "i")
+		} else {
+			w = replaceSuffix(w, suffix, "ie")
+		}
+	case "s":
+		if containsVowel(w[:len(w)-2], isEnglishVowel) {
+			w = replaceSuffix(w, suffix, "")
+		}
+	}
+	if englishExceptionsAfterStep1a.Contains(string(w)) {
+		return string(w)
+	}
+
+	// Step 1b
+	switch suffix := longestSuffix(w, []string{"eed", "eedly", "ed", "edly", "ing", "ingly"}); suffix {
+	case "eed", "eedly":
+		if suffixStart(w, suffix) >= r1 {
+			w = replaceSuffix(w, suffix, "ee")
+		}
+	case "ed", "edly", "ing", "ingly":
+		if !containsVowel(w[:suffixStart(w, suffix)], isEnglishVowel) {
+			break
+		}
+		w = replaceSuffix(w, suffix, "")
+
+		switch {
+		case longestSuffix(w, []string{"at", "bl", "iz"}) != "":
+			w = append(w, 'e')
+		case longestSuffix(w, []string{"bb", "dd", "ff", "gg", "mm", "nn", "pp", "rr", "tt"}) != "":
+			w = w[:len(w)-1]
+		case endsWithShortSyllable(w) && r1 >= len(w):
+			w = append(w, 'e')
+		}
+	}
+
+	// Step 1c
+	if n := len(w); n > 2 && (w[n-1] == 'y' || w[n-1] == 'Y') && !isEnglishVowel(w[n-2]) {
+		w[n-1] = 'i'
+	}
+
+	// Step 2
+	if suffix := longestSuffix(w, mapKeys(englishStep2Suffixes)); suffix != "" && suffixStart(w, suffix) >= r1 {
+		start := suffixStart(w, suffix)
+		switch suffix {
+		case "ogi":
+			if start > 0 && w[start-1] == 'l' {
+				w = replaceSuffix(w, suffix, "og")
+			}
+		case "li":
+			if start > 0 && strings.ContainsRune("cdeghkmnrt", w[start-1]) {
+				w = replaceSuffix(w, suffix, "")
+			}
+		default:
+			w = replaceSuffix(w, suffix, englishStep2Suffixes[suffix])
+		}
+	}
+
+	// Step 3
+	if suffix := longestSuffix(w, mapKeys(englishStep3Suffixes)); suffix != "" && suffixStart(w, suffix) >= r1 {
+		if suffix != "ative" || suffixStart(w, suffix) >= r2 {
+			w = replaceSuffix(w, suffix, englishStep3Suffixes[suffix])
+		}
+	}
+
+	// Step 4
+	if suffix := longestSuffix(w, englishStep4Suffixes); suffix != "" && suffixStart(w, suffix) >= r2 {
+		start := suffixStart(w, suffix)
+		if suffix != "ion" || (start > 0 && (w[start-1] == 's' || w[start-1] == 't')) {
+			w = replaceSuffix(w, suffix, "")
+		}
+	}
+
+	// Step 5
+	if n := len(w); n > 0 {
+		switch {
+		case w[n-1] == 'e' && (n-1 >= r2 || (n-1 >= r1 && !endsWithShortSyllable(w[:n-1]))):
+			w = w[:n-1]
+		case w[n-1] == 'l' && n-1 >= r2 && n > 1 && w[n-2] == 'l':
+			w = w[:n-1]
+		}
+	}
+
+	return strings.ReplaceAll(string(w), "Y", "y")
+}
+
+// Indonesian
+
+func isIndonesianVowel(r rune) bool {
+	return strings.ContainsRune("aeiou", r)
+}
+
+// affixRule is a prefix or suffix of the Indonesian stemmer. The affix is replaced if its condition
+// holds, otherwise shorter affixes are tried.
+type affixRule struct {
+	Affix       string
+	Replacement string
+	Prefix      int // Kind of prefix removed, which restricts the suffixes that can be removed
+	Condition   func(w []rune) bool
+}
+
+var (
+	indonesianFirstOrderPrefixes = []affixRule{
+		{Affix: "meny", Replacement: "s", Prefix: 1, Condition: followedByIndonesianVowel(4)},
+		{Affix: "peny", Replacement: "s", Prefix: 3, Condition: followedByIndonesianVowel(4)},
+		{Affix: "meng", Prefix: 1},
+		{Affix: "peng", Prefix: 3},
+		{Affix: "men", Prefix: 1},
+		{Affix: "pen", Prefix: 3},
+		{Affix: "mem", Replacement: "p", Prefix: 1, Condition: followedByIndonesianVowel(3)},
+		{Affix: "pem", Replacement: "p", Prefix: 3, Condition: followedByIndonesianVowel(3)},
+		{Affix: "mem", Prefix: 1},
+		{Affix: "pem", Prefix: 3},
+		{Affix: "ter", Prefix: 1},
+		{Affix: "di", Prefix: 1},
+		{Affix: "me", Prefix: 1},
+		{Affix: "ke", Prefix: 3},
+	}
+	indonesianSecondOrderPrefixes = []affixRule{
+		{Affix: "pelajar", Replacement: "ajar"},
+		{Affix: "belajar", Replacement: "ajar", Prefix: 4},
+		{Affix: "per", Prefix: 2},
+		{Affix: "ber", Prefix: 4},
+		{Affix: "pe", Prefix: 2},
+		{Affix: "be", Prefix: 4, Condition: func(w []rune) bool {
+			// The prefix is only removed when followed by a non-vowel and "er", as in "bekerja"
+			return len(w) > 4 && !isIndonesianVowel(w[2]) && w[3] == 'e' && w[4] == 'r'
+		}},
+	}
+)
+
+func followedByIndonesianVowel(offset int) func(w []rune) bool {
+	return func(w []rune) bool {
+		return len(w) > offset && isIndonesianVowel(w[offset])
+	}
+}
+
+// stemIndonesian implements the Snowball Indonesian stemming algorithm, which removes particles,
+// possessive pronouns, suffixes, and up to two prefixes while the word has more than two vowels.
+func stemIndonesian(word string) string {
+	w := []rune(word)
+
+	measure := 0
+	for _, r := range w {
+		if isIndonesianVowel(r) {
+			measure++
+		}
+	}
+	if measure <= 2 {
+		return word
+	}
+
+	prefix := 0
+
+	if suffix := longestSuffix(w, []string{"kah", "lah", "pun"}); suffix != "" {
+		w = replaceSuffix(w, suffix, "")
+		measure--
+	}
+	if measure > 2 {
+		if suffix := longestSuffix(w, []string{"ku", "mu", "nya"}); suffix != "" {
+			w = replaceSuffix(w, suffix, "")
+			measure--
+		}
+	}
+	if measure <= 2 {
+		return string(w)
+	}
+
+	removePrefix := func(rules []affixRule) bool {
+		for _, rule := range rules {
+			if !strings.HasPrefix(string(w), rule.Affix) || (rule.Condition != nil && !rule.Condition(w)) {
+				continue
+			}
+			w = append([]rune(rule.Replacement), w[utf8.RuneCountInString(rule.Affix):]...)
+			if rule.Prefix != 0 {
+				prefix = rule.Prefix
+			}
+			measure--
+			return true
+		}
+		return false
+	}
+	removeSuffix := func() {
+		suffixes := []affixRule{
+			{Affix: "kan", Condition: func(w []rune) bool { return prefix != 3 && prefix != 2 }},
+			{Affix: "an", Condition: func(w []rune) bool { return prefix != 1 }},
+			{Affix: "i", Condition: func(w []rune) bool { return prefix <= 2 && !hasSuffixRunes(w, "si") }},
+		}
+		for _, rule := range suffixes {
+			if hasSuffixRunes(w, rule.Affix) && rule.Condition(w) {
+				w = replaceSuffix(w, rule.Affix, "")
+				measure--
+				return
+			}
+		}
+	}
+
+	if removePrefix(indonesianFirstOrderPrefixes) {
+		if measure > 2 {
+			removeSuffix()
+		}
+		if measure > 2 {
+			removePrefix(indonesianSecondOrderPrefixes)
+		}
+	} else {
+		removePrefix(indonesianSecondOrderPrefixes)
+		if measure > 2 {
+			removeSuffix()
+		}
+	}
+
+	return string(w)
+}
+
+// German
+
+func isGermanVowel(r rune) bool {
+	return strings.ContainsRune("aeiouyäöü", r)
+}
+
+// stemGerman implements the Snowball German stemming algorithm.
+func stemGerman(word string) string {
+	w := []rune(strings.ReplaceAll(word, "ß", "ss"))
+
+	for i := 1; i < len(w)-1; i++ {
+		if isGermanVowel(w[i-1]) && isGermanVowel(w[i+1]) {
+			switch w[i] {
+			case 'u':
+				w[i] = 'U'
+			case 'y':
+				w[i] = 'Y'
+			}
+		}
+	}
+
+	// R1 is adjusted so that the region before it contains at least 3 letters
+	r1 := regionAfter(w, 0, isGermanVowel)
+	r2 := regionAfter(w, r1, isGermanVowel)
+	if r1 < 3 {
+		r1 = 3
+	}
+	if r1 > len(w) {
+		r1 = len(w)
+	}
+
+	// Step 1
+	if suffix := longestSuffix(w, []string{"em", "ern", "er", "e", "en", "es", "s"}); suffix != "" && suffixStart(w, suffix) >= r1 {
+		start := suffixStart(w, suffix)
+		switch suffix {
+		case "s":
+			if start > 0 && strings.ContainsRune("bdfghklmnrt", w[start-1]) {
+				w = replaceSuffix(w, suffix, "")
+			}
+		case "e", "en", "es":
+			w = replaceSuffix(w, suffix, "")
+			if hasSuffixRunes(w, "niss") {
+				w = w[:len(w)-1]
+			}
+		default:
+			w = replaceSuffix(w, suffix, "")
+		}
+	}
+
+	// Step 2
+	if suffix := longestSuffix(w, []string{"en", "er", "est", "st"}); suffix != "" && suffixStart(w, suffix) >= r1 {
+		start := suffixStart(w, suffix)
+		if suffix != "st" || (start > 3 && strings.ContainsRune("bdfghklmnt", w[start-1])) {
+			w = replaceSuffix(w, suffix, "")
+		}
+	}
+
+	// Step 3
+	precededByE := func(start int) bool {
+		return start > 0 && w[start-1] == 'e'
+	}
+	if suffix := longestSuffix(w, []string{"end", "ung", "ig", "ik", "isch", "lich", "heit", "keit"}); suffix != "" && suffixStart(w, suffix) >= r2 {
+		start := suffixStart(w, suffix)
+		switch suffix {
+		case "end", "ung":
+			w = replaceSuffix(w, suffix, "")
+			if hasSuffixRunes(w, "ig") && suffixStart(w, "ig") >= r2 && !precededByE(suffixStart(w, "ig")) {
+				w = replaceSuffix(w, "ig", "")
+			}
+		case "ig", "ik", "isch":
+			if !precededByE(start) {
+				w = replaceSuffix(w, suffix, "")
+			}
+		case "lich", "heit":
+			w = replaceSuffix(w, suffix, "")
+			if preceding := longestSuffix(w, []string{"er", "en"}); preceding != "" && suffixStart(w, preceding) >= r1 {
+				w = replaceSuffix(w, preceding, "")
+			}
+		case "keit":
+			w = replaceSuffix(w, suffix, "")
+			if preceding := longestSuffix(w, []string{"lich", "ig"}); preceding != "" && suffixStart(w, preceding) >= r2 {
+				w = replaceSuffix(w, preceding, "")
+			}
+		}
+	}
+
+	return strings.NewReplacer("U", "u", "Y", "y", "ä", "a", "ö", "o", "ü", "u").Replace(string(w))
+}
+
+// Spanish
+
+var (
+	spanishPronouns = []string{
+		"me", "se", "sa", "sele", "selo", "selas", "seles", "selos", "la", "le", "lo", "las", "les",
+		"los", "nos",
+	}
+	spanishStep1Suffixes = []string{
+		"anza", "anzas", "ico", "ica", "icos", "icas", "ismo", "ismos", "able", "ables", "ible",
+		"ibles", "ista", "istas", "oso", "osa", "osos", "osas", "amiento", "amientos", "imiento",
+		"imientos", "adora", "ador", "ación", "adoras", "adores", "aciones", "ante", "antes",
+		"ancia", "ancias", "logía", "logías", "ución", "uciones", "encia", "encias", "amente",
+		"mente", "idad", "idades", "iva", "ivo", "ivas", "ivos",
+	}
+	spanishStep2aSuffixes = []string{
+		"ya", "ye", "yan", "yen", "yeron", "yendo", "yo", "yó", "yas", "yes", "yais", "yamos",
+	}
+	spanishStep2bSuffixes = []string{
+		"en", "es", "éis", "emos", "arían", "arías", "arán", "arás", "aríais", "aría", "aréis",
+		"aríamos", "aremos", "ará", "aré", "erían", "erías", "erán", "erás", "eríais", "ería",
+		"eréis", "eríamos", "eremos", "erá", "eré", "irían", "irías", "irán", "irás", "iríais",
+		"iría", "iréis", "iríamos", "iremos", "irá", "iré", "aba", "ada", "ida", "ía", "ara",
+		"iera", "ad", "ed", "id", "ase", "iese", "aste", "iste", "an", "aban", "ían", "aran",
+		"ieran", "asen", "iesen", "aron", "ieron", "ado", "ido", "ando", "iendo", "ió", "ar", "er",
+		"ir", "as", "abas", "adas", "idas", "ías", "aras", "ieras", "ases", "ieses", "ís", "áis",
+		"abais", "íais", "arais", "ierais", "aseis", "ieseis", "asteis", "isteis", "ados", "idos",
+		"amos", "ábamos", "íamos", "imos", "áramos", "iéramos", "iésemos", "ásemos",
+	}
+	spanishAccents = strings.NewReplacer("á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u")
+)
+
+func isSpanishVowel(r rune) bool {
+	return strings.ContainsRune("aeiouáéíóúü", r)
+}
+
+// stemSpanish implements the Snowball Spanish stemming algorithm.
+func stemSpanish(word string) string {
+	w := []rune(word)
+
+	rv := len(w)
+	switch {
+	case len(w) < 2:
+	case !isSpanishVowel(w[1]):
+		for i := 2; i < len(w); i++ {
+			if isSpanishVowel(w[i]) {
+				rv = i + 1
+				break
+			}
+		}
+	case isSpanishVowel(w[0]):
+		for i := 2; i < len(w); i++ {
+			if !isSpanishVowel(w[i]) {
+				rv = i + 1
+				break
+			}
+		}
+	default:
+		rv = 3
+	}
+	if rv > len(w) {
+		rv = len(w)
+	}
+	r1 := regionAfter(w, 0, isSpanishVowel)
+	r2 := regionAfter(w, r1, isSpanishVowel)
+
+	inRV := func(suffix string) bool { return suffixStart(w, suffix) >= rv }
+	inR1 := func(suffix string) bool { return suffixStart(w, suffix) >= r1 }
+	inR2 := func(suffix string) bool { return suffixStart(w, suffix) >= r2 }
+
+	// Step 0: attached pronouns
+	if pronoun := longestSuffix(w, spanishPronouns); pronoun != "" && inRV(pronoun) {
+		stem := replaceSuffix(append([]rune{}, w...), pronoun, "")
+		switch ending := longestSuffix(stem, []string{"iéndo", "ándo", "ár", "ér", "ír", "ando", "iendo", "ar", "er", "ir", "yendo"}); {
+		case ending == "":
+		case suffixStart(stem, ending) < rv:
+		case ending == "yendo" && !hasSuffixRunes(stem, "uyendo"):
+		default:
+			w = append([]rune(string(stem[:suffixStart(stem, ending)])), []rune(spanishAccents.Replace(ending))...)
+		}
+	}
+
+	// Step 1: standard suffixes
+	removed := false
+	if suffix := longestSuffix(w, spanishStep1Suffixes); suffix != "" {
+		removeIfInR2 := func(preceding ...string) {
+			if p := longestSuffix(w, preceding); p != "" && inR2(p) {
+				w = replaceSuffix(w, p, "")
+			}
+		}
+
+		switch suffix {
+		case "adora", "ador", "ación", "adoras", "adores", "aciones", "ante", "antes", "ancia", "ancias":
+			if inR2(suffix) {
+				w, removed = replaceSuffix(w, suffix, ""), true
+				removeIfInR2("ic")
+			}
+		case "logía", "logías":
+			if inR2(suffix) {
+				w, removed = replaceSuffix(w, suffix, "log"), true
+			}
+		case "ución", "uciones":
+			if inR2(suffix) {
+				w, removed = replaceSuffix(w, suffix, "u"), true
+			}
+		case "encia", "encias":
+			if inR2(suffix) {
+				w, removed = replaceSuffix(w, suffix, "ente"), true
+			}
+		case "amente":
+			if inR1(suffix) {
+				w, removed = replaceSuffix(w, suffix, ""), true
+				if hasSuffixRunes(w, "iv") && inR2("iv") {
+					w = replaceSuffix(w, "iv", "")
+					removeIfInR2("at")
+				} else {
+					removeIfInR2("os", "ic", "ad")
+				}
+			}
+		case "mente":
+			if inR2(suffix) {
+				w, removed = replaceSuffix(w, suffix, ""), true
+				removeIfInR2("ante", "able", "ible")
+			}
+		case "idad", "idades":
+			if inR2(suffix) {
+				w, removed = replaceSuffix(w, suffix, ""), true
+				removeIfInR2("abil", "ic", "iv")
+			}
+		case "iva", "ivo", "ivas", "ivos":
+			if inR2(suffix) {
+				w, removed = replaceSuffix(w, suffix, ""), true
+				removeIfInR2("at")
+			}
+		default:
+			if inR2(suffix) {
+				w, removed = replaceSuffix(w, suffix, ""), true
+			}
+		}
+	}
+
+	// Step 2: verb suffixes
+	if !removed {
+		if suffix := longestSuffix(w, spanishStep2aSuffixes); suffix != "" && inRV(suffix) && hasSuffixRunes(w, "u"+suffix) {
+			w = replaceSuffix(w, suffix, "")
+		} else if suffix := longestSuffix(w, spanishStep2bSuffixes); suffix != "" && inRV(suffix) {
+			w = replaceSuffix(w, suffix, "")
+			if (suffix == "en" || suffix == "es" || suffix == "éis" || suffix == "emos") && hasSuffixRunes(w, "gu") {
+				w = w[:len(w)-1]
+			}
+		}
+	}
+
+	// Step 3: residual suffixes
+	if suffix := longestSuffix(w, []string{"os", "a", "o", "á", "í", "ó", "e", "é"}); suffix != "" && inRV(suffix) {
+		w = replaceSuffix(w, suffix, "")
+		if (suffix == "e" || suffix == "é") && hasSuffixRunes(w, "gu") && inRV("u") {
+			w = w[:len(w)-1]
+		}
+	}
+
+	return spanishAccents.Replace(string(w))
+}
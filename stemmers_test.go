@@ -0,0 +1,93 @@
+package folder
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStemEnglish(t *testing.T) {
+	words := map[string]string{
+		"caresses":    "caress",
+		"flies":       "fli",
+		"agreed":      "agre",
+		"humbled":     "humbl",
+		"hoping":      "hope",
+		"hopping":     "hop",
+		"itemization": "item",
+		"sensational": "sensat",
+		"generously":  "generous",
+		"drawing":     "draw",
+		"draws":       "draw",
+		"skies":       "sky",
+	}
+	for word, stem := range words {
+		assert.Equal(t, stem, stemEnglish(word), word)
+	}
+}
+
+func TestStemIndonesian(t *testing.T) {
+	words := map[string]string{
+		"makanan":     "makan",
+		"membaca":     "baca",
+		"menyapu":     "sapu",
+		"pembangunan": "bangun",
+		"bekerja":     "kerja",
+		"pelajaran":   "ajar",
+		"bukunya":     "buku",
+		"minumlah":    "minum",
+		"buku":        "buku",
+	}
+	for word, stem := range words {
+		assert.Equal(t, stem, stemIndonesian(word), word)
+	}
+}
+
+func TestStemGerman(t *testing.T) {
+	words := map[string]string{
+		"aufeinanderfolgenden": "aufeinanderfolg",
+		"häuser":               "haus",
+		"freundlichkeit":       "freundlich",
+		"möglichkeiten":        "moglich",
+		"bedeutung":            "bedeut",
+	}
+	for word, stem := range words {
+		assert.Equal(t, stem, stemGerman(word), word)
+	}
+}
+
+func TestStemSpanish(t *testing.T) {
+	words := map[string]string{
+		"chicas":       "chic",
+		"canciones":    "cancion",
+		"rápidamente":  "rapid",
+		"nacionalidad": "nacional",
+		"bebiéndolo":   "beb",
+		"hablamos":     "habl",
+	}
+	for word, stem := range words {
+		assert.Equal(t, stem, stemSpanish(word), word)
+	}
+}
+
+func TestStemmerTokenFilter(t *testing.T) {
+	_, err := NewStemmerTokenFilter("klingon")
+	assert.True(t, errors.Is(err, ErrUnknownLanguage))
+
+	index := New()
+	err = index.AddAnalyzer("english", AnalyzerConfig{
+		Tokenizer: ComponentConfig{Type: "unicode"},
+		TokenFilters: []ComponentConfig{
+			{Type: "lowercase"},
+			{Type: "stop"},
+			{Type: "stemmer", Params: []string{"english"}},
+		},
+	})
+	assert.Nil(t, err)
+	index.SetDefaultAnalyzer("english")
+
+	index.IndexWithID(map[string]interface{}{"title": "She draws landscapes"}, "1")
+	res, _ := index.Search("Drawing")
+	assert.Equal(t, 1, res.Count)
+}
@@ -0,0 +1,113 @@
+package folder
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// NoStopWords is the stop filter parameter that disables stop word removal, e.g. for fields such as
+// band names where "The Who" should not lose "the".
+const NoStopWords = "_none_"
+
+// stopWordLists contains the bundled stop word lists by language. They can be used as stop filter
+// parameters by surrounding their language with underscores, e.g. "_indonesian_".
+var stopWordLists = map[string][]string{
+	"english": stopWords,
+	"indonesian": {
+		"ada", "adalah", "agar", "akan", "aku", "anda", "antara", "apa", "apakah", "atau", "bagi",
+		"bahwa", "banyak", "begitu", "belum", "bisa", "dalam", "dan", "dapat", "dari", "demikian",
+		"dengan", "di", "dia", "hal", "hanya", "harus", "ia", "ini", "itu", "jika", "juga", "kami",
+		"kamu", "karena", "ke", "kepada", "ketika", "kita", "lagi", "lain", "lalu", "maka", "masih",
+		"mereka", "namun", "oleh", "pada", "para", "saat", "saja", "sangat", "saya", "se", "sebagai",
+		"sebelum", "sedang", "sehingga", "sejak", "semua", "sendiri", "setelah", "seperti",
+		"sudah", "tapi", "telah", "tentang", "tersebut", "tetapi", "untuk", "yaitu", "yang",
+	},
+	"german": {
+		"aber", "alle", "als", "also", "am", "an", "auch", "auf", "aus", "bei", "bin", "bis", "bist",
+		"da", "damit", "dann", "das", "dass", "dein", "dem", "den", "der", "des", "die", "dies",
+		"dir", "doch", "du", "durch", "ein", "eine", "einem", "einen", "einer", "eines", "er", "es",
+		"euer", "für", "hat", "hatte", "ich", "ihr", "im", "in", "ist", "ja", "kann", "kein", "man",
+		"mein", "mit", "nach", "nicht", "noch", "nur", "ob", "oder", "ohne", "sich", "sie", "sind",
+		"so", "um", "und", "uns", "unter", "vom", "von", "vor", "war", "was", "weil", "wenn", "wer",
+		"wie", "wir", "wird", "zu", "zum", "zur",
+	},
+	"spanish": {
+		"a", "al", "algo", "como", "con", "contra", "cual", "cuando", "de", "del", "desde", "donde",
+		"durante", "e", "el", "ella", "ellos", "en", "entre", "era", "es", "esa", "ese", "eso",
+		"esta", "este", "esto", "fue", "ha", "hay", "la", "las", "le", "les", "lo", "los", "más",
+		"me", "mi", "muy", "nada", "ni", "no", "nos", "o", "otra", "otro", "para", "pero", "por",
+		"porque", "que", "qué", "se", "sin", "sobre", "su", "sus", "también", "te", "tu", "un",
+		"una", "uno", "y", "ya", "yo",
+	},
+	"japanese": {
+		"あそこ", "あっ", "あの", "あり", "ある", "い", "いう", "いる", "う", "うち", "え", "お",
+		"および", "おり", "か", "かつて", "から", "が", "き", "ここ", "こと", "この", "これ",
+		"これら", "さ", "さらに", "し", "しかし", "する", "ず", "せ", "せる", "そこ", "そして",
+		"その", "その他", "それ", "それぞれ", "た", "たち", "ため", "たり", "だ", "だっ", "つ",
+		"て", "で", "でき", "できる", "です", "では", "でも", "と", "という", "といった", "とき",
+		"ところ", "として", "とともに", "とも", "と共に", "な", "ない", "なお", "なかっ", "ながら",
+		"なく", "なっ", "など", "なら", "なり", "なる", "に", "において", "における", "について",
+		"にて", "によって", "により", "による", "に対して", "に対する", "に関する", "の", "ので",
+		"のみ", "は", "ば", "へ", "ほか", "ほとんど", "ほど", "ます", "また", "または", "まで",
+		"も", "もの", "ものの", "や", "よう", "より", "ら", "られ", "られる", "れ", "れる", "を",
+		"ん", "及び", "特に",
+	},
+}
+
+// StopWordLanguages returns the sorted languages of the bundled stop word lists.
+func StopWordLanguages() (languages []string) {
+	for language := range stopWordLists {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return
+}
+
+// StopTokenFilter removes tokens whose term is one of its stop words.
+type StopTokenFilter struct {
+	Words StringSet
+}
+
+// newStopTokenFilter creates a stop filter from its parameters. Each parameter is either a stop
+// word, the name of a bundled list surrounded by underscores such as "_english_", or NoStopWords.
+// The English list is used if there is no parameter.
+func newStopTokenFilter(params []string) (TokenFilter, error) {
+	if len(params) == 0 {
+		params = []string{"_english_"}
+	}
+
+	words := MakeStringSet(nil)
+	for _, param := range params {
+		if param == NoStopWords {
+			continue
+		}
+		if len(param) < 3 || !strings.HasPrefix(param, "_") || !strings.HasSuffix(param, "_") {
+			words.Add(param)
+			continue
+		}
+
+		language := strings.Trim(param, "_")
+		list, ok := stopWordLists[language]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownLanguage, language)
+		}
+		words.Union(MakeStringSet(list))
+	}
+	return NewStopTokenFilter(words.List()), nil
+}
+
+// NewStopTokenFilter creates a stop filter that removes the given words.
+func NewStopTokenFilter(words []string) *StopTokenFilter {
+	return &StopTokenFilter{Words: MakeStringSet(words)}
+}
+
+// Filter removes the tokens that are stop words.
+func (filter *StopTokenFilter) Filter(tokens []Token) (filteredTokens []Token) {
+	for _, token := range tokens {
+		if !filter.Words.Contains(token.Term) {
+			filteredTokens = append(filteredTokens, token)
+		}
+	}
+	return
+}
@@ -0,0 +1,498 @@
+package folder
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// StructTagKey is the key of the struct tags that tell how the fields of structs are indexed, e.g.
+// `folder:"status,keyword,omitempty"`. The first part of a tag is the name of the field in the
+// document, the struct field name is used if it is empty, and "-" leaves the field out. The other
+// parts are either "omitempty", which leaves the field out when it has a zero value, or a field type.
+const StructTagKey = "folder"
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// numberTypes contains the types that named number types are converted into.
+var numberTypes = map[reflect.Kind]reflect.Type{
+	reflect.Int:     reflect.TypeOf(int(0)),
+	reflect.Int8:    reflect.TypeOf(int8(0)),
+	reflect.Int16:   reflect.TypeOf(int16(0)),
+	reflect.Int32:   reflect.TypeOf(int32(0)),
+	reflect.Int64:   reflect.TypeOf(int64(0)),
+	reflect.Uint:    reflect.TypeOf(uint(0)),
+	reflect.Uint8:   reflect.TypeOf(uint8(0)),
+	reflect.Uint16:  reflect.TypeOf(uint16(0)),
+	reflect.Uint32:  reflect.TypeOf(uint32(0)),
+	reflect.Uint64:  reflect.TypeOf(uint64(0)),
+	reflect.Float32: reflect.TypeOf(float32(0)),
+	reflect.Float64: reflect.TypeOf(float64(0)),
+}
+
+// structTag is a parsed struct tag of a struct field.
+type structTag struct {
+	Name      string
+	Type      FieldType
+	OmitEmpty bool
+}
+
+// parseStructTag parses the struct tag of a struct field. Fields that are left out have no name.
+func parseStructTag(field reflect.StructField) (tag structTag, err error) {
+	parts := strings.Split(field.Tag.Get(StructTagKey), ",")
+	if parts[0] == "-" && len(parts) == 1 {
+		return
+	}
+
+	tag.Name = parts[0]
+	if tag.Name == "" {
+		tag.Name = field.Name
+	}
+
+	for _, option := range parts[1:] {
+		switch {
+		case option == "omitempty":
+			tag.OmitEmpty = true
+		case containsFieldType(fieldTypes, FieldType(option)):
+			tag.Type = FieldType(option)
+		default:
+			err = fmt.Errorf("%w: %s: %s", ErrUnknownFieldType, field.Name, option)
+			return
+		}
+	}
+	return
+}
+
+// IndexStruct indexes a struct, or a pointer to a struct, into the index. It is equivalent to Index
+// with the document that the struct is converted into.
+func (index *Index) IndexStruct(v interface{}) (documentID string, err error) {
+	documentID = index.nextDocumentID()
+	err = index.updateStruct(documentID, v)
+	return
+}
+
+// IndexStructWithID indexes a struct into the index but with user-specified document ID.
+func (index *Index) IndexStructWithID(v interface{}, desiredDocumentID string) (documentID string, err error) {
+	documentID = desiredDocumentID
+	err = index.updateStruct(documentID, v)
+	return
+}
+
+func (index *Index) updateStruct(documentID string, v interface{}) (err error) {
+	document, mappings, err := documentFromStruct(v)
+	if err != nil {
+		return
+	}
+
+	// Types given by struct tags are added to the mapping unless the fields are mapped already
+	added := make(map[string]FieldMapping)
+	for field, mapping := range mappings {
+		if _, ok := index.Mapping.Fields[field]; ok {
+			continue
+		}
+
+		err = index.validateFieldMapping(field, mapping)
+		if err != nil {
+			return
+		}
+		added[field] = mapping
+	}
+
+	// The document is validated with the added mappings before they are added so that the mapping
+	// is left alone when the document is rejected
+	inferred := make(map[string]FieldMapping, len(added))
+	for field, mapping := range added {
+		inferred[field] = mapping
+	}
+	err = index.mapValue("", index.withLanguages(document, index.detectLanguages(document)), inferred)
+	if err != nil {
+		return
+	}
+
+	for field, mapping := range added {
+		debug("  Add field mapping", field, mapping.Type)
+		index.setFieldMapping(field, mapping)
+	}
+
+	err = index.Update(documentID, document)
+	return
+}
+
+// documentFromStruct converts a struct into a document along with the mappings of the fields whose
+// struct tags have a field type.
+func documentFromStruct(v interface{}) (document map[string]interface{}, mappings map[string]FieldMapping, err error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct || rv.Type() == timeType {
+		err = fmt.Errorf("%w: %T", ErrInvalidStruct, v)
+		return
+	}
+
+	mappings = make(map[string]FieldMapping)
+	document = make(map[string]interface{})
+	err = fieldsFromStruct("", rv, document, mappings)
+	return
+}
+
+// fieldsFromStruct adds the exported fields of a struct to an object. The fields of exported embedded
+// structs without a name in their struct tags are added as if they were fields of the struct itself.
+func fieldsFromStruct(parentField string, rv reflect.Value, object map[string]interface{}, mappings map[string]FieldMapping) (err error) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		var tag structTag
+
+		structField := rt.Field(i)
+		if structField.PkgPath != "" {
+			continue
+		}
+
+		tag, err = parseStructTag(structField)
+		if err != nil {
+			return
+		}
+		if tag.Name == "" {
+			continue
+		}
+
+		fv := rv.Field(i)
+		if structField.Anonymous && structField.Tag.Get(StructTagKey) == "" {
+			for fv.Kind() == reflect.Ptr && !fv.IsNil() {
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct && fv.Type() != timeType {
+				err = fieldsFromStruct(parentField, fv, object, mappings)
+				if err != nil {
+					return
+				}
+				continue
+			}
+		}
+
+		if tag.OmitEmpty && isEmptyValue(fv) {
+			continue
+		}
+
+		field := tag.Name
+		if parentField != "" {
+			field = parentField + "." + tag.Name
+		}
+		if tag.Type != "" {
+			mappings[field] = FieldMapping{Type: tag.Type}
+		}
+
+		object[tag.Name], err = valueFromStructField(field, fv, mappings)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// valueFromStructField converts the value of a struct field into a document value. Pointers become
+// the values they point to, structs become objects, and slices become arrays, except for slices of
+// strings and of floats which are kept as they are.
+func valueFromStructField(field string, rv reflect.Value, mappings map[string]FieldMapping) (v interface{}, err error) {
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return
+		}
+		rv = rv.Elem()
+	}
+
+	if rv.Type() == timeType {
+		return rv.Interface().(time.Time), nil
+	}
+
+	switch rv.Kind() {
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Bool:
+		return rv.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		// Named number types become the types that they are based on
+		return rv.Convert(numberTypes[rv.Kind()]).Interface(), nil
+	case reflect.Struct:
+		object := make(map[string]interface{})
+		err = fieldsFromStruct(field, rv, object, mappings)
+		return object, err
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			break
+		}
+		if rv.IsNil() {
+			return
+		}
+
+		object := make(map[string]interface{}, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			name := iter.Key().String()
+			object[name], err = valueFromStructField(field+"."+name, iter.Value(), mappings)
+			if err != nil {
+				return
+			}
+		}
+		return object, nil
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return
+		}
+
+		switch rv.Type().Elem().Kind() {
+		case reflect.Uint8:
+			if rv.Kind() == reflect.Slice {
+				return string(rv.Bytes()), nil
+			}
+		case reflect.String:
+			values := make([]string, rv.Len())
+			for i := range values {
+				values[i] = rv.Index(i).String()
+			}
+			return values, nil
+		case reflect.Float32, reflect.Float64:
+			values := make([]float64, rv.Len())
+			for i := range values {
+				values[i] = rv.Index(i).Float()
+			}
+			return values, nil
+		}
+
+		values := make([]interface{}, rv.Len())
+		for i := range values {
+			values[i], err = valueFromStructField(field, rv.Index(i), mappings)
+			if err != nil {
+				return
+			}
+		}
+		return values, nil
+	}
+
+	err = fmt.Errorf("%w: %s has unsupported type %s", ErrInvalidStruct, field, rv.Type())
+	return
+}
+
+// isEmptyValue returns whether a value is left out by the omitempty option, which is the case for
+// zero values and empty slices and maps.
+func isEmptyValue(rv reflect.Value) bool {
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Map:
+		return rv.Len() == 0
+	}
+	return rv.IsZero()
+}
+
+// Decode stores the document of a hit into the value pointed to by v, which is usually a pointer to
+// a struct with the same struct tags as the structs that were indexed.
+func (hit *Hit) Decode(v interface{}) (err error) {
+	return decodeDocumentInto(hit.Source, v)
+}
+
+// FetchInto fetches a document with specific ID just like Fetch and stores it into the value pointed
+// to by v. ErrDocumentNotFound is returned if there is no such document.
+func (index *Index) FetchInto(documentID string, v interface{}) (err error) {
+	return index.FetchIntoContext(context.Background(), documentID, v)
+}
+
+// FetchIntoContext fetches a document just like FetchInto but stops reading the shard when the
+// context is done.
+func (index *Index) FetchIntoContext(ctx context.Context, documentID string, v interface{}) (err error) {
+	document, err := index.FetchContext(ctx, documentID)
+	if err != nil {
+		return
+	}
+	if document == nil {
+		return fmt.Errorf("%w: %s", ErrDocumentNotFound, documentID)
+	}
+
+	err = decodeDocumentInto(document, v)
+	return
+}
+
+// decodeDocumentInto stores a document into the value pointed to by v.
+func decodeDocumentInto(document map[string]interface{}, v interface{}) (err error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("%w: %T is not a pointer", ErrInvalidStruct, v)
+	}
+	return assignValue("", rv.Elem(), document)
+}
+
+// assignValue stores a document value into a Go value. Numbers, booleans, and dates that were stored
+// as strings are parsed, and missing fields are left alone.
+func assignValue(field string, rv reflect.Value, v interface{}) (err error) {
+	invalid := func() error {
+		return fmt.Errorf("%w: %s: cannot decode %T into %s", ErrInvalidFieldValue, field, v, rv.Type())
+	}
+
+	if v == nil {
+		rv.Set(reflect.Zero(rv.Type()))
+		return
+	}
+
+	if rv.Type() == timeType {
+		t, ok := dateValue(v)
+		if !ok {
+			return invalid()
+		}
+		rv.Set(reflect.ValueOf(t))
+		return
+	}
+
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		return assignValue(field, rv.Elem(), v)
+	case reflect.Interface:
+		if rv.NumMethod() > 0 {
+			return invalid()
+		}
+		rv.Set(reflect.ValueOf(v))
+	case reflect.String:
+		s, ok := v.(string)
+		if !ok {
+			return invalid()
+		}
+		rv.SetString(s)
+	case reflect.Bool:
+		b, ok := booleanValue(v)
+		if !ok {
+			return invalid()
+		}
+		rv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := integerValue(v)
+		if !ok || rv.OverflowInt(i) {
+			return invalid()
+		}
+		rv.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, ok := v.(uint64)
+		if !ok {
+			var i int64
+			i, ok = integerValue(v)
+			ok = ok && i >= 0
+			u = uint64(i)
+		}
+		if !ok || rv.OverflowUint(u) {
+			return invalid()
+		}
+		rv.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, ok := numberValue(v)
+		if !ok {
+			return invalid()
+		}
+		rv.SetFloat(f)
+	case reflect.Struct:
+		object, ok := v.(map[string]interface{})
+		if !ok {
+			return invalid()
+		}
+		return assignStructFields(field, rv, object)
+	case reflect.Map:
+		object, ok := v.(map[string]interface{})
+		if !ok || rv.Type().Key().Kind() != reflect.String {
+			return invalid()
+		}
+
+		if rv.IsNil() {
+			rv.Set(reflect.MakeMapWithSize(rv.Type(), len(object)))
+		}
+		for name, v := range object {
+			value := reflect.New(rv.Type().Elem()).Elem()
+			err = assignValue(field+"."+name, value, v)
+			if err != nil {
+				return
+			}
+			rv.SetMapIndex(reflect.ValueOf(name).Convert(rv.Type().Key()), value)
+		}
+	case reflect.Slice, reflect.Array:
+		if s, ok := v.(string); ok && rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8 {
+			rv.SetBytes([]byte(s))
+			return
+		}
+
+		values := reflect.ValueOf(v)
+		if values.Kind() != reflect.Slice {
+			return invalid()
+		}
+
+		if rv.Kind() == reflect.Slice {
+			rv.Set(reflect.MakeSlice(rv.Type(), values.Len(), values.Len()))
+		} else if values.Len() > rv.Len() {
+			return invalid()
+		}
+		for i := 0; i < values.Len(); i++ {
+			err = assignValue(field, rv.Index(i), values.Index(i).Interface())
+			if err != nil {
+				return
+			}
+		}
+	default:
+		return invalid()
+	}
+	return
+}
+
+// assignStructFields stores the fields of an object into the fields of a struct with the same
+// struct tags.
+func assignStructFields(parentField string, rv reflect.Value, object map[string]interface{}) (err error) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		var tag structTag
+
+		structField := rt.Field(i)
+		if structField.PkgPath != "" {
+			continue
+		}
+
+		tag, err = parseStructTag(structField)
+		if err != nil {
+			return
+		}
+		if tag.Name == "" {
+			continue
+		}
+
+		fv := rv.Field(i)
+		if structField.Anonymous && structField.Tag.Get(StructTagKey) == "" {
+			embedded := fv
+			if embedded.Kind() == reflect.Ptr && embedded.Type().Elem().Kind() == reflect.Struct {
+				if embedded.IsNil() {
+					embedded.Set(reflect.New(embedded.Type().Elem()))
+				}
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct && embedded.Type() != timeType {
+				err = assignStructFields(parentField, embedded, object)
+				if err != nil {
+					return
+				}
+				continue
+			}
+		}
+
+		v, ok := object[tag.Name]
+		if !ok {
+			continue
+		}
+
+		field := tag.Name
+		if parentField != "" {
+			field = parentField + "." + tag.Name
+		}
+		err = assignValue(field, fv, v)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,156 @@
+package folder
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testAuthor struct {
+	Name    string   `folder:"name"`
+	Hobbies []string `folder:"hobbies,omitempty"`
+}
+
+type ArticleTimestamps struct {
+	CreatedAt time.Time  `folder:"created_at"`
+	DeletedAt *time.Time `folder:"deleted_at,omitempty"`
+}
+
+type testStatus string
+
+type testArticle struct {
+	ArticleTimestamps
+	Title     string            `folder:"title"`
+	Status    testStatus        `folder:"status,keyword"`
+	Views     int               `folder:"views"`
+	Rating    *float64          `folder:"rating,omitempty"`
+	Tags      []string          `folder:"tags,keyword,omitempty"`
+	Author    *testAuthor       `folder:"author"`
+	Reviewers []testAuthor      `folder:"reviewers"`
+	Embedding []float32         `folder:"embedding,vector"`
+	Extra     map[string]string `folder:"extra,omitempty"`
+	Draft     string            `folder:"-"`
+	Published bool
+	secret    string
+}
+
+func testArticleValue() testArticle {
+	rating := 4.5
+	return testArticle{
+		ArticleTimestamps: ArticleTimestamps{CreatedAt: time.Date(2021, 2, 12, 10, 30, 0, 0, time.UTC)},
+		Title:             "Folder, a search engine",
+		Status:            "Published",
+		Views:             42,
+		Rating:            &rating,
+		Tags:              []string{"search", "engine"},
+		Author:            &testAuthor{Name: "Lilis Iskandar", Hobbies: []string{"drawing"}},
+		Reviewers:         []testAuthor{{Name: "Budi"}},
+		Embedding:         []float32{0.5, 0.25},
+		Draft:             "not indexed",
+		Published:         true,
+		secret:            "not indexed either",
+	}
+}
+
+func TestDocumentFromStruct(t *testing.T) {
+	document, mappings, err := documentFromStruct(testArticleValue())
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"created_at": time.Date(2021, 2, 12, 10, 30, 0, 0, time.UTC),
+		"title":      "Folder, a search engine",
+		"status":     "Published",
+		"views":      42,
+		"rating":     4.5,
+		"tags":       []string{"search", "engine"},
+		"author":     map[string]interface{}{"name": "Lilis Iskandar", "hobbies": []string{"drawing"}},
+		"reviewers":  []interface{}{map[string]interface{}{"name": "Budi"}},
+		"embedding":  []float64{0.5, 0.25},
+		"Published":  true,
+	}, document)
+	assert.Equal(t, map[string]FieldMapping{
+		"status":    {Type: FieldTypeKeyword},
+		"tags":      {Type: FieldTypeKeyword},
+		"embedding": {Type: FieldTypeVector},
+	}, mappings)
+
+	_, _, err = documentFromStruct("article")
+	assert.True(t, errors.Is(err, ErrInvalidStruct))
+
+	_, _, err = documentFromStruct(struct {
+		Title string `folder:"title,huge"`
+	}{})
+	assert.True(t, errors.Is(err, ErrUnknownFieldType))
+
+	_, _, err = documentFromStruct(struct{ Done chan bool }{})
+	assert.True(t, errors.Is(err, ErrInvalidStruct))
+}
+
+func TestIndexStruct(t *testing.T) {
+	dir, err := os.MkdirTemp("", "folder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The types given by struct tags are not added when the struct is rejected
+	index := New()
+	err = index.SetMapping(Mapping{Fields: map[string]FieldMapping{"title": {Type: FieldTypeText}}, Dynamic: DynamicStrict})
+	assert.Nil(t, err)
+	article := testArticleValue()
+	_, err = index.IndexStructWithID(&article, "1")
+	assert.True(t, errors.Is(err, ErrUnknownField))
+	assert.Equal(t, 1, len(index.Mapping.Fields))
+
+	index = New()
+	_, err = index.IndexStructWithID(&article, "1")
+	assert.Nil(t, err)
+	assert.Equal(t, FieldTypeKeyword, index.Mapping.Fields["status"].Type)
+	assert.Equal(t, FieldTypeInteger, index.Mapping.Fields["views"].Type)
+
+	res, _ := index.SearchTerm("status", "Published", DefaultSearchOptions)
+	assert.Equal(t, 1, res.Count)
+	res, _ = index.Search("iskandar")
+	assert.Equal(t, 1, res.Count)
+
+	expected := article
+	expected.Draft = ""
+	expected.secret = ""
+
+	var decoded testArticle
+	err = res.Hits[0].Decode(&decoded)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, decoded)
+
+	// Documents are decoded the same way after they are saved
+	err = index.SaveToShards(dir+"/index", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loadedIndex, err := LoadDeferred(dir + "/index")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded = testArticle{}
+	err = loadedIndex.FetchInto("1", &decoded)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, decoded)
+
+	err = loadedIndex.FetchInto("2", &decoded)
+	assert.True(t, errors.Is(err, ErrDocumentNotFound))
+	err = loadedIndex.FetchInto("1", decoded)
+	assert.True(t, errors.Is(err, ErrInvalidStruct))
+
+	// Numbers stored as strings are parsed but numbers are not turned into strings
+	var values struct {
+		Views  int64  `folder:"views"`
+		Rating string `folder:"rating"`
+	}
+	hit := Hit{Source: map[string]interface{}{"views": "42", "rating": 4.5}}
+	err = hit.Decode(&values)
+	assert.True(t, errors.Is(err, ErrInvalidFieldValue))
+	assert.Equal(t, int64(42), values.Views)
+}
@@ -0,0 +1,76 @@
+package folder
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// TokenTypeWord is the type of tokens made of letters or digits.
+	TokenTypeWord = "word"
+	// TokenTypeNumber is the type of tokens made of digits.
+	TokenTypeNumber = "number"
+	// TokenTypeIdeographic is the type of tokens made of Han, Hiragana, or Katakana characters.
+	TokenTypeIdeographic = "ideographic"
+	// TokenTypeEmoji is the type of tokens made of emoji.
+	TokenTypeEmoji = "emoji"
+)
+
+// defaultSeparators are the runes that the separator tokenizer splits text on by default.
+const defaultSeparators = ",、　 ​"
+
+// SeparatorTokenizer splits text on every occurrence of any of its separator runes. Consecutive
+// separators produce empty tokens just like strings.Split.
+type SeparatorTokenizer struct {
+	Separators string
+}
+
+// newSeparatorTokenizer creates a separator tokenizer whose separators are the runes of the first
+// parameter, or the default separators if there is none.
+func newSeparatorTokenizer(params []string) (Tokenizer, error) {
+	separators := defaultSeparators
+	if len(params) > 0 {
+		separators = params[0]
+	}
+	return &SeparatorTokenizer{Separators: separators}, nil
+}
+
+// Tokenize splits text into tokens.
+func (tokenizer *SeparatorTokenizer) Tokenize(s string) (tokens []Token) {
+	start := 0
+	for i, r := range s {
+		if !strings.ContainsRune(tokenizer.Separators, r) {
+			continue
+		}
+		tokens = append(tokens, Token{Term: s[start:i], Type: TokenTypeWord, Position: len(tokens), Start: start, End: i})
+		start = i + utf8.RuneLen(r)
+	}
+	tokens = append(tokens, Token{Term: s[start:], Type: TokenTypeWord, Position: len(tokens), Start: start, End: len(s)})
+	return
+}
+
+// UnicodeTokenizer splits text into words at the word boundaries defined by Unicode Standard Annex
+// #29. Whitespace and punctuation between words are dropped. Hyphenated words and runs of Han,
+// Hiragana, and Katakana characters are kept together.
+type UnicodeTokenizer struct{}
+
+func newUnicodeTokenizer(params []string) (Tokenizer, error) {
+	return &UnicodeTokenizer{}, nil
+}
+
+// Tokenize splits text into tokens.
+func (tokenizer *UnicodeTokenizer) Tokenize(s string) (tokens []Token) {
+	for _, segment := range wordSegments(s) {
+		if segment.Type == "" {
+			continue
+		}
+		tokens = append(tokens, Token{
+			Term:     s[segment.Start:segment.End],
+			Type:     segment.Type,
+			Position: len(tokens),
+			Start:    segment.Start,
+			End:      segment.End,
+		})
+	}
+	return
+}
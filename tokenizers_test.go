@@ -0,0 +1,41 @@
+package folder
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func terms(tokens []Token) (terms []string) {
+	for _, token := range tokens {
+		terms = append(terms, token.Term)
+	}
+	return
+}
+
+func TestSeparatorTokenizer(t *testing.T) {
+	tokenizer := &SeparatorTokenizer{Separators: defaultSeparators}
+	tokens := tokenizer.Tokenize("シェフ、庭師  cook")
+	assert.Equal(t, []string{"シェフ", "庭師", "", "cook"}, terms(tokens))
+	assert.Equal(t, Token{Term: "庭師", Type: TokenTypeWord, Position: 1, Start: 12, End: 18}, tokens[1])
+}
+
+func TestUnicodeTokenizer(t *testing.T) {
+	tokenizer := &UnicodeTokenizer{}
+
+	tokens := tokenizer.Tokenize("Hello,  world!\n\tIt's 3.14 or 1,000.5 — isn't it?")
+	assert.Equal(t, []string{"Hello", "world", "It's", "3.14", "or", "1,000.5", "isn't", "it"}, terms(tokens))
+	assert.Equal(t, TokenTypeNumber, tokens[3].Type)
+	assert.Equal(t, Token{Term: "world", Type: TokenTypeWord, Position: 1, Start: 8, End: 13}, tokens[1])
+
+	tokens = tokenizer.Tokenize("Chae-Young Song «송채영» シェフ、庭師")
+	assert.Equal(t, []string{"Chae-Young", "Song", "송채영", "シェフ", "庭師"}, terms(tokens))
+	assert.Equal(t, TokenTypeIdeographic, tokens[4].Type)
+
+	tokens = tokenizer.Tokenize("I ❤️ 🍕🍕 and 👩‍💻 in 🇲🇾")
+	assert.Equal(t, []string{"I", "❤️", "🍕", "🍕", "and", "👩‍💻", "in", "🇲🇾"}, terms(tokens))
+	assert.Equal(t, TokenTypeEmoji, tokens[1].Type)
+
+	tokens = tokenizer.Tokenize("snake_case v0.1.0 été ​zero​width")
+	assert.Equal(t, []string{"snake_case", "v0.1.0", "été", "zero", "width"}, terms(tokens))
+}
@@ -0,0 +1,256 @@
+package folder
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+const (
+	// ScriptHangul is the transliteration filter parameter that romanizes Korean Hangul using the
+	// Revised Romanization of Korean.
+	ScriptHangul = "hangul"
+	// ScriptKana is the transliteration filter parameter that romanizes Japanese Hiragana and
+	// Katakana using Hepburn romanization.
+	ScriptKana = "kana"
+)
+
+var (
+	hangulInitials = []string{
+		"g", "kk", "n", "d", "tt", "r", "m", "b", "pp", "s", "ss", "", "j", "jj", "ch", "k", "t", "p", "h",
+	}
+	hangulVowels = []string{
+		"a", "ae", "ya", "yae", "eo", "e", "yeo", "ye", "o", "wa", "wae", "oe", "yo", "u", "wo", "we",
+		"wi", "yu", "eu", "ui", "i",
+	}
+	// hangulFinals are the romanizations of final consonants before another consonant or at the
+	// end of a word
+	hangulFinals = []string{
+		"", "k", "k", "k", "n", "n", "n", "t", "l", "k", "m", "l", "l", "l", "p", "l", "m", "p", "p",
+		"t", "t", "ng", "t", "t", "k", "t", "p", "t",
+	}
+	// hangulLinkedFinals are the romanizations of final consonants that are carried over to a
+	// following syllable starting with a vowel
+	hangulLinkedFinals = []string{
+		"", "g", "kk", "gs", "n", "nj", "n", "d", "r", "lg", "lm", "lb", "ls", "lt", "lp", "r", "m",
+		"b", "bs", "s", "ss", "ng", "j", "ch", "k", "t", "p", "",
+	}
+
+	hepburnDigraphs = map[string]string{
+		"きゃ": "kya", "きゅ": "kyu", "きょ": "kyo", "しゃ": "sha", "しゅ": "shu", "しょ": "sho",
+		"ちゃ": "cha", "ちゅ": "chu", "ちょ": "cho", "にゃ": "nya", "にゅ": "nyu", "にょ": "nyo",
+		"ひゃ": "hya", "ひゅ": "hyu", "ひょ": "hyo", "みゃ": "mya", "みゅ": "myu", "みょ": "myo",
+		"りゃ": "rya", "りゅ": "ryu", "りょ": "ryo", "ぎゃ": "gya", "ぎゅ": "gyu", "ぎょ": "gyo",
+		"じゃ": "ja", "じゅ": "ju", "じょ": "jo", "ぢゃ": "ja", "ぢゅ": "ju", "ぢょ": "jo",
+		"びゃ": "bya", "びゅ": "byu", "びょ": "byo", "ぴゃ": "pya", "ぴゅ": "pyu", "ぴょ": "pyo",
+		"しぇ": "she", "じぇ": "je", "ちぇ": "che", "てぃ": "ti", "でぃ": "di", "とぅ": "tu",
+		"どぅ": "du", "ふぁ": "fa", "ふぃ": "fi", "ふぇ": "fe", "ふぉ": "fo", "うぃ": "wi",
+		"うぇ": "we", "うぉ": "wo", "ゔぁ": "va", "ゔぃ": "vi", "ゔぇ": "ve", "ゔぉ": "vo",
+	}
+	hepburnMonographs = map[rune]string{
+		'あ': "a", 'い': "i", 'う': "u", 'え': "e", 'お': "o",
+		'か': "ka", 'き': "ki", 'く': "ku", 'け': "ke", 'こ': "ko",
+		'さ': "sa", 'し': "shi", 'す': "su", 'せ': "se", 'そ': "so",
+		'た': "ta", 'ち': "chi", 'つ': "tsu", 'て': "te", 'と': "to",
+		'な': "na", 'に': "ni", 'ぬ': "nu", 'ね': "ne", 'の': "no",
+		'は': "ha", 'ひ': "hi", 'ふ': "fu", 'へ': "he", 'ほ': "ho",
+		'ま': "ma", 'み': "mi", 'む': "mu", 'め': "me", 'も': "mo",
+		'や': "ya", 'ゆ': "yu", 'よ': "yo",
+		'ら': "ra", 'り': "ri", 'る': "ru", 'れ': "re", 'ろ': "ro",
+		'わ': "wa", 'ゐ': "i", 'ゑ': "e", 'を': "o", 'ん': "n",
+		'が': "ga", 'ぎ': "gi", 'ぐ': "gu", 'げ': "ge", 'ご': "go",
+		'ざ': "za", 'じ': "ji", 'ず': "zu", 'ぜ': "ze", 'ぞ': "zo",
+		'だ': "da", 'ぢ': "ji", 'づ': "zu", 'で': "de", 'ど': "do",
+		'ば': "ba", 'び': "bi", 'ぶ': "bu", 'べ': "be", 'ぼ': "bo",
+		'ぱ': "pa", 'ぴ': "pi", 'ぷ': "pu", 'ぺ': "pe", 'ぽ': "po",
+		'ゔ': "vu", 'ぁ': "a", 'ぃ': "i", 'ぅ': "u", 'ぇ': "e", 'ぉ': "o",
+		'ゃ': "ya", 'ゅ': "yu", 'ょ': "yo", 'ゎ': "wa", 'ゕ': "ka", 'ゖ': "ke",
+	}
+	// hepburnLongVowels collapses long vowels the way they are commonly written without macrons,
+	// e.g. "toukyou" becomes "tokyo".
+	hepburnLongVowels = strings.NewReplacer("ou", "o", "oo", "o", "uu", "u")
+)
+
+// TransliterationTokenFilter adds the romanized form of tokens written in Hangul or Kana at the
+// same position as the original token so that queries in either script match. Tokens with a
+// reading, such as those produced by the Japanese tokenizer, are romanized from their reading.
+type TransliterationTokenFilter struct {
+	Hangul bool
+	Kana   bool
+}
+
+// newTransliterationTokenFilter creates a transliteration filter for the scripts given as
+// parameters, or for both Hangul and Kana if there are none.
+func newTransliterationTokenFilter(params []string) (TokenFilter, error) {
+	if len(params) == 0 {
+		params = []string{ScriptHangul, ScriptKana}
+	}
+
+	filter := &TransliterationTokenFilter{}
+	for _, param := range params {
+		switch param {
+		case ScriptHangul:
+			filter.Hangul = true
+		case ScriptKana:
+			filter.Kana = true
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrUnknownScript, param)
+		}
+	}
+	return filter, nil
+}
+
+// Filter adds the romanized forms after the original tokens.
+func (filter *TransliterationTokenFilter) Filter(tokens []Token) (filteredTokens []Token) {
+	for _, token := range tokens {
+		filteredTokens = append(filteredTokens, token)
+
+		s := token.Term
+		if filter.Kana && token.Reading != "" && !isKana(s) {
+			s = token.Reading
+		}
+
+		romanized, ok := filter.romanize(s)
+		if !ok || romanized == token.Term {
+			continue
+		}
+
+		variants := []string{romanized}
+		if collapsed := hepburnLongVowels.Replace(romanized); filter.Kana && containsKana(s) && collapsed != romanized {
+			variants = append(variants, collapsed)
+		}
+		for _, variant := range variants {
+			transliterated := token
+			transliterated.Term = variant
+			transliterated.Type = TokenTypeWord
+			transliterated.BaseForm = ""
+			transliterated.Reading = ""
+			filteredTokens = append(filteredTokens, transliterated)
+		}
+	}
+	return
+}
+
+// romanize transliterates the Hangul or Kana of a string. It fails if the string contains other
+// characters that cannot be written in Latin script such as Kanji.
+func (filter *TransliterationTokenFilter) romanize(s string) (romanized string, ok bool) {
+	var b strings.Builder
+	runes := []rune(s)
+	changed := false
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch {
+		case filter.Hangul && isHangulSyllable(r):
+			var prev, next rune
+			if i > 0 {
+				prev = runes[i-1]
+			}
+			if i+1 < len(runes) {
+				next = runes[i+1]
+			}
+			b.WriteString(romanizeHangulSyllable(prev, r, next))
+		case filter.Kana && isKana(string(r)):
+			n := romanizeKana(&b, runes, i)
+			i += n - 1
+		case r < 0x80 || unicode.Is(unicode.Latin, r) || !unicode.IsLetter(r):
+			b.WriteRune(r)
+			continue
+		default:
+			return
+		}
+		changed = true
+	}
+
+	return b.String(), changed
+}
+
+func isHangulSyllable(r rune) bool {
+	return r >= 0xac00 && r <= 0xd7a3
+}
+
+// hangulJamo returns the indexes of the initial consonant, vowel, and final consonant of a Hangul
+// syllable. The final consonant is 0 if there is none.
+func hangulJamo(r rune) (initial, vowel, final int) {
+	i := int(r - 0xac00)
+	return i / 588, (i % 588) / 28, i % 28
+}
+
+// romanizeHangulSyllable romanizes a Hangul syllable given the runes around it, which determine
+// whether its final consonant is carried over to the next syllable and how ㄹ is written.
+func romanizeHangulSyllable(prev, r, next rune) string {
+	initial, vowel, final := hangulJamo(r)
+
+	romanized := hangulInitials[initial]
+	if _, _, prevFinal := hangulJamo(prev); isHangulSyllable(prev) && prevFinal == 8 && initial == 5 {
+		// ㄹㄹ is written as "ll"
+		romanized = "l"
+	}
+	romanized += hangulVowels[vowel]
+
+	if final == 0 {
+		return romanized
+	}
+	if nextInitial, _, _ := hangulJamo(next); isHangulSyllable(next) && nextInitial == 11 {
+		// The next syllable starts with a silent ㅇ
+		return romanized + hangulLinkedFinals[final]
+	}
+	return romanized + hangulFinals[final]
+}
+
+// isKana returns whether a string only contains Hiragana, Katakana, and prolonged sound marks.
+func isKana(s string) bool {
+	for _, r := range s {
+		if !unicode.In(r, unicode.Hiragana, unicode.Katakana) && r != 'ー' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// containsKana returns whether a string contains any Hiragana or Katakana.
+func containsKana(s string) bool {
+	return strings.IndexFunc(s, func(r rune) bool {
+		return unicode.In(r, unicode.Hiragana, unicode.Katakana)
+	}) >= 0
+}
+
+// romanizeKana writes the Hepburn romanization of the Kana at an offset and returns the number of
+// runes that were romanized.
+func romanizeKana(b *strings.Builder, runes []rune, i int) (n int) {
+	end := i + 2
+	if end > len(runes) {
+		end = len(runes)
+	}
+	kana := []rune(katakanaToHiragana(string(runes[i:end])))
+
+	switch {
+	case kana[0] == 'ー':
+		// Long vowels are not written
+		return 1
+	case kana[0] == 'っ' && len(kana) > 1:
+		// Small tsu doubles the following consonant
+		var next strings.Builder
+		romanizeKana(&next, runes, i+1)
+		if s := next.String(); s != "" && !strings.ContainsRune("aiueon", rune(s[0])) {
+			if strings.HasPrefix(s, "ch") {
+				b.WriteByte('t')
+			} else {
+				b.WriteByte(s[0])
+			}
+		}
+		return 1
+	case kana[0] == 'っ':
+		return 1
+	}
+
+	if len(kana) > 1 {
+		if romanized, ok := hepburnDigraphs[string(kana)]; ok {
+			b.WriteString(romanized)
+			return 2
+		}
+	}
+	b.WriteString(hepburnMonographs[kana[0]])
+	return 1
+}
@@ -0,0 +1,58 @@
+package folder
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransliterationTokenFilter(t *testing.T) {
+	filter, err := newTransliterationTokenFilter(nil)
+	assert.Nil(t, err)
+
+	romanized := func(s string) []string {
+		return terms(filter.Filter([]Token{{Term: s}}))
+	}
+	assert.Equal(t, []string{"송채영", "songchaeyeong"}, romanized("송채영"))
+	assert.Equal(t, []string{"한국어", "hangugeo"}, romanized("한국어"))
+	assert.Equal(t, []string{"서울", "seoul"}, romanized("서울"))
+	assert.Equal(t, []string{"ひらがな", "hiragana"}, romanized("ひらがな"))
+	assert.Equal(t, []string{"マッチャ", "matcha"}, romanized("マッチャ"))
+	assert.Equal(t, []string{"コーヒー", "kohi"}, romanized("コーヒー"))
+	assert.Equal(t, []string{"ゆうき", "yuuki", "yuki"}, romanized("ゆうき"))
+	assert.Equal(t, []string{"漢字"}, romanized("漢字"))
+
+	// Tokens with a reading are romanized from their reading at the same position
+	tokens := filter.Filter([]Token{{Term: "東京", Position: 3, Reading: "トウキョウ"}})
+	assert.Equal(t, []string{"東京", "toukyou", "tokyo"}, terms(tokens))
+	assert.Equal(t, 3, tokens[2].Position)
+
+	_, err = newTransliterationTokenFilter([]string{"cyrillic"})
+	assert.True(t, errors.Is(err, ErrUnknownScript))
+}
+
+func TestTransliterationSearch(t *testing.T) {
+	index := New()
+	err := index.AddAnalyzer("romanized", AnalyzerConfig{
+		Tokenizer:    ComponentConfig{Type: "unicode"},
+		TokenFilters: []ComponentConfig{{Type: "lowercase"}, {Type: "transliterate"}},
+	})
+	assert.Nil(t, err)
+	index.SetDefaultAnalyzer("romanized")
+
+	index.IndexWithID(map[string]interface{}{"name": "송 채영"}, "1")
+	index.IndexWithID(map[string]interface{}{"name": "Song Chaeyeong"}, "2")
+	index.IndexWithID(map[string]interface{}{"name": "송 강"}, "3")
+
+	// The query is transliterated too, so either script finds documents written in both
+	res, _ := index.Search("chaeyeong")
+	assert.Equal(t, 2, res.Count)
+	res, _ = index.Search("채영")
+	assert.Equal(t, 2, res.Count)
+	res, _ = index.Search("송 강")
+	assert.Equal(t, 1, res.Count)
+	assert.Equal(t, "3", res.Hits[0].ID)
+	res, _ = index.Search("song")
+	assert.Equal(t, 3, res.Count)
+}
@@ -2,7 +2,6 @@ package folder
 
 import (
 	"math/rand"
-	"strings"
 )
 
 const (
@@ -44,14 +43,3 @@ func generateRandomID(n int) string {
 	}
 	return string(b)
 }
-
-func splitWithRunes(s, runes string) (tokens []string) {
-	s = strings.Map(func(r rune) rune {
-		if strings.ContainsRune(runes, r) {
-			return ' '
-		}
-		return r
-	}, s)
-	tokens = strings.Split(s, " ")
-	return
-}
@@ -0,0 +1,129 @@
+package folder
+
+import (
+	"context"
+	"fmt"
+)
+
+// VersionConflictError is returned when a conditional update or delete expects a version of a
+// document other than its current version, which usually means that someone else changed the
+// document in the meantime. It matches ErrVersionConflict with errors.Is.
+type VersionConflictError struct {
+	DocumentID      string
+	ExpectedVersion uint64
+	CurrentVersion  uint64 // 0 if the document doesn't exist
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("%s: document %s has version %d instead of %d", ErrVersionConflict, e.DocumentID, e.CurrentVersion, e.ExpectedVersion)
+}
+
+// Is makes errors.Is(err, ErrVersionConflict) true for version conflict errors.
+func (e *VersionConflictError) Is(target error) bool {
+	return target == ErrVersionConflict
+}
+
+// Version returns the current version of a document, or 0 if the document doesn't exist.
+func (index *Index) Version(documentID string) (version uint64, err error) {
+	return index.VersionContext(context.Background(), documentID)
+}
+
+// VersionContext returns the version of a document just like Version but stops loading the shard
+// of the document when the context is done.
+func (index *Index) VersionContext(ctx context.Context, documentID string) (version uint64, err error) {
+	_, ok, err := index.fetchDocument(ctx, documentID)
+	if err != nil || !ok {
+		return
+	}
+
+	version = index.Versions[documentID]
+	return
+}
+
+// FetchWithVersion fetches a document with specific ID along with its version, which is 0 if the
+// document doesn't exist.
+func (index *Index) FetchWithVersion(documentID string) (document map[string]interface{}, version uint64, err error) {
+	return index.FetchWithVersionContext(context.Background(), documentID)
+}
+
+// FetchWithVersionContext fetches a document along with its version just like FetchWithVersion but
+// stops reading the shard when the context is done.
+func (index *Index) FetchWithVersionContext(ctx context.Context, documentID string) (document map[string]interface{}, version uint64, err error) {
+	var ok bool
+
+	if document, ok = index.Documents[documentID]; ok || index.ShardCount == 0 {
+		if ok {
+			version = index.Versions[documentID]
+		}
+		return
+	}
+
+	shardID := index.CalculateShardID(documentID)
+	document, version, err = index.fetchDocumentFromShard(ctx, shardID, documentID)
+	return
+}
+
+// IndexWithVersion indexes a document just like Index and also returns the version of the document.
+func (index *Index) IndexWithVersion(document map[string]interface{}) (documentID string, version uint64, err error) {
+	documentID = index.nextDocumentID()
+	version, err = index.updateWithVersion(documentID, document)
+	return
+}
+
+// IndexWithIDAndVersion indexes a document just like IndexWithID and also returns the version of the
+// document, which is 1 unless a document with the same ID was indexed before.
+func (index *Index) IndexWithIDAndVersion(document map[string]interface{}, desiredDocumentID string) (documentID string, version uint64, err error) {
+	documentID = desiredDocumentID
+	version, err = index.updateWithVersion(documentID, document)
+	return
+}
+
+// updateWithVersion updates a document and returns its new version.
+func (index *Index) updateWithVersion(documentID string, document map[string]interface{}) (version uint64, err error) {
+	err = index.Update(documentID, document)
+	if err != nil {
+		return
+	}
+
+	version = index.Versions[documentID]
+	return
+}
+
+// UpdateIfVersion updates a document just like Update but only if its current version is the given
+// version, otherwise a *VersionConflictError is returned. Version 0 only creates the document if it
+// doesn't exist yet. The new version of the document is returned.
+func (index *Index) UpdateIfVersion(documentID string, document map[string]interface{}, version uint64) (newVersion uint64, err error) {
+	err = index.checkVersion(documentID, version)
+	if err != nil {
+		return
+	}
+
+	newVersion, err = index.updateWithVersion(documentID, document)
+	return
+}
+
+// DeleteIfVersion deletes a document just like Delete but only if its current version is the given
+// version, otherwise a *VersionConflictError is returned.
+func (index *Index) DeleteIfVersion(documentID string, version uint64) (err error) {
+	err = index.checkVersion(documentID, version)
+	if err != nil {
+		return
+	}
+
+	err = index.Delete(documentID)
+	return
+}
+
+// checkVersion returns a version conflict error if a document doesn't have the expected version.
+func (index *Index) checkVersion(documentID string, version uint64) (err error) {
+	currentVersion, err := index.Version(documentID)
+	if err != nil {
+		return
+	}
+
+	if currentVersion != version {
+		debug("  Version conflict", documentID, currentVersion, version)
+		err = &VersionConflictError{DocumentID: documentID, ExpectedVersion: version, CurrentVersion: currentVersion}
+	}
+	return
+}
@@ -0,0 +1,121 @@
+package folder
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersions(t *testing.T) {
+	index := New()
+
+	version, err := index.UpdateIfVersion("1", map[string]interface{}{"title": "Folder"}, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), version)
+
+	// Version 0 only creates documents
+	_, err = index.UpdateIfVersion("1", map[string]interface{}{"title": "Folder"}, 0)
+	assert.True(t, errors.Is(err, ErrVersionConflict))
+
+	index.IndexWithID(map[string]interface{}{"title": "Folder search"}, "1")
+	document, version, err := index.FetchWithVersion("1")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), version)
+	assert.Equal(t, "Folder search", document["title"])
+
+	// Writers that read an older version cannot overwrite newer changes
+	_, err = index.UpdateIfVersion("1", map[string]interface{}{"title": "Stale"}, 1)
+	var conflict *VersionConflictError
+	assert.True(t, errors.As(err, &conflict))
+	assert.Equal(t, VersionConflictError{DocumentID: "1", ExpectedVersion: 1, CurrentVersion: 2}, *conflict)
+
+	res, _ := index.Search("search")
+	assert.Equal(t, 1, res.Count)
+	assert.Equal(t, uint64(2), res.Hits[0].Version)
+
+	err = index.DeleteIfVersion("1", 1)
+	assert.True(t, errors.Is(err, ErrVersionConflict))
+	err = index.DeleteIfVersion("1", 2)
+	assert.Nil(t, err)
+
+	document, version, _ = index.FetchWithVersion("1")
+	assert.Nil(t, document)
+	assert.Equal(t, uint64(0), version)
+	version, _ = index.Version("1")
+	assert.Equal(t, uint64(0), version)
+	res, _ = index.Search("search")
+	assert.Equal(t, 0, res.Count)
+
+	// Deleted documents that are indexed again keep getting newer versions
+	_, version, err = index.IndexWithIDAndVersion(map[string]interface{}{"title": "Folder again"}, "1")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(3), version)
+
+	documentID, version, err := index.IndexWithVersion(map[string]interface{}{"title": "Generated"})
+	assert.Nil(t, err)
+	assert.NotEqual(t, "", documentID)
+	assert.Equal(t, uint64(1), version)
+}
+
+func TestSaveAndLoadVersions(t *testing.T) {
+	dir, err := os.MkdirTemp("", "folder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	index := New()
+	index.IndexWithID(map[string]interface{}{"title": "Folder"}, "1")
+	index.IndexWithID(map[string]interface{}{"title": "Folder search"}, "1")
+	index.IndexWithID(map[string]interface{}{"title": "Search engine"}, "2")
+	index.IndexWithID(map[string]interface{}{"title": "Deleted"}, "3")
+	index.Delete("3")
+
+	err = index.SaveToShards(dir+"/index", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loadedIndex, err := LoadDeferred(dir + "/index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, version, err := loadedIndex.FetchWithVersion("1")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), version)
+
+	// Updates of deferred indexes replace the saved documents
+	version, err = loadedIndex.UpdateIfVersion("2", map[string]interface{}{"title": "Search library"}, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), version)
+	res, _ := loadedIndex.Search("engine")
+	assert.Equal(t, 0, res.Count)
+	res, _ = loadedIndex.Search("library")
+	assert.Equal(t, 1, res.Count)
+
+	// The versions of deleted documents are saved too
+	document, version, err := loadedIndex.FetchWithVersion("3")
+	assert.Nil(t, err)
+	assert.Nil(t, document)
+	assert.Equal(t, uint64(0), version)
+	_, version, err = loadedIndex.IndexWithIDAndVersion(map[string]interface{}{"title": "Restored"}, "3")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), version)
+
+	err = index.Save(dir + "/single")
+	if err != nil {
+		t.Fatal(err)
+	}
+	loadedIndex, err = Load(dir + "/single")
+	if err != nil {
+		t.Fatal(err)
+	}
+	version, _ = loadedIndex.Version("1")
+	assert.Equal(t, uint64(2), version)
+	version, _ = loadedIndex.Version("3")
+	assert.Equal(t, uint64(0), version)
+	_, version, _ = loadedIndex.IndexWithIDAndVersion(map[string]interface{}{"title": "Restored"}, "3")
+	assert.Equal(t, uint64(2), version)
+}
@@ -0,0 +1,290 @@
+package folder
+
+import (
+	"unicode"
+)
+
+// wordBreakClass is the Word_Break property of a rune as defined by Unicode Standard Annex #29.
+type wordBreakClass int
+
+const (
+	wbOther wordBreakClass = iota
+	wbCR
+	wbLF
+	wbNewline
+	wbExtend
+	wbZWJ
+	wbRegionalIndicator
+	wbFormat
+	wbHebrewLetter
+	wbALetter
+	wbSingleQuote
+	wbDoubleQuote
+	wbMidNumLet
+	wbMidLetter
+	wbMidNum
+	wbNumeric
+	wbExtendNumLet
+	wbWSegSpace
+	// wbIdeographic is not part of UAX #29. It is a tailoring that keeps runs of Han, Hiragana, and
+	// Katakana characters together instead of breaking between every ideograph, leaving their
+	// segmentation to language-specific tokenizers.
+	wbIdeographic
+)
+
+var (
+	// extendedPictographic approximates the Extended_Pictographic property, i.e. emoji and other
+	// pictographs.
+	extendedPictographic = &unicode.RangeTable{
+		R16: []unicode.Range16{
+			{Lo: 0x00a9, Hi: 0x00a9, Stride: 1},
+			{Lo: 0x00ae, Hi: 0x00ae, Stride: 1},
+			{Lo: 0x203c, Hi: 0x203c, Stride: 1},
+			{Lo: 0x2049, Hi: 0x2049, Stride: 1},
+			{Lo: 0x2122, Hi: 0x2122, Stride: 1},
+			{Lo: 0x2139, Hi: 0x2139, Stride: 1},
+			{Lo: 0x2194, Hi: 0x2199, Stride: 1},
+			{Lo: 0x21a9, Hi: 0x21aa, Stride: 1},
+			{Lo: 0x231a, Hi: 0x231b, Stride: 1},
+			{Lo: 0x2328, Hi: 0x2328, Stride: 1},
+			{Lo: 0x23cf, Hi: 0x23cf, Stride: 1},
+			{Lo: 0x23e9, Hi: 0x23f3, Stride: 1},
+			{Lo: 0x23f8, Hi: 0x23fa, Stride: 1},
+			{Lo: 0x24c2, Hi: 0x24c2, Stride: 1},
+			{Lo: 0x25aa, Hi: 0x25ab, Stride: 1},
+			{Lo: 0x25b6, Hi: 0x25b6, Stride: 1},
+			{Lo: 0x25c0, Hi: 0x25c0, Stride: 1},
+			{Lo: 0x25fb, Hi: 0x25fe, Stride: 1},
+			{Lo: 0x2600, Hi: 0x27bf, Stride: 1},
+			{Lo: 0x2934, Hi: 0x2935, Stride: 1},
+			{Lo: 0x2b05, Hi: 0x2b07, Stride: 1},
+			{Lo: 0x2b1b, Hi: 0x2b1c, Stride: 1},
+			{Lo: 0x2b50, Hi: 0x2b50, Stride: 1},
+			{Lo: 0x2b55, Hi: 0x2b55, Stride: 1},
+			{Lo: 0x3030, Hi: 0x3030, Stride: 1},
+			{Lo: 0x303d, Hi: 0x303d, Stride: 1},
+			{Lo: 0x3297, Hi: 0x3297, Stride: 1},
+			{Lo: 0x3299, Hi: 0x3299, Stride: 1},
+		},
+		R32: []unicode.Range32{
+			{Lo: 0x1f000, Hi: 0x1f1e5, Stride: 1},
+			{Lo: 0x1f200, Hi: 0x1f3fa, Stride: 1},
+			{Lo: 0x1f400, Hi: 0x1faff, Stride: 1},
+			{Lo: 0x1fc00, Hi: 0x1fffd, Stride: 1},
+		},
+	}
+
+	// saScripts are scripts that are written without spaces between words and are kept together
+	// like letters since no dictionary is available to segment them.
+	saScripts = []*unicode.RangeTable{unicode.Thai, unicode.Lao, unicode.Myanmar, unicode.Khmer}
+)
+
+// wordBreakClassOf returns the Word_Break property of a rune. Colons are not treated as MidLetter
+// and hyphens between letters are treated as MidLetter so that hyphenated names stay together.
+func wordBreakClassOf(r rune) wordBreakClass {
+	switch r {
+	case '\r':
+		return wbCR
+	case '\n':
+		return wbLF
+	case 0x000b, 0x000c, 0x0085, 0x2028, 0x2029:
+		return wbNewline
+	case 0x200d:
+		return wbZWJ
+	case 0x200c, 0xff9e, 0xff9f:
+		return wbExtend
+	case 0x200b:
+		return wbOther
+	case '\'':
+		return wbSingleQuote
+	case '"':
+		return wbDoubleQuote
+	case '.', 0x2018, 0x2019, 0x2024, 0xfe52, 0xff07, 0xff0e:
+		return wbMidNumLet
+	case '-', 0x2010, 0x2011, 0x00b7, 0x0387, 0x055f, 0x05f4, 0x2027, 0xfe13, 0xfe55, 0xff1a:
+		return wbMidLetter
+	case ',', ';', 0x037e, 0x0589, 0x060c, 0x060d, 0x066c, 0x07f8, 0x2044, 0xfe10, 0xfe14, 0xfe50, 0xfe54, 0xff0c, 0xff1b:
+		return wbMidNum
+	case 0x202f:
+		return wbExtendNumLet
+	case 0x00a0, 0x2007:
+		return wbOther
+	case 0x3005, 0x3006, 0x3007, 0x303b, 0x30fc, 0xff70:
+		return wbIdeographic
+	}
+
+	switch {
+	case r >= 0x1f1e6 && r <= 0x1f1ff:
+		return wbRegionalIndicator
+	case r >= 0x1f3fb && r <= 0x1f3ff:
+		return wbExtend
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc):
+		return wbExtend
+	case unicode.Is(unicode.Cf, r):
+		return wbFormat
+	case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana):
+		return wbIdeographic
+	case unicode.Is(unicode.Hebrew, r) && unicode.Is(unicode.Lo, r):
+		return wbHebrewLetter
+	case unicode.IsLetter(r), unicode.Is(unicode.Nl, r), unicode.In(r, saScripts...):
+		return wbALetter
+	case unicode.Is(unicode.Nd, r):
+		return wbNumeric
+	case unicode.Is(unicode.Pc, r):
+		return wbExtendNumLet
+	case unicode.Is(unicode.Zs, r):
+		return wbWSegSpace
+	}
+
+	return wbOther
+}
+
+func (class wordBreakClass) isAHLetter() bool {
+	return class == wbALetter || class == wbHebrewLetter
+}
+
+func (class wordBreakClass) isMidNumLetQ() bool {
+	return class == wbMidNumLet || class == wbSingleQuote
+}
+
+func (class wordBreakClass) isIgnorable() bool {
+	return class == wbExtend || class == wbFormat || class == wbZWJ
+}
+
+func (class wordBreakClass) isNewline() bool {
+	return class == wbCR || class == wbLF || class == wbNewline
+}
+
+// wordSegment is a piece of text between two word boundaries.
+type wordSegment struct {
+	Start int
+	End   int
+	Type  string // Empty if the segment contains no letters, numbers, or emoji
+}
+
+// wordSegments splits text at its word boundaries following the rules of UAX #29.
+func wordSegments(s string) (segments []wordSegment) {
+	var offsets []int
+	var classes []wordBreakClass
+	var pictographic []bool
+
+	for i, r := range s {
+		offsets = append(offsets, i)
+		classes = append(classes, wordBreakClassOf(r))
+		pictographic = append(pictographic, unicode.Is(extendedPictographic, r))
+	}
+	offsets = append(offsets, len(s))
+
+	// prev returns the index of the last rune before i that is not ignored because of WB4
+	prev := func(i int) int {
+		for i--; i >= 0 && classes[i].isIgnorable(); i-- {
+		}
+		return i
+	}
+	// next returns the index of the first rune after i that is not ignored because of WB4
+	next := func(i int) int {
+		for i++; i < len(classes) && classes[i].isIgnorable(); i++ {
+		}
+		return i
+	}
+	classAt := func(i int) wordBreakClass {
+		if i < 0 || i >= len(classes) {
+			return wbOther
+		}
+		return classes[i]
+	}
+
+	isBoundary := func(i int) bool {
+		before, after := classes[i-1], classes[i]
+
+		switch {
+		case before == wbCR && after == wbLF: // WB3
+			return false
+		case before.isNewline() || after.isNewline(): // WB3a, WB3b
+			return true
+		case before == wbZWJ && pictographic[i]: // WB3c
+			return false
+		case before == wbWSegSpace && after == wbWSegSpace: // WB3d
+			return false
+		case after.isIgnorable(): // WB4
+			return false
+		}
+
+		p := prev(i)
+		before = classAt(p)
+		beforeBefore := classAt(prev(p))
+		afterAfter := classAt(next(i))
+
+		switch {
+		case before.isAHLetter() && after.isAHLetter(): // WB5
+			return false
+		case before.isAHLetter() && (after == wbMidLetter || after.isMidNumLetQ()) && afterAfter.isAHLetter(): // WB6
+			return false
+		case beforeBefore.isAHLetter() && (before == wbMidLetter || before.isMidNumLetQ()) && after.isAHLetter(): // WB7
+			return false
+		case before == wbHebrewLetter && after == wbSingleQuote: // WB7a
+			return false
+		case before == wbHebrewLetter && after == wbDoubleQuote && afterAfter == wbHebrewLetter: // WB7b
+			return false
+		case beforeBefore == wbHebrewLetter && before == wbDoubleQuote && after == wbHebrewLetter: // WB7c
+			return false
+		case before == wbNumeric && after == wbNumeric: // WB8
+			return false
+		case before.isAHLetter() && after == wbNumeric: // WB9
+			return false
+		case before == wbNumeric && after.isAHLetter(): // WB10
+			return false
+		case beforeBefore == wbNumeric && (before == wbMidNum || before.isMidNumLetQ()) && after == wbNumeric: // WB11
+			return false
+		case before == wbNumeric && (after == wbMidNum || after.isMidNumLetQ()) && afterAfter == wbNumeric: // WB12
+			return false
+		case before == wbIdeographic && after == wbIdeographic: // WB13 (tailored)
+			return false
+		case (before.isAHLetter() || before == wbNumeric || before == wbIdeographic || before == wbExtendNumLet) && after == wbExtendNumLet: // WB13a
+			return false
+		case before == wbExtendNumLet && (after.isAHLetter() || after == wbNumeric || after == wbIdeographic): // WB13b
+			return false
+		case before == wbRegionalIndicator && after == wbRegionalIndicator: // WB15, WB16
+			count := 0
+			for j := p; j >= 0 && (classes[j] == wbRegionalIndicator || classes[j].isIgnorable()); j-- {
+				if classes[j] == wbRegionalIndicator {
+					count++
+				}
+			}
+			return count%2 == 0
+		}
+
+		return true // WB999
+	}
+
+	start := 0
+	for i := 1; i <= len(classes); i++ {
+		if i < len(classes) && !isBoundary(i) {
+			continue
+		}
+		segments = append(segments, wordSegment{
+			Start: offsets[start],
+			End:   offsets[i],
+			Type:  wordSegmentType(classes[start:i], pictographic[start:i]),
+		})
+		start = i
+	}
+	return
+}
+
+// wordSegmentType returns the token type of a segment from the classes of its runes.
+func wordSegmentType(classes []wordBreakClass, pictographic []bool) (tokenType string) {
+	for i, class := range classes {
+		switch {
+		case class == wbIdeographic:
+			return TokenTypeIdeographic
+		case class.isAHLetter():
+			tokenType = TokenTypeWord
+		case class == wbNumeric && tokenType == "":
+			tokenType = TokenTypeNumber
+		case (class == wbRegionalIndicator || pictographic[i]) && tokenType == "":
+			tokenType = TokenTypeEmoji
+		}
+	}
+	return
+}